@@ -1,19 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/analytics"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/audit"
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/changes"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/comments"
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/drafts"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/favorites"
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/handlers"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/presence"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/ratelimit"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/share"
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/trash"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/webhooks"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 func main() {
@@ -34,30 +51,45 @@ func main() {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
-	// Sync from GitHub to local on startup
-	log.Printf("Syncing data from GitHub...")
-	if err := store.Sync(); err != nil {
-		log.Printf("Warning: Failed to sync from GitHub on startup: %v", err)
-	} else {
-		log.Printf("Sync from GitHub completed successfully")
-	}
-
 	// Initialize Gin router
 	router := gin.Default()
 
-	// Set up session middleware
-	sessionStore := cookie.NewStore([]byte(cfg.Session.Secret))
-	sessionStore.Options(sessions.Options{
-		Path:     "/",
-		MaxAge:   3600, // 1 hour
-		HttpOnly: true,
-		Secure:   cfg.Session.Secure, // Use secure cookies in production
-		SameSite: http.SameSiteLaxMode,
-		Domain:   "", // Empty domain to ensure cookie is only sent to the exact domain
-	})
-	log.Printf("Initializing session store with MaxAge: 3600 seconds (1 hour), Secure: %v", cfg.Session.Secure)
+	// Client IPs key rate limits, login lockouts and audit entries, so only
+	// take them from X-Forwarded-For when the request came through a known proxy
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid server.trusted_proxies: %v", err)
+	}
+
+	// Set up session middleware, keeping sessions in Redis when they should be
+	// listable and revocable
+	var sessionStore sessions.Store
+	var sessionManager auth.SessionManager
+	switch cfg.Session.Backend {
+	case "redis":
+		redisSessions, err := auth.NewRedisSessionStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		}), []byte(cfg.Session.Secret))
+		if err != nil {
+			log.Fatalf("Failed to initialize session store: %v", err)
+		}
+		sessionStore = redisSessions
+		sessionManager = redisSessions
+	case "cookie":
+		sessionStore = cookie.NewStore([]byte(cfg.Session.Secret))
+	default:
+		log.Fatalf("Failed to initialize session store: unknown session.backend %q", cfg.Session.Backend)
+	}
+	lifetime := auth.SessionLifetime()
+	sessionStore.Options(auth.CookieOptions(lifetime.CookieMaxAge(false)))
+	log.Printf("Initializing %s session store with idle timeout %v, max age %v, remember me %v, Secure: %v",
+		cfg.Session.Backend, lifetime.Idle, lifetime.Absolute, lifetime.Remember, cfg.Session.Secure)
 	router.Use(sessions.Sessions("wiki_session", sessionStore))
 
+	// Every non-GET request must carry the session's CSRF token
+	router.Use(auth.CSRFProtect())
+
 	// Set up template functions
 	router.SetFuncMap(template.FuncMap{
 		"add": func(a, b int) int {
@@ -69,6 +101,17 @@ func main() {
 		"html": func(value interface{}) template.HTML {
 			return template.HTML(fmt.Sprint(value))
 		},
+		"formatTime": func(value interface{}) string {
+			switch t := value.(type) {
+			case time.Time:
+				return t.Format("2006-01-02 15:04")
+			case *time.Time:
+				if t != nil {
+					return t.Format("2006-01-02 15:04")
+				}
+			}
+			return ""
+		},
 	})
 
 	// Set up static files
@@ -78,35 +121,360 @@ func main() {
 	// Initialize handlers with storage
 	handlers.InitHandlers(store)
 
+	// Initialize personal access tokens
+	tokens, err := auth.NewTokenStore(filepath.Join(cfg.Server.StateDir, "tokens.json"))
+	if err != nil {
+		log.Fatalf("Failed to initialize token store: %v", err)
+	}
+	handlers.InitTokenHandlers(tokens)
+
 	// Initialize auth handlers
-	handlers.InitAuthHandlers(cfg)
+	if err := handlers.InitAuthHandlers(cfg, tokens); err != nil {
+		log.Fatalf("Failed to initialize login providers: %v", err)
+	}
+	handlers.InitSessionHandlers(sessionManager)
+
+	// Initialize role-based access control
+	policy, err := access.NewPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load access policy: %v", err)
+	}
+	handlers.InitAccessHandlers(policy)
+
+	// Initialize the audit log, optionally mirrored to Redis
+	auditOpts := audit.Options{
+		Dir:         filepath.Join(cfg.Server.StateDir, "audit"),
+		MaxSizeMB:   cfg.Audit.MaxSizeMB,
+		MaxFiles:    cfg.Audit.MaxFiles,
+		RedisLength: cfg.Audit.RedisLength,
+	}
+	if cfg.Audit.MirrorRedis {
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			log.Printf("Warning: Audit Redis mirror disabled, connection failed: %v", err)
+		} else {
+			auditOpts.RedisClient = client
+		}
+	}
+	auditLog, err := audit.NewLogger(auditOpts)
+	if err != nil {
+		log.Fatalf("Failed to initialize audit log: %v", err)
+	}
+	defer auditLog.Close()
+	handlers.InitAuditHandlers(auditLog)
+
+	// Initialize the trash bin for deleted pages and folders, keeping page
+	// comments with their page
+	trashBin, err := trash.NewBin(cfg.Server.DataDir, filepath.Join(cfg.Server.StateDir, "trash"), comments.Suffix)
+	if err != nil {
+		log.Fatalf("Failed to initialize trash: %v", err)
+	}
+	trashBin.StartSweeper(time.Duration(cfg.Trash.RetentionDays) * 24 * time.Hour)
+	handlers.InitTrashHandlers(trashBin)
+
+	// Initialize autosaved drafts
+	draftTTL := time.Duration(cfg.Drafts.RetentionDays) * 24 * time.Hour
+	var draftStore drafts.Store
+	switch cfg.Drafts.Backend {
+	case "redis":
+		draftStore, err = drafts.NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		}), draftTTL)
+	case "file":
+		draftStore, err = drafts.NewFileStore(filepath.Join(cfg.Server.StateDir, "drafts"), draftTTL)
+	default:
+		err = fmt.Errorf("unknown drafts.backend %q", cfg.Drafts.Backend)
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize drafts: %v", err)
+	}
+	handlers.InitDraftHandlers(draftStore)
+
+	// Initialize favorites and recently viewed pages
+	var favoriteStore favorites.Store
+	switch cfg.Favorites.Backend {
+	case "redis":
+		favoriteStore, err = favorites.NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		}))
+	case "file":
+		favoriteStore, err = favorites.NewFileStore(filepath.Join(cfg.Server.StateDir, "favorites"))
+	default:
+		err = fmt.Errorf("unknown favorites.backend %q", cfg.Favorites.Backend)
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize favorites: %v", err)
+	}
+	handlers.InitFavoriteHandlers(favoriteStore)
+
+	// Initialize edit presence, shared through Redis when it is available
+	presenceTTL := time.Duration(cfg.Presence.TTLSeconds) * time.Second
+	var presenceStore presence.Store
+	if cfg.Redis.Enabled {
+		presenceStore, err = presence.NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		}), presenceTTL)
+		if err != nil {
+			log.Printf("Warning: Presence falling back to memory, Redis connection failed: %v", err)
+			presenceStore = nil
+		}
+	}
+	if presenceStore == nil {
+		presenceStore = presence.NewMemoryStore(presenceTTL)
+	}
+	handlers.InitPresenceHandlers(presenceStore, cfg.Presence.SoftLock)
+
+	// Initialize page view analytics, kept in Redis when it is available
+	var tracker analytics.Tracker
+	if cfg.Redis.Enabled {
+		tracker, err = analytics.NewRedisTracker(redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		}), cfg.Analytics.RetentionDays)
+		if err != nil {
+			log.Printf("Warning: Analytics falling back to memory, Redis connection failed: %v", err)
+			tracker = nil
+		}
+	}
+	if tracker == nil {
+		tracker = analytics.NewMemoryTracker(cfg.Analytics.RetentionDays)
+	}
+	handlers.InitAnalyticsHandlers(tracker, cfg.Analytics.PopularDays)
+
+	// Initialize rate limiting, shared through Redis when it is available
+	var limitStore ratelimit.Store
+	if cfg.Redis.Enabled {
+		limitStore, err = ratelimit.NewRedisStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		}))
+		if err != nil {
+			log.Printf("Warning: Rate limiting falling back to memory, Redis connection failed: %v", err)
+			limitStore = nil
+		}
+	}
+	if limitStore == nil {
+		limitStore = ratelimit.NewMemoryStore()
+	}
+	authLimit := ratelimit.Middleware(limitStore, "auth", ratelimit.Limit(cfg.RateLimit.Auth))
+	apiLimit := ratelimit.Middleware(limitStore, "api", ratelimit.Limit(cfg.RateLimit.API))
+	webLimit := ratelimit.Middleware(limitStore, "web", ratelimit.Limit(cfg.RateLimit.Web))
+
+	// Comments live next to each page in the backing storage
+	handlers.InitCommentHandlers(comments.NewStore())
+
+	// Initialize real-time collaborative editing
+	handlers.InitCollabHandlers(time.Duration(cfg.Collab.IdleSeconds) * time.Second)
+
+	// Initialize watch lists and email notifications
+	watchStore, err := notify.NewWatchStore(filepath.Join(cfg.Server.StateDir, "watches.json"))
+	if err != nil {
+		log.Fatalf("Failed to initialize watches: %v", err)
+	}
+	var notifier *notify.Notifier
+	if cfg.Notify.SMTP.Host != "" {
+		notifier, err = notify.NewNotifier(notify.Options{
+			Watches: watchStore,
+			Mailer: &notify.SMTPMailer{
+				Host:     cfg.Notify.SMTP.Host,
+				Port:     cfg.Notify.SMTP.Port,
+				Username: cfg.Notify.SMTP.Username,
+				Password: cfg.Notify.SMTP.Password,
+				From:     cfg.Notify.SMTP.From,
+			},
+			BaseURL:    cfg.Notify.BaseURL,
+			StateFile:  filepath.Join(cfg.Server.StateDir, "notify", "pending.json"),
+			BatchDelay: time.Duration(cfg.Notify.BatchMinutes) * time.Minute,
+			DigestHour: cfg.Notify.DigestHour,
+			CanView: func(email, wikiPath string) bool {
+				return policy.Can(email, wikiPath, access.RoleViewer)
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize notifications: %v", err)
+		}
+		notifier.Start()
+	} else {
+		log.Printf("Email notifications disabled, notify.smtp.host is not set")
+	}
+	handlers.InitWatchHandlers(watchStore, notifier)
+
+	// Initialize outgoing webhooks
+	webhookManager, err := webhooks.NewManager(webhooks.Options{
+		Dir:         filepath.Join(cfg.Server.StateDir, "webhooks"),
+		Timeout:     time.Duration(cfg.Webhooks.TimeoutSeconds) * time.Second,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		HistorySize: cfg.Webhooks.HistorySize,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize webhooks: %v", err)
+	}
+	handlers.InitWebhookHandlers(webhookManager)
+
+	// Initialize recent changes, read from GitHub history when the storage has it
+	changeJournal, err := changes.NewJournal(filepath.Join(cfg.Server.StateDir, "changes.jsonl"), cfg.Changes.JournalSize)
+	if err != nil {
+		log.Fatalf("Failed to initialize change journal: %v", err)
+	}
+	history, _ := store.(types.ChangeLister)
+	feedTokens, err := auth.NewFeedTokenStore(filepath.Join(cfg.Server.StateDir, "feed_tokens.json"))
+	if err != nil {
+		log.Fatalf("Failed to initialize feed tokens: %v", err)
+	}
+	changeFeed := changes.NewFeed(history, changeJournal, time.Duration(cfg.Changes.CacheSeconds)*time.Second)
+	handlers.InitChangeHandlers(changeFeed, feedTokens, cfg.Notify.BaseURL)
+
+	// Initialize signed share links to single pages
+	shareLinks, err := share.NewStore(filepath.Join(cfg.Server.StateDir, "share_links.json"), []byte(cfg.Share.Secret))
+	if err != nil {
+		log.Fatalf("Failed to initialize share links: %v", err)
+	}
+	handlers.InitShareHandlers(shareLinks, cfg.Notify.BaseURL)
+
+	// Sync from GitHub to local on startup, once watchers can be notified
+	log.Printf("Syncing data from GitHub...")
+	if err := store.Sync(); err != nil {
+		log.Printf("Warning: Failed to sync from GitHub on startup: %v", err)
+	} else {
+		log.Printf("Sync from GitHub completed successfully")
+	}
 
 	// Auth routes (no auth required)
 	router.GET("/login", handlers.LoginHandler)
-	router.GET("/auth/google", handlers.GoogleLoginHandler)
-	router.GET("/auth/google/callback", handlers.GoogleCallbackHandler)
-	router.GET("/logout", handlers.LogoutHandler)
+	router.POST("/login", authLimit, handlers.PasswordLoginHandler)
+	router.GET("/auth/:provider", authLimit, handlers.ProviderLoginHandler)
+	router.GET("/auth/:provider/callback", authLimit, handlers.ProviderCallbackHandler)
+	router.POST("/logout", handlers.LogoutHandler)
+	router.GET("/session", auth.SessionStatusHandler)
+
+	// Share links open one page read-only without signing in
+	router.GET("/s/:token", webLimit, handlers.SharedPageHandler)
+	router.POST("/s/:token", authLimit, handlers.UnlockShareHandler)
+
+	// Atom feeds authenticate with a feed token so readers work without a session
+	router.GET("/feed.atom", auth.FeedAuthRequired(feedTokens), webLimit, access.Require(policy, access.RoleViewer, handlers.FolderQueryFromRequest), handlers.FeedHandler)
+
+	// Read-only routes that anonymous visitors may use for access.public_folders
+	public := router.Group("/")
+	public.Use(auth.AuthOptional())
+	{
+		public.GET("/view/:title", webLimit, access.Require(policy, access.RoleViewer, handlers.PagePathFromRequest), handlers.ViewHandler)
+		public.GET("/category/*path", webLimit, access.Require(policy, access.RoleViewer, handlers.WildcardPathFromRequest), handlers.CategoryHandler)
+		public.GET("/api/folders/children/*path", apiLimit, access.Require(policy, access.RoleViewer, handlers.WildcardPathFromRequest), handlers.GetFolderChildrenHandler)
+	}
 
 	// Protected routes (auth required)
 	protected := router.Group("/")
-	protected.Use(auth.AuthRequired())
+	protected.Use(auth.AuthRequired(), webLimit)
 	{
 		protected.GET("/", handlers.HomeHandler)
-		protected.GET("/view/:title", handlers.ViewHandler)
-		protected.GET("/edit/:title", handlers.EditHandler)
-		protected.GET("/new", handlers.EditHandler)
+		protected.GET("/edit/:title", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.EditHandler)
+		protected.GET("/new", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.EditHandler)
 		protected.POST("/save", handlers.SaveHandler)
-		protected.POST("/delete/:title", handlers.DeleteHandler)
-		protected.GET("/delete/:title", handlers.DeleteHandler)
+		protected.PUT("/drafts", handlers.SaveDraftHandler)
+		protected.DELETE("/drafts", handlers.DiscardDraftHandler)
+		protected.GET("/presence/:title", access.Require(policy, access.RoleViewer, handlers.PagePathFromRequest), handlers.PresenceHandler)
+		protected.POST("/presence/:title", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.HeartbeatHandler)
+		protected.DELETE("/presence/:title", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.LeavePresenceHandler)
+
+		// Comment routes; editing and deleting is further limited to the author
+		protected.GET("/comments/:title", access.Require(policy, access.RoleViewer, handlers.PagePathFromRequest), handlers.ListCommentsHandler)
+		protected.POST("/comments/:title", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.AddCommentHandler)
+		protected.PUT("/comments/:title/:id", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.EditCommentHandler)
+		protected.DELETE("/comments/:title/:id", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.DeleteCommentHandler)
+		protected.POST("/comments/:title/:id/resolve", access.Require(policy, access.RoleAdmin, handlers.PagePathFromRequest), handlers.ResolveCommentHandler)
+		protected.GET("/collab/:title", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.CollabHandler)
+		protected.POST("/delete/:title", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.DeleteHandler)
 
 		// Category routes
 		protected.POST("/category/create", handlers.CategoryCreateHandler)
-		protected.GET("/category/*path", handlers.CategoryHandler)
-		protected.GET("/api/folders/children/*path", handlers.GetFolderChildrenHandler)
-		protected.DELETE("/api/folder/delete", handlers.DeleteFolderHandler)
+		protected.DELETE("/api/folder/delete", access.Require(policy, access.RoleAdmin, handlers.QueryPathFromRequest), handlers.DeleteFolderHandler)
 
 		// Sync route
-		protected.POST("/api/sync", handlers.HandleSync)
+		protected.POST("/api/sync", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.HandleSync)
+
+		// Personal access token routes
+		protected.GET("/settings/tokens", handlers.TokensPageHandler)
+		protected.POST("/settings/tokens", handlers.CreateTokenHandler)
+		protected.DELETE("/settings/tokens/:id", handlers.RevokeTokenHandler)
+
+		// Local account routes
+		protected.GET("/settings/password", handlers.PasswordPageHandler)
+		protected.POST("/settings/password", handlers.ChangePasswordHandler)
+
+		// Session routes
+		protected.POST("/session/keepalive", auth.KeepAliveHandler)
+		protected.GET("/settings/sessions", handlers.SessionsPageHandler)
+		protected.DELETE("/settings/sessions/:id", handlers.RevokeSessionHandler)
+		protected.POST("/logout/everywhere", handlers.LogoutEverywhereHandler)
+
+		// Share link routes; revoking is further limited to the creator and admins
+		protected.POST("/shares/:title", access.Require(policy, access.RoleEditor, handlers.PagePathFromRequest), handlers.CreateShareHandler)
+		protected.DELETE("/shares/:id", handlers.RevokeShareHandler)
+
+		// Recent changes routes
+		protected.GET("/recent", access.Require(policy, access.RoleViewer, handlers.FolderQueryFromRequest), handlers.RecentChangesHandler)
+		protected.POST("/recent/feed-token", handlers.ResetFeedTokenHandler)
+
+		// Favorite routes
+		protected.GET("/favorites", handlers.FavoritesHandler)
+		protected.POST("/favorites", handlers.AddFavoriteHandler)
+		protected.DELETE("/favorites", handlers.RemoveFavoriteHandler)
+
+		// Watch routes
+		protected.GET("/settings/watches", handlers.WatchesPageHandler)
+		protected.PUT("/settings/watches/digest", handlers.DigestHandler)
+		protected.POST("/watches", handlers.WatchHandler)
+		protected.DELETE("/watches", handlers.UnwatchHandler)
+
+		// Trash routes
+		protected.GET("/trash", handlers.TrashPageHandler)
+		protected.POST("/trash/:id/restore", handlers.RestoreTrashHandler)
+		protected.DELETE("/trash/:id", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.PurgeTrashHandler)
+
+		// Admin routes
+		protected.GET("/admin/audit", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.AuditPageHandler)
+		protected.GET("/admin/analytics", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.AnalyticsPageHandler)
+		protected.GET("/admin/sessions", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.AdminSessionsPageHandler)
+		protected.DELETE("/admin/sessions", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.AdminRevokeUserSessionsHandler)
+		protected.DELETE("/admin/sessions/:id", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.AdminRevokeSessionHandler)
+		protected.GET("/admin/shares", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.SharesPageHandler)
+
+		// Webhook routes
+		protected.GET("/admin/webhooks", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.WebhooksPageHandler)
+		protected.POST("/admin/webhooks", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.CreateWebhookHandler)
+		protected.PUT("/admin/webhooks/:id", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.UpdateWebhookHandler)
+		protected.DELETE("/admin/webhooks/:id", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.DeleteWebhookHandler)
+		protected.POST("/admin/webhooks/:id/test", access.Require(policy, access.RoleAdmin, handlers.RootPath), handlers.TestWebhookHandler)
+	}
+
+	// Versioned JSON API (session or personal access token)
+	api := router.Group("/api/v1")
+	api.Use(auth.APIAuthRequired(tokens), auth.RequireMethodScope(), apiLimit)
+	{
+		api.GET("/pages", handlers.APIListPagesHandler)
+		api.POST("/pages", handlers.APICreatePageHandler)
+		api.GET("/pages/*path", access.Require(policy, access.RoleViewer, handlers.WildcardPathFromRequest), handlers.APIGetPageHandler)
+		api.PUT("/pages/*path", access.Require(policy, access.RoleEditor, handlers.WildcardPathFromRequest), handlers.APIUpdatePageHandler)
+		api.PATCH("/pages/*path", access.Require(policy, access.RoleEditor, handlers.WildcardPathFromRequest), handlers.APIMovePageHandler)
+		api.DELETE("/pages/*path", access.Require(policy, access.RoleEditor, handlers.WildcardPathFromRequest), handlers.APIDeletePageHandler)
+
+		api.GET("/folders", handlers.APIListFoldersHandler)
+		api.GET("/folders/tree", handlers.APIFolderTreeHandler)
+		api.POST("/folders", handlers.APICreateFolderHandler)
+		api.DELETE("/folders/*path", auth.RequireScope(auth.ScopeAdmin), access.Require(policy, access.RoleAdmin, handlers.WildcardPathFromRequest), handlers.APIDeleteFolderHandler)
 	}
 
 	// Start server
@@ -0,0 +1,137 @@
+// Command users manages the wiki's local accounts:
+//
+//	go run ./cmd/users list
+//	go run ./cmd/users add alice@example.com "Alice Example"
+//	go run ./cmd/users reset alice@example.com
+//	go run ./cmd/users remove alice@example.com
+//
+// Passwords are prompted for, or read from the first line of standard input
+// when it isn't a terminal. The running wiki picks up changes on the next
+// sign-in attempt.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"golang.org/x/term"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: users [-file path] <command> [arguments]
+
+Commands:
+  list                  list local accounts
+  add <email> [name]    create an account
+  reset <email>         set a new password
+  remove <email>        delete an account
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	file := flag.String("file", "", "account file (default: auth.local.file from env.yaml)")
+	flag.Usage = usage
+	flag.Parse()
+
+	// Only show the command's own output, not the packages' logging
+	log.SetOutput(io.Discard)
+
+	if *file == "" {
+		if err := config.LoadConfig(); err != nil {
+			fatalf("%v (use -file to point at the account file directly)", err)
+		}
+		*file = config.GetConfig().Auth.Local.File
+	}
+	accounts, err := auth.NewAccountStore(*file)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		list, err := accounts.List()
+		if err != nil {
+			fatalf("%v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "EMAIL\tNAME\tCREATED\tPASSWORD CHANGED")
+		for _, account := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", account.Email, account.Name,
+				account.CreatedAt.Format("2006-01-02 15:04"), account.UpdatedAt.Format("2006-01-02 15:04"))
+		}
+		w.Flush()
+	case args[0] == "add" && (len(args) == 2 || len(args) == 3):
+		name := ""
+		if len(args) == 3 {
+			name = args[2]
+		}
+		if err := accounts.Add(args[1], name, readPassword()); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("Added %s\n", args[1])
+	case args[0] == "reset" && len(args) == 2:
+		if err := accounts.SetPassword(args[1], readPassword()); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("Reset the password of %s\n", args[1])
+	case args[0] == "remove" && len(args) == 2:
+		if err := accounts.Remove(args[1]); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("Removed %s\n", args[1])
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+// readPassword prompts for a new password twice on a terminal, or reads one
+// line from standard input otherwise
+func readPassword() string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fatalf("failed to read password: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n")
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fatalf("failed to read password: %v", err)
+	}
+	fmt.Fprint(os.Stderr, "Confirm password: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fatalf("failed to read password: %v", err)
+	}
+	if string(password) != string(confirm) {
+		fatalf("passwords do not match")
+	}
+	return string(password)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "users: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -2,8 +2,8 @@ package middleware
 
 import (
 	"net/http"
-	"time"
 
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
@@ -12,11 +12,7 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
 		// Update last activity time
-		session.Set("last_activity", time.Now().Unix())
-		session.Options(sessions.Options{
-			MaxAge: 3600, // 1 hour
-		})
-		if err := session.Save(); err != nil {
+		if err := auth.TouchSession(session); err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session"})
 			return
 		}
@@ -26,10 +22,6 @@ func AuthMiddleware() gin.HandlerFunc {
 
 func LogoutHandler(c *gin.Context) {
 	session := sessions.Default(c)
-	session.Clear()
-	session.Options(sessions.Options{
-		MaxAge: -1,
-	})
-	session.Save()
+	auth.EndSession(session)
 	c.Redirect(http.StatusSeeOther, "/")
 }
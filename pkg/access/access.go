@@ -0,0 +1,291 @@
+package access
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Role is a permission level, each one including the ones below it
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleEditor
+	RoleAdmin
+)
+
+// String returns the config name of the role
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleEditor:
+		return "editor"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// ParseRole converts a config role name into a Role
+func ParseRole(name string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "none":
+		return RoleNone, nil
+	case "viewer":
+		return RoleViewer, nil
+	case "editor":
+		return RoleEditor, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", name)
+}
+
+// rule assigns a role to users matching an email pattern
+type rule struct {
+	pattern string
+	role    Role
+}
+
+// folderACL overrides roles for a folder and everything below it
+type folderACL struct {
+	path        string
+	defaultRole Role
+	rules       []rule
+}
+
+// Policy resolves a user's role globally and for individual wiki paths
+type Policy struct {
+	defaultRole Role
+	rules       []rule
+	folders     []folderACL
+	public      []string
+}
+
+// NewPolicy builds a policy from the access section of the configuration
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	defaultRole, err := ParseRole(cfg.Access.DefaultRole)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access.default_role: %v", err)
+	}
+
+	p := &Policy{defaultRole: defaultRole}
+
+	p.rules, err = parseRules(cfg.Access.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access.roles: %v", err)
+	}
+
+	for _, acl := range cfg.Access.Folders {
+		folder := strings.Trim(acl.Path, "/")
+		if folder == "" {
+			return nil, fmt.Errorf("invalid access.folders entry: path is required")
+		}
+		aclDefault, err := ParseRole(acl.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default for folder %s: %v", folder, err)
+		}
+		rules, err := parseRules(acl.Rules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules for folder %s: %v", folder, err)
+		}
+		p.folders = append(p.folders, folderACL{
+			path:        folder,
+			defaultRole: aclDefault,
+			rules:       rules,
+		})
+	}
+
+	for _, folder := range cfg.Access.PublicFolders {
+		folder = strings.Trim(folder, "/")
+		if folder == "" {
+			return nil, fmt.Errorf("invalid access.public_folders entry: publishing the whole wiki is not supported")
+		}
+		p.public = append(p.public, folder)
+	}
+
+	log.Printf("Access policy loaded: default role %s, %d role rules, %d folder ACLs, %d public folders",
+		p.defaultRole, len(p.rules), len(p.folders), len(p.public))
+	return p, nil
+}
+
+// parseRules converts config rules into matchable rules
+func parseRules(entries []config.AccessRule) ([]rule, error) {
+	var rules []rule
+	for _, entry := range entries {
+		role, err := ParseRole(entry.Role)
+		if err != nil {
+			return nil, err
+		}
+		pattern := strings.ToLower(strings.TrimSpace(entry.Match))
+		if strings.HasPrefix(pattern, "@") {
+			pattern = "*" + pattern
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", entry.Match, err)
+		}
+		rules = append(rules, rule{pattern: pattern, role: role})
+	}
+	return rules, nil
+}
+
+// matchRules returns the role of the first rule matching the email
+func matchRules(rules []rule, email string) (Role, bool) {
+	email = strings.ToLower(email)
+	for _, r := range rules {
+		if ok, _ := path.Match(r.pattern, email); ok {
+			return r.role, true
+		}
+	}
+	return RoleNone, false
+}
+
+// GlobalRole returns the user's role outside of any folder override
+func (p *Policy) GlobalRole(email string) Role {
+	if role, ok := matchRules(p.rules, email); ok {
+		return role
+	}
+	return p.defaultRole
+}
+
+// CleanPath checks a wiki path before it is matched against the policy.
+// Storage joins paths onto the data directory, which resolves "." and ".."
+// to a different page than the one checked, so paths with such segments,
+// empty segments or a leading slash are rejected. A trailing slash is dropped.
+func CleanPath(wikiPath string) (string, error) {
+	cleaned := strings.TrimRight(wikiPath, "/")
+	if cleaned == "" && wikiPath == "" {
+		return "", nil
+	}
+	for _, segment := range strings.Split(cleaned, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid path %q", wikiPath)
+		}
+	}
+	return cleaned, nil
+}
+
+// IsPublic reports whether anonymous visitors may read the wiki path
+func (p *Policy) IsPublic(wikiPath string) bool {
+	wikiPath, err := CleanPath(wikiPath)
+	if err != nil {
+		return false
+	}
+	for _, folder := range p.public {
+		if wikiPath == folder || strings.HasPrefix(wikiPath, folder+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleFor returns the user's effective role on a wiki path. The most specific
+// folder ACL covering the path wins; global admins are never restricted.
+// Anonymous visitors, who have no email, can only view public folders. Paths
+// that CleanPath rejects get no role at all.
+func (p *Policy) RoleFor(email, wikiPath string) Role {
+	wikiPath, err := CleanPath(wikiPath)
+	if err != nil {
+		return RoleNone
+	}
+	if email == "" {
+		if p.IsPublic(wikiPath) {
+			return RoleViewer
+		}
+		return RoleNone
+	}
+
+	global := p.GlobalRole(email)
+	if global == RoleAdmin {
+		return RoleAdmin
+	}
+
+	var best *folderACL
+	for i := range p.folders {
+		acl := &p.folders[i]
+		if wikiPath == acl.path || strings.HasPrefix(wikiPath, acl.path+"/") {
+			if best == nil || len(acl.path) > len(best.path) {
+				best = acl
+			}
+		}
+	}
+	if best == nil {
+		return global
+	}
+	if role, ok := matchRules(best.rules, email); ok {
+		return role
+	}
+	return best.defaultRole
+}
+
+// Can reports whether the user holds at least the role on the path
+func (p *Policy) Can(email, wikiPath string, role Role) bool {
+	return p.RoleFor(email, wikiPath) >= role
+}
+
+// userEmail returns the email of the authenticated user in the context
+func userEmail(c *gin.Context) string {
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(auth.User); ok {
+			return user.Email
+		}
+	}
+	return ""
+}
+
+// Require aborts the request unless the user holds the role on the path
+// returned by pathOf. Requests whose path CleanPath rejects fail with a 400.
+func Require(p *Policy, role Role, pathOf func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := userEmail(c)
+		wikiPath, err := CleanPath(pathOf(c))
+		if err != nil {
+			log.Printf("Rejected request from %s: %v", email, err)
+			reject(c, http.StatusBadRequest, "invalid_path", err.Error(), "Invalid page or folder path")
+			return
+		}
+		if p.Can(email, wikiPath, role) {
+			c.Next()
+			return
+		}
+
+		log.Printf("Access denied: %s needs %s on %q", email, role, wikiPath)
+		if email == "" && c.Request.Method == http.MethodGet && !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			// Anonymous visitors outside the public folders need to sign in
+			c.Redirect(http.StatusTemporaryRedirect, "/login")
+			c.Abort()
+			return
+		}
+		reject(c, http.StatusForbidden, "forbidden", fmt.Sprintf("You need the %s role on %q", role, wikiPath),
+			"You don't have permission to access this page")
+	}
+}
+
+// reject aborts in the format the route's clients expect: the API's error
+// envelope, plain JSON for scripts, or the error page for browsers
+func reject(c *gin.Context, status int, code, message, pageMessage string) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/v1/") {
+		auth.AbortAPI(c, status, code, message)
+		return
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") || c.Request.Method != http.MethodGet {
+		c.AbortWithStatusJSON(status, gin.H{
+			"success": false,
+			"error":   message,
+		})
+		return
+	}
+	c.HTML(status, "error.html", gin.H{
+		"error": pageMessage,
+	})
+	c.Abort()
+}
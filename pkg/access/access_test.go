@@ -0,0 +1,156 @@
+package access
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestPolicy gives everyone the editor role, except that the hr folder is
+// limited to hr@example.com
+func newTestPolicy(t *testing.T) *Policy {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Access.DefaultRole = "editor"
+	cfg.Access.Folders = []config.FolderACL{{
+		Path:    "hr",
+		Default: "none",
+		Rules:   []config.AccessRule{{Match: "hr@example.com", Role: "editor"}},
+	}}
+	p, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	return p
+}
+
+func TestCleanPath(t *testing.T) {
+	valid := map[string]string{
+		"":           "",
+		"page":       "page",
+		"a/b/page":   "a/b/page",
+		"a/b/":       "a/b",
+		"notes..txt": "notes..txt",
+	}
+	for input, want := range valid {
+		got, err := CleanPath(input)
+		if err != nil || got != want {
+			t.Errorf("CleanPath(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"/", "/hr/secret", "..", "x/../hr/secret", "./hr", "hr/./secret", "a//b", "hr/.."} {
+		if got, err := CleanPath(input); err == nil {
+			t.Errorf("CleanPath(%q) = %q, want an error", input, got)
+		}
+	}
+}
+
+func TestRoleForFolderACL(t *testing.T) {
+	p := newTestPolicy(t)
+
+	if got := p.RoleFor("alice@example.com", "hr/secret"); got != RoleNone {
+		t.Errorf("alice on hr/secret = %s, want none", got)
+	}
+	if got := p.RoleFor("hr@example.com", "hr/secret"); got != RoleEditor {
+		t.Errorf("hr on hr/secret = %s, want editor", got)
+	}
+	if got := p.RoleFor("alice@example.com", "x/page"); got != RoleEditor {
+		t.Errorf("alice on x/page = %s, want editor", got)
+	}
+
+	// Storage would resolve these to hr/secret, so they must not be judged
+	// by the ACL of the folder they seem to be in
+	for _, wikiPath := range []string{"x/../hr/secret", "/hr/secret", "./hr/secret"} {
+		if got := p.RoleFor("alice@example.com", wikiPath); got != RoleNone {
+			t.Errorf("alice on %q = %s, want none", wikiPath, got)
+		}
+	}
+}
+
+func TestRequireRejectsTraversal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := newTestPolicy(t)
+
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("error.html").Parse("{{.error}}")))
+	pathOf := func(c *gin.Context) string {
+		return c.Query("folder") + "/" + c.Param("title")
+	}
+	router.POST("/save/:title", func(c *gin.Context) {
+		c.Set("user", auth.User{Email: "alice@example.com", Name: "Alice"})
+	}, Require(p, RoleEditor, pathOf), func(c *gin.Context) {
+		c.String(http.StatusOK, "saved")
+	})
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/save/page?folder=x", http.StatusOK},
+		{"/save/secret?folder=hr", http.StatusForbidden},
+		{"/save/secret?folder=x/../hr", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.url, nil))
+		if w.Code != tt.want {
+			t.Errorf("POST %s = %d, want %d", tt.url, w.Code, tt.want)
+		}
+	}
+}
+
+func TestPublicFolderTraversal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.Access.DefaultRole = "editor"
+	cfg.Access.PublicFolders = []string{"public"}
+	p, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	if !p.IsPublic("public/intro") {
+		t.Errorf("public/intro is not public")
+	}
+	for _, wikiPath := range []string{"public/../hr/secret", "public/./../hr", "/public/../hr"} {
+		if p.IsPublic(wikiPath) {
+			t.Errorf("%q is public", wikiPath)
+		}
+		if got := p.RoleFor("", wikiPath); got != RoleNone {
+			t.Errorf("anonymous on %q = %s, want none", wikiPath, got)
+		}
+	}
+
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("error.html").Parse("{{.error}}")))
+	pathOf := func(c *gin.Context) string {
+		return c.Query("folder") + "/" + c.Param("title")
+	}
+	// No user in the context: an anonymous visitor
+	router.GET("/view/:title", Require(p, RoleViewer, pathOf), func(c *gin.Context) {
+		c.String(http.StatusOK, "page")
+	})
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/view/intro?folder=public", http.StatusOK},
+		{"/view/secret?folder=hr", http.StatusTemporaryRedirect},
+		{"/view/secret?folder=public/../hr", http.StatusBadRequest},
+		{"/view/secret?folder=public/%2E%2E/hr", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.url, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// dayFormat names the daily buckets views are rolled up into, in UTC
+const dayFormat = "2006-01-02"
+
+// PageStats is how often a page was read over a period
+type PageStats struct {
+	Path    string
+	Views   int64 // Views in the period
+	Viewers int64 // Distinct viewers in the period, estimated in Redis
+	Total   int64 // Views since counting started
+}
+
+// DayStats is how much the whole wiki was read on one day
+type DayStats struct {
+	Date    string
+	Views   int64
+	Viewers int64
+}
+
+// Tracker counts page views. Viewers are only ever kept as hashes, so the
+// counts can't be turned back into who read what.
+type Tracker interface {
+	// RecordView counts one view of a page by a viewer
+	RecordView(path, viewer string) error
+	// TopPages returns the most viewed pages in a folder over the last days,
+	// most viewed first. An empty folder means the whole wiki.
+	TopPages(folder string, days, limit int) ([]PageStats, error)
+	// Daily returns wiki-wide views for each of the last days, oldest first
+	Daily(days int) ([]DayStats, error)
+}
+
+// viewerHash hides who viewed a page. Redis only keeps HyperLogLog registers
+// built from these hashes, which can't be read back at all.
+func viewerHash(viewer string) string {
+	sum := sha256.Sum256([]byte("analytics:" + strings.ToLower(viewer)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// lastDays returns the names of the last n daily buckets, oldest first
+func lastDays(n int) []string {
+	if n <= 0 {
+		n = 1
+	}
+	now := time.Now().UTC()
+	days := make([]string, n)
+	for i := range days {
+		days[i] = now.AddDate(0, 0, i-n+1).Format(dayFormat)
+	}
+	return days
+}
+
+// inFolder reports whether a page lies somewhere under the folder
+func inFolder(path, folder string) bool {
+	return folder == "" || strings.HasPrefix(path, folder+"/")
+}
+
+// sortTop orders pages by views and cuts the list to limit
+func sortTop(stats []PageStats, limit int) []PageStats {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Views != stats[j].Views {
+			return stats[i].Views > stats[j].Views
+		}
+		return stats[i].Path < stats[j].Path
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats
+}
+
+// MemoryTracker keeps counts in process memory. It is used when Redis is not
+// available, so counts start over on restart.
+type MemoryTracker struct {
+	mu        sync.Mutex
+	retention int
+	views     map[string]map[string]int64           // day -> page -> views
+	viewers   map[string]map[string]map[string]bool // day -> page -> viewer hashes
+	total     map[string]int64
+}
+
+// NewMemoryTracker creates an in-memory tracker keeping retention days
+func NewMemoryTracker(retention int) *MemoryTracker {
+	log.Printf("Counting page views in memory")
+	return &MemoryTracker{
+		retention: retention,
+		views:     make(map[string]map[string]int64),
+		viewers:   make(map[string]map[string]map[string]bool),
+		total:     make(map[string]int64),
+	}
+}
+
+// RecordView counts one view of a page
+func (t *MemoryTracker) RecordView(path, viewer string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	day := time.Now().UTC().Format(dayFormat)
+	if t.views[day] == nil {
+		t.views[day] = make(map[string]int64)
+		t.viewers[day] = make(map[string]map[string]bool)
+		t.prune()
+	}
+	t.views[day][path]++
+	if t.viewers[day][path] == nil {
+		t.viewers[day][path] = make(map[string]bool)
+	}
+	t.viewers[day][path][viewerHash(viewer)] = true
+	t.total[path]++
+	return nil
+}
+
+// prune drops days past the retention. The caller must hold the lock.
+func (t *MemoryTracker) prune() {
+	keep := make(map[string]bool)
+	for _, day := range lastDays(t.retention) {
+		keep[day] = true
+	}
+	for day := range t.views {
+		if !keep[day] {
+			delete(t.views, day)
+			delete(t.viewers, day)
+		}
+	}
+}
+
+// TopPages returns the most viewed pages in a folder over the last days
+func (t *MemoryTracker) TopPages(folder string, days, limit int) ([]PageStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	byPath := make(map[string]*PageStats)
+	viewers := make(map[string]map[string]bool)
+	for _, day := range lastDays(days) {
+		for path, views := range t.views[day] {
+			if !inFolder(path, folder) {
+				continue
+			}
+			stats := byPath[path]
+			if stats == nil {
+				stats = &PageStats{Path: path, Total: t.total[path]}
+				byPath[path] = stats
+				viewers[path] = make(map[string]bool)
+			}
+			stats.Views += views
+			for hash := range t.viewers[day][path] {
+				viewers[path][hash] = true
+			}
+		}
+	}
+
+	result := make([]PageStats, 0, len(byPath))
+	for path, stats := range byPath {
+		stats.Viewers = int64(len(viewers[path]))
+		result = append(result, *stats)
+	}
+	return sortTop(result, limit), nil
+}
+
+// Daily returns wiki-wide views for each of the last days
+func (t *MemoryTracker) Daily(days int) ([]DayStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var result []DayStats
+	for _, day := range lastDays(days) {
+		stats := DayStats{Date: day}
+		viewers := make(map[string]bool)
+		for path, views := range t.views[day] {
+			stats.Views += views
+			for hash := range t.viewers[day][path] {
+				viewers[hash] = true
+			}
+		}
+		stats.Viewers = int64(len(viewers))
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
+// RedisTracker keeps counts in Redis so they are shared between instances and
+// survive restarts. Each day has a sorted set of page views and HyperLogLogs
+// of viewers; daily keys expire after the retention.
+type RedisTracker struct {
+	client    *redis.Client
+	retention time.Duration
+}
+
+// NewRedisTracker creates a tracker backed by Redis keeping retention days
+func NewRedisTracker(client *redis.Client, retention int) (*RedisTracker, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	log.Printf("Counting page views in Redis")
+	return &RedisTracker{client: client, retention: time.Duration(retention+1) * 24 * time.Hour}, nil
+}
+
+// Redis keys used by the tracker
+const (
+	totalKey = "analytics:total"
+)
+
+func viewsKey(day string) string {
+	return "analytics:views:" + day
+}
+
+func viewersKey(day, path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return "analytics:viewers:" + day + ":" + hex.EncodeToString(sum[:16])
+}
+
+func siteViewersKey(day string) string {
+	return "analytics:viewers:" + day
+}
+
+// RecordView counts one view of a page
+func (t *RedisTracker) RecordView(path, viewer string) error {
+	ctx := context.Background()
+	day := time.Now().UTC().Format(dayFormat)
+	hash := viewerHash(viewer)
+
+	pipe := t.client.TxPipeline()
+	pipe.ZIncrBy(ctx, viewsKey(day), 1, path)
+	pipe.Expire(ctx, viewsKey(day), t.retention)
+	pipe.PFAdd(ctx, viewersKey(day, path), hash)
+	pipe.Expire(ctx, viewersKey(day, path), t.retention)
+	pipe.PFAdd(ctx, siteViewersKey(day), hash)
+	pipe.Expire(ctx, siteViewersKey(day), t.retention)
+	pipe.ZIncrBy(ctx, totalKey, 1, path)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record view: %v", err)
+	}
+	return nil
+}
+
+// TopPages returns the most viewed pages in a folder over the last days
+func (t *RedisTracker) TopPages(folder string, days, limit int) ([]PageStats, error) {
+	ctx := context.Background()
+	period := lastDays(days)
+
+	byPath := make(map[string]*PageStats)
+	for _, day := range period {
+		entries, err := t.client.ZRangeWithScores(ctx, viewsKey(day), 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read views: %v", err)
+		}
+		for _, entry := range entries {
+			path, _ := entry.Member.(string)
+			if !inFolder(path, folder) {
+				continue
+			}
+			if byPath[path] == nil {
+				byPath[path] = &PageStats{Path: path}
+			}
+			byPath[path].Views += int64(entry.Score)
+		}
+	}
+
+	result := make([]PageStats, 0, len(byPath))
+	for _, stats := range byPath {
+		result = append(result, *stats)
+	}
+	result = sortTop(result, limit)
+
+	// Only look up viewers and totals for the pages that made the cut
+	for i := range result {
+		keys := make([]string, len(period))
+		for j, day := range period {
+			keys[j] = viewersKey(day, result[i].Path)
+		}
+		viewers, err := t.client.PFCount(ctx, keys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count viewers: %v", err)
+		}
+		total, err := t.client.ZScore(ctx, totalKey, result[i].Path).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to read total views: %v", err)
+		}
+		result[i].Viewers = viewers
+		result[i].Total = int64(total)
+	}
+	return result, nil
+}
+
+// Daily returns wiki-wide views for each of the last days
+func (t *RedisTracker) Daily(days int) ([]DayStats, error) {
+	ctx := context.Background()
+
+	var result []DayStats
+	for _, day := range lastDays(days) {
+		stats := DayStats{Date: day}
+		entries, err := t.client.ZRangeWithScores(ctx, viewsKey(day), 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read views: %v", err)
+		}
+		for _, entry := range entries {
+			stats.Views += int64(entry.Score)
+		}
+		stats.Viewers, err = t.client.PFCount(ctx, siteViewersKey(day)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count viewers: %v", err)
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
@@ -0,0 +1,296 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// currentFile is the name of the file new entries are appended to
+	currentFile = "audit.jsonl"
+
+	// redisKey is the Redis list that mirrors the most recent entries
+	redisKey = "audit:log"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionPageCreate   = "page.create"
+	ActionPageUpdate   = "page.update"
+	ActionPageDelete   = "page.delete"
+	ActionPageMove     = "page.move"
+	ActionFolderCreate = "folder.create"
+	ActionFolderDelete = "folder.delete"
+	ActionTrashRestore = "trash.restore"
+	ActionTrashPurge   = "trash.purge"
+	ActionSync         = "sync"
+	ActionShareCreate  = "share.create"
+	ActionShareRevoke  = "share.revoke"
+)
+
+// Entry is a single audit record
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	ActorName  string    `json:"actor_name,omitempty"`
+	Action     string    `json:"action"`
+	Path       string    `json:"path,omitempty"`
+	OldPath    string    `json:"old_path,omitempty"`
+	BeforeHash string    `json:"before_hash,omitempty"`
+	AfterHash  string    `json:"after_hash,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Via        string    `json:"via,omitempty"`
+}
+
+// Filter narrows down the entries returned by Query
+type Filter struct {
+	Actor  string
+	Action string
+	Path   string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// underPath reports whether an entry's path is the filter path itself or
+// inside it, so "hr" matches "hr/policy" but not "hrx/policy"
+func underPath(entryPath, filter string) bool {
+	filter = strings.Trim(filter, "/")
+	return entryPath == filter || strings.HasPrefix(entryPath, filter+"/")
+}
+
+// matches reports whether the entry passes the filter
+func (f Filter) matches(e *Entry) bool {
+	if f.Actor != "" && !strings.Contains(strings.ToLower(e.Actor), strings.ToLower(f.Actor)) {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.Path != "" && !underPath(e.Path, f.Path) && !underPath(e.OldPath, f.Path) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Logger appends audit entries to a size-rotated JSONL file and optionally
+// mirrors them to a capped Redis list
+type Logger struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+
+	redis       *redis.Client
+	redisLength int64
+}
+
+// Options configures a Logger
+type Options struct {
+	Dir         string
+	MaxSizeMB   int
+	MaxFiles    int
+	RedisClient *redis.Client
+	RedisLength int
+}
+
+// NewLogger opens (or creates) the audit log in the given directory
+func NewLogger(opts Options) (*Logger, error) {
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %v", err)
+	}
+
+	l := &Logger{
+		dir:         opts.Dir,
+		maxBytes:    int64(opts.MaxSizeMB) * 1024 * 1024,
+		maxFiles:    opts.MaxFiles,
+		redis:       opts.RedisClient,
+		redisLength: int64(opts.RedisLength),
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Audit log writing to %s (rotate at %d MB, keep %d files, redis mirror: %v)",
+		filepath.Join(opts.Dir, currentFile), opts.MaxSizeMB, opts.MaxFiles, l.redis != nil)
+	return l, nil
+}
+
+// open opens the current log file for appending. The caller must hold the lock.
+func (l *Logger) open() error {
+	f, err := os.OpenFile(filepath.Join(l.dir, currentFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat audit log: %v", err)
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file with a timestamp and prunes old files.
+// The caller must hold the lock.
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		log.Printf("Warning: failed to close audit log before rotation: %v", err)
+	}
+
+	rotated := filepath.Join(l.dir, fmt.Sprintf("audit-%s.jsonl", time.Now().UTC().Format("20060102-150405.000")))
+	if err := os.Rename(filepath.Join(l.dir, currentFile), rotated); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %v", err)
+	}
+	log.Printf("Rotated audit log to %s", rotated)
+
+	if l.maxFiles > 0 {
+		files := l.rotatedFiles()
+		for len(files) > l.maxFiles {
+			if err := os.Remove(files[len(files)-1]); err != nil {
+				log.Printf("Warning: failed to remove old audit log %s: %v", files[len(files)-1], err)
+			}
+			files = files[:len(files)-1]
+		}
+	}
+
+	return l.open()
+}
+
+// rotatedFiles returns rotated log files, newest first
+func (l *Logger) rotatedFiles() []string {
+	files, err := filepath.Glob(filepath.Join(l.dir, "audit-*.jsonl"))
+	if err != nil {
+		return nil
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files
+}
+
+// Record appends an entry to the log. Failures are logged rather than
+// returned so that auditing never blocks a user's change.
+func (l *Logger) Record(entry Entry) {
+	if l == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error marshalling audit entry: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	if l.maxBytes > 0 && l.size+int64(len(data))+1 > l.maxBytes && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			log.Printf("Error rotating audit log: %v", err)
+		}
+	}
+	n, err := l.file.Write(append(data, '\n'))
+	l.size += int64(n)
+	l.mu.Unlock()
+	if err != nil {
+		log.Printf("Error writing audit entry: %v", err)
+	}
+
+	if l.redis != nil {
+		ctx := context.Background()
+		pipe := l.redis.TxPipeline()
+		pipe.LPush(ctx, redisKey, data)
+		if l.redisLength > 0 {
+			pipe.LTrim(ctx, redisKey, 0, l.redisLength-1)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Error mirroring audit entry to Redis: %v", err)
+		}
+	}
+
+	log.Printf("AUDIT %s %s %s", entry.Actor, entry.Action, entry.Path)
+}
+
+// Query returns entries matching the filter, newest first
+func (l *Logger) Query(filter Filter) ([]Entry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 200
+	}
+
+	l.mu.Lock()
+	files := append([]string{filepath.Join(l.dir, currentFile)}, l.rotatedFiles()...)
+	l.mu.Unlock()
+
+	var results []Entry
+	for _, path := range files {
+		entries, err := readEntries(path)
+		if err != nil {
+			return nil, err
+		}
+		// Files are append-only, so walk each one backwards for newest first
+		for i := len(entries) - 1; i >= 0; i-- {
+			if filter.matches(&entries[i]) {
+				results = append(results, entries[i])
+				if len(results) >= filter.Limit {
+					return results, nil
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// readEntries parses every entry of a JSONL file
+func readEntries(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("Skipping malformed audit line in %s: %v", path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return entries, nil
+}
+
+// Close closes the underlying log file
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,24 @@
+package audit
+
+import "testing"
+
+func TestFilterPath(t *testing.T) {
+	tests := []struct {
+		path, oldPath string
+		want          bool
+	}{
+		{"hr", "", true},
+		{"hr/policy", "", true},
+		{"hr/team/onboarding", "", true},
+		{"hrx/policy", "", false},
+		{"hr-archive", "", false},
+		{"docs/policy", "hr/policy", true},
+		{"docs/policy", "hrx/policy", false},
+	}
+	f := Filter{Path: "hr"}
+	for _, tt := range tests {
+		if got := f.matches(&Entry{Path: tt.path, OldPath: tt.oldPath}); got != tt.want {
+			t.Errorf("path filter %q on %q (from %q) = %v, want %v", f.Path, tt.path, tt.oldPath, got, tt.want)
+		}
+	}
+}
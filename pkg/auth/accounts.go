@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MinPasswordLength is the shortest password accepted for local accounts
+	MinPasswordLength = 10
+	// maxPasswordLength is bcrypt's input limit in bytes
+	maxPasswordLength = 72
+)
+
+// Account is a local user account. Only the bcrypt hash of the password is stored.
+type Account struct {
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AccountStore persists local accounts in a JSON file. The admin CLI edits
+// the same file, so it is reloaded whenever it changes on disk.
+type AccountStore struct {
+	mu       sync.Mutex
+	path     string
+	modTime  time.Time
+	accounts map[string]*Account
+}
+
+// dummyHash is compared against when an account doesn't exist so that
+// unknown emails take as long to reject as wrong passwords
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// NewAccountStore loads the account file, creating an empty store if it doesn't exist
+func NewAccountStore(path string) (*AccountStore, error) {
+	s := &AccountStore{path: path, accounts: make(map[string]*Account)}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %d local accounts", len(s.accounts))
+	return s, nil
+}
+
+// accountKey normalises an email for lookups
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// reload rereads the file when it changed since it was last read. The caller
+// must hold the lock, except in NewAccountStore.
+func (s *AccountStore) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.accounts = make(map[string]*Account)
+			s.modTime = time.Time{}
+			return nil
+		}
+		return fmt.Errorf("failed to read account file: %v", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read account file: %v", err)
+	}
+	var list []*Account
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("failed to parse account file: %v", err)
+		}
+	}
+	accounts := make(map[string]*Account, len(list))
+	for _, account := range list {
+		accounts[accountKey(account.Email)] = account
+	}
+	s.accounts = accounts
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// save writes the accounts to disk. The caller must hold the lock.
+func (s *AccountStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create account directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal accounts: %v", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write account file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace account file: %v", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// list returns the accounts sorted by email. The caller must hold the lock.
+func (s *AccountStore) list() []*Account {
+	list := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		list = append(list, account)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Email < list[j].Email
+	})
+	return list
+}
+
+// hashPassword checks a new password and returns its bcrypt hash
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return "", fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
+
+// List returns all accounts without their password hashes
+func (s *AccountStore) List() ([]Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	var list []Account
+	for _, account := range s.list() {
+		copy := *account
+		copy.PasswordHash = ""
+		list = append(list, copy)
+	}
+	return list, nil
+}
+
+// Add creates an account
+func (s *AccountStore) Add(email, name, password string) error {
+	email = strings.TrimSpace(email)
+	if !strings.Contains(email, "@") {
+		return fmt.Errorf("invalid email address %q", email)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if _, exists := s.accounts[accountKey(email)]; exists {
+		return fmt.Errorf("account %s already exists", email)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = strings.Split(email, "@")[0]
+	}
+	now := time.Now().UTC()
+	s.accounts[accountKey(email)] = &Account{
+		Email:        email,
+		Name:         name,
+		PasswordHash: hash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	return s.save()
+}
+
+// Remove deletes an account
+func (s *AccountStore) Remove(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if _, exists := s.accounts[accountKey(email)]; !exists {
+		return fmt.Errorf("account %s not found", email)
+	}
+	delete(s.accounts, accountKey(email))
+	return s.save()
+}
+
+// SetPassword replaces an account's password
+func (s *AccountStore) SetPassword(email, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	account, exists := s.accounts[accountKey(email)]
+	if !exists {
+		return fmt.Errorf("account %s not found", email)
+	}
+	account.PasswordHash = hash
+	account.UpdatedAt = time.Now().UTC()
+	return s.save()
+}
+
+// Authenticate checks an email and password and returns the account
+func (s *AccountStore) Authenticate(email, password string) (*Account, error) {
+	s.mu.Lock()
+	if err := s.reload(); err != nil {
+		log.Printf("Error reloading accounts: %v", err)
+	}
+	account, exists := s.accounts[accountKey(email)]
+	var found Account
+	if exists {
+		found = *account
+	}
+	s.mu.Unlock()
+
+	hash := dummyHash
+	if exists {
+		hash = []byte(found.PasswordHash)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !exists {
+		return nil, fmt.Errorf("invalid email or password")
+	}
+	found.PasswordHash = ""
+	return &found, nil
+}
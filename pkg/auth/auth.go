@@ -3,42 +3,105 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
+// providerIDPattern limits provider IDs to what fits in a URL path segment
+var providerIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
 type Handler struct {
-	oauthConfig *oauth2.Config
-	cfg         *config.Config
+	cfg       *config.Config
+	providers []Provider
+	policy    *LoginPolicy
+	tokens    *TokenStore
+
+	// Local accounts, nil unless enabled
+	accounts     *AccountStore
+	emailLimiter *LoginLimiter
+	ipLimiter    *LoginLimiter
+}
+
+// NewHandler sets up the configured login providers: local accounts, Google,
+// GitHub and any number of OpenID Connect providers. The login policy also
+// governs the personal access tokens in the token store.
+func NewHandler(cfg *config.Config, tokens *TokenStore) (*Handler, error) {
+	policy, err := NewLoginPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Watch(); err != nil {
+		return nil, err
+	}
+
+	tokens.UsePolicy(policy)
+
+	h := &Handler{cfg: cfg, policy: policy, tokens: tokens}
+	if cfg.Auth.Local.Enabled {
+		accounts, err := NewAccountStore(cfg.Auth.Local.File)
+		if err != nil {
+			return nil, err
+		}
+		lockout := time.Duration(cfg.Auth.Local.LockoutMinutes) * time.Minute
+		h.accounts = accounts
+		// Anyone can fail on someone else's email, so an account only slows
+		// down; a client address that keeps guessing is locked out instead.
+		// Offices share addresses, so allow more attempts per client address.
+		h.emailLimiter = NewBackoffLimiter(cfg.Auth.Local.MaxAttempts, lockout)
+		h.ipLimiter = NewLoginLimiter(cfg.Auth.Local.MaxAttempts*4, lockout)
+	}
+	if cfg.Google.ClientID != "" {
+		h.providers = append(h.providers, newGoogleProvider(cfg))
+	}
+	if cfg.Auth.GitHub.ClientID != "" {
+		h.providers = append(h.providers, newGitHubProvider(cfg))
+	}
+	for _, provider := range cfg.Auth.OIDC {
+		if !providerIDPattern.MatchString(provider.ID) {
+			return nil, fmt.Errorf("invalid OpenID Connect provider id %q", provider.ID)
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("OpenID Connect provider %q needs an issuer and client_id", provider.ID)
+		}
+		if h.Provider(provider.ID) != nil {
+			return nil, fmt.Errorf("duplicate login provider id %q", provider.ID)
+		}
+		h.providers = append(h.providers, newOIDCProvider(cfg, provider))
+	}
+	if len(h.providers) == 0 && h.accounts == nil {
+		log.Printf("Warning: No login providers are configured")
+	}
+	return h, nil
 }
 
-func NewHandler(cfg *config.Config) *Handler {
-	return &Handler{
-		cfg: cfg,
-		oauthConfig: &oauth2.Config{
-			ClientID:     cfg.Google.ClientID,
-			ClientSecret: cfg.Google.ClientSecret,
-			RedirectURL:  cfg.Google.RedirectURL,
-			Scopes: []string{
-				"https://www.googleapis.com/auth/userinfo.email",
-				"https://www.googleapis.com/auth/userinfo.profile",
-			},
-			Endpoint: google.Endpoint,
-		},
+// Provider returns the provider with the given ID, or nil
+func (h *Handler) Provider(id string) Provider {
+	for _, provider := range h.providers {
+		if provider.ID() == id {
+			return provider
+		}
 	}
+	return nil
 }
 
-func InitGoogleOAuth(cfg *config.Config) {
-	// Initialize the OAuth config
-	_ = NewHandler(cfg)
+// Providers describes the configured providers for the login page
+func (h *Handler) Providers() []ProviderInfo {
+	infos := make([]ProviderInfo, 0, len(h.providers))
+	for _, provider := range h.providers {
+		infos = append(infos, ProviderInfo{
+			ID:   provider.ID(),
+			Name: provider.Name(),
+			Icon: provider.Icon(),
+		})
+	}
+	return infos
 }
 
 // GenerateRandomState generates a random state string for OAuth
@@ -48,8 +111,11 @@ func GenerateRandomState() string {
 	return base64.URLEncoding.EncodeToString(b)
 }
 
-func (h *Handler) getOAuthConfig() *oauth2.Config {
-	return h.oauthConfig
+// loginFailed sends the browser back to the login page with an error
+func loginFailed(c *gin.Context, session sessions.Session, message string) {
+	session.Set("error", message)
+	session.Save()
+	c.Redirect(http.StatusSeeOther, "/login")
 }
 
 func (h *Handler) Login(c *gin.Context) {
@@ -62,16 +128,28 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	// Get the OAuth2 config
-	oauthConfig := h.getOAuthConfig()
+	provider := h.Provider(c.Param("provider"))
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
 
-	// Generate random state
+	// Generate random state, and a nonce tying the ID token to this login
 	state := GenerateRandomState()
+	nonce := GenerateRandomState()
+	url, err := provider.AuthCodeURL(c, state, nonce)
+	if err != nil {
+		log.Printf("Error starting %s login: %v", provider.ID(), err)
+		loginFailed(c, session, fmt.Sprintf("Sign-in with %s is unavailable right now", provider.Name()))
+		return
+	}
 	session.Set("oauth_state", state)
+	session.Set("oauth_nonce", nonce)
+	session.Set("oauth_provider", provider.ID())
+	session.Set("oauth_remember", c.Query("remember") == "1")
 	session.Save()
 
-	// Redirect to Google's consent page
-	url := oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	// Redirect to the provider's consent page
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
@@ -81,65 +159,49 @@ func (h *Handler) Callback(c *gin.Context) {
 
 	// Verify state
 	state := session.Get("oauth_state")
-	if state == nil || state != c.Query("state") {
+	provider := h.Provider(c.Param("provider"))
+	if state == nil || state != c.Query("state") || provider == nil || session.Get("oauth_provider") != provider.ID() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
 		return
 	}
 
 	// Clear the state after verification
+	nonce, _ := session.Get("oauth_nonce").(string)
+	remember, _ := session.Get("oauth_remember").(bool)
 	session.Delete("oauth_state")
+	session.Delete("oauth_nonce")
+	session.Delete("oauth_provider")
+	session.Delete("oauth_remember")
 	session.Save()
 
-	// Get the OAuth2 config
-	oauthConfig := h.getOAuthConfig()
-
-	// Exchange code for token
-	code := c.Query("code")
-	token, err := oauthConfig.Exchange(c, code)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange token"})
+	if reason := c.Query("error"); reason != "" {
+		log.Printf("%s login was not completed: %s", provider.ID(), reason)
+		loginFailed(c, session, fmt.Sprintf("Sign-in with %s was cancelled", provider.Name()))
 		return
 	}
 
-	// Get user info
-	client := oauthConfig.Client(c, token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	// Exchange the code for the signed-in user
+	identity, err := provider.Exchange(c, c.Query("code"), nonce)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+		log.Printf("Error completing %s login: %v", provider.ID(), err)
+		loginFailed(c, session, fmt.Sprintf("Sign-in with %s failed", provider.Name()))
 		return
 	}
-	defer resp.Body.Close()
-
-	var userInfo struct {
-		ID            string `json:"id"`
-		Email         string `json:"email"`
-		VerifiedEmail bool   `json:"verified_email"`
-		Name          string `json:"name"`
-		Picture       string `json:"picture"`
-	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode user info"})
-		return
-	}
+	h.signIn(c, session, identity, provider.ID(), remember)
+}
 
-	// Check if email is allowed
-	allowedEmails := h.cfg.Session.AllowedEmails
-	isAllowed := len(allowedEmails) == 0 // If no emails are specified, allow all
-	if !isAllowed {
-		for _, email := range allowedEmails {
-			if email == userInfo.Email {
-				isAllowed = true
-				break
-			}
+// signIn starts a session for a user who proved who they are
+func (h *Handler) signIn(c *gin.Context, session sessions.Session, identity *Identity, providerID string, remember bool) {
+	// Check the login policy
+	allowed, reason := h.policy.Check(identity)
+	if !allowed {
+		log.Printf("Login denied for %s via %s: %s", identity.Email, providerID, reason)
+		// Tokens minted while the user was allowed must not outlive their access
+		if _, err := h.tokens.RevokeUser(identity.Email); err != nil {
+			log.Printf("Error revoking tokens of denied user %s: %v", identity.Email, err)
 		}
-	}
-
-	if !isAllowed {
-		// Set error message in session
-		session.Set("error", "Your email is not authorized to access this application")
-		session.Save()
-		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		loginFailed(c, session, "Your email is not authorized to access this application")
 		return
 	}
 
@@ -147,10 +209,12 @@ func (h *Handler) Callback(c *gin.Context) {
 	session.Clear()
 
 	// Set new session data
-	session.Set("user_email", userInfo.Email)
-	session.Set("user_name", userInfo.Name)
-	session.Set("user_picture", userInfo.Picture)
-	session.Set("last_activity", time.Now().Unix()) // Store as Unix timestamp
+	session.Set("user_email", identity.Email)
+	session.Set("user_name", identity.Name)
+	session.Set("user_picture", identity.Picture)
+	session.Set("user_provider", providerID)
+	StartSession(session, remember)
+	newCSRFToken(c, session)
 
 	// Save the session
 	if err := session.Save(); err != nil {
@@ -159,5 +223,6 @@ func (h *Handler) Callback(c *gin.Context) {
 		return
 	}
 
-	c.Redirect(http.StatusTemporaryRedirect, "/")
+	log.Printf("%s signed in with %s, %s", identity.Email, providerID, reason)
+	c.Redirect(http.StatusSeeOther, "/")
 }
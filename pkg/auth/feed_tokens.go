@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// feedTokenPrefix marks tokens that can only read change feeds
+const feedTokenPrefix = "wiki_feed_"
+
+// FeedToken lets a feed reader fetch a user's change feeds without a session.
+// It only ever grants read access to feeds, so the secret is kept as is and
+// can be shown again in the subscribe URL.
+type FeedToken struct {
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FeedTokenStore persists one feed token per user in a JSON file
+type FeedTokenStore struct {
+	mu     sync.Mutex
+	path   string
+	tokens []*FeedToken
+}
+
+// NewFeedTokenStore loads the feed token file, creating an empty store if it
+// doesn't exist
+func NewFeedTokenStore(path string) (*FeedTokenStore, error) {
+	s := &FeedTokenStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read feed token file: %v", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.tokens); err != nil {
+			return nil, fmt.Errorf("failed to parse feed token file: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// save writes the tokens to disk. The caller must hold the lock.
+func (s *FeedTokenStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create feed token directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal feed tokens: %v", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write feed token file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace feed token file: %v", err)
+	}
+	return nil
+}
+
+// Get returns the user's feed token, creating one on first use
+func (s *FeedTokenStore) Get(user User) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if strings.EqualFold(t.Email, user.Email) {
+			return t.Secret, nil
+		}
+	}
+	return s.create(user)
+}
+
+// Reset replaces the user's feed token so that old feed URLs stop working
+func (s *FeedTokenStore) Reset(user User) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.tokens[:0]
+	for _, t := range s.tokens {
+		if !strings.EqualFold(t.Email, user.Email) {
+			kept = append(kept, t)
+		}
+	}
+	s.tokens = kept
+
+	secret, err := s.create(user)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Reset feed token for %s", user.Email)
+	return secret, nil
+}
+
+// create mints a token for the user. The caller must hold the lock.
+func (s *FeedTokenStore) create(user User) (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate feed token: %v", err)
+	}
+
+	token := &FeedToken{
+		Email:     user.Email,
+		Name:      user.Name,
+		Secret:    feedTokenPrefix + base64.RawURLEncoding.EncodeToString(raw),
+		CreatedAt: time.Now(),
+	}
+	s.tokens = append(s.tokens, token)
+	if err := s.save(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return "", err
+	}
+	return token.Secret, nil
+}
+
+// Authenticate returns the user a feed token belongs to
+func (s *FeedTokenStore) Authenticate(secret string) (User, error) {
+	if !strings.HasPrefix(secret, feedTokenPrefix) {
+		return User{}, fmt.Errorf("malformed feed token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Secret), []byte(secret)) == 1 {
+			return User{Email: t.Email, Name: t.Name}, nil
+		}
+	}
+	return User{}, fmt.Errorf("invalid feed token")
+}
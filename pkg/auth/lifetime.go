@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// Session values that track how long a session lives
+const (
+	sessionCreatedKey  = "created_at"
+	sessionActivityKey = "last_activity"
+	sessionRememberKey = "remember"
+)
+
+// Lifetime holds the session timeouts
+type Lifetime struct {
+	// Idle signs users out after this long without a request
+	Idle time.Duration
+	// Absolute signs users out this long after they signed in
+	Absolute time.Duration
+	// Remember is how long a "remember me" session lasts, without an idle
+	// timeout. Zero disables remember me.
+	Remember time.Duration
+	// Warn is how long before expiry the UI warns about it
+	Warn time.Duration
+}
+
+// SessionLifetime returns the configured session timeouts
+func SessionLifetime() Lifetime {
+	cfg := config.GetConfig().Session
+	return Lifetime{
+		Idle:     time.Duration(cfg.IdleMinutes) * time.Minute,
+		Absolute: time.Duration(cfg.MaxAgeHours) * time.Hour,
+		Remember: time.Duration(cfg.RememberDays) * 24 * time.Hour,
+		Warn:     time.Duration(cfg.WarnMinutes) * time.Minute,
+	}
+}
+
+// CookieMaxAge returns how long the browser keeps the session cookie, in
+// seconds
+func (l Lifetime) CookieMaxAge(remember bool) int {
+	if remember && l.Remember > 0 {
+		return int(l.Remember.Seconds())
+	}
+	return int(l.Absolute.Seconds())
+}
+
+// Expiry returns when a session ends and whether activity can push that back
+func (l Lifetime) Expiry(session sessions.Session) (time.Time, bool) {
+	lastActivity := sessionTime(session, sessionActivityKey)
+	created := sessionTime(session, sessionCreatedKey)
+	if created.IsZero() {
+		// Sessions from before absolute timeouts start counting now
+		created = lastActivity
+	}
+
+	if remembered(session) && l.Remember > 0 {
+		return created.Add(l.Remember), false
+	}
+	idleEnd := lastActivity.Add(l.Idle)
+	absoluteEnd := created.Add(l.Absolute)
+	if idleEnd.Before(absoluteEnd) {
+		return idleEnd, true
+	}
+	return absoluteEnd, false
+}
+
+// sessionTime reads a Unix timestamp stored in the session
+func sessionTime(session sessions.Session, key string) time.Time {
+	if value, ok := session.Get(key).(int64); ok {
+		return time.Unix(value, 0)
+	}
+	return time.Time{}
+}
+
+// remembered reports whether the user asked to be remembered when signing in
+func remembered(session sessions.Session) bool {
+	remember, _ := session.Get(sessionRememberKey).(bool)
+	return remember
+}
+
+// CookieOptions returns the session cookie options for a max age in seconds
+func CookieOptions(maxAge int) sessions.Options {
+	return sessions.Options{
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   config.GetConfig().Session.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// StartSession stamps a freshly signed-in session with its start time
+func StartSession(session sessions.Session, remember bool) {
+	now := time.Now().Unix()
+	session.Set(sessionCreatedKey, now)
+	session.Set(sessionActivityKey, now)
+	session.Set(sessionRememberKey, remember)
+	session.Options(CookieOptions(SessionLifetime().CookieMaxAge(remember)))
+}
+
+// TouchSession records activity on a session and saves it
+func TouchSession(session sessions.Session) error {
+	now := time.Now().Unix()
+	if _, ok := session.Get(sessionCreatedKey).(int64); !ok {
+		session.Set(sessionCreatedKey, now)
+	}
+	session.Set(sessionActivityKey, now)
+	session.Options(CookieOptions(SessionLifetime().CookieMaxAge(remembered(session))))
+	return session.Save()
+}
+
+// EndSession clears a session and expires its cookie
+func EndSession(session sessions.Session) error {
+	session.Clear()
+	session.Options(CookieOptions(-1))
+	return session.Save()
+}
+
+// SessionStatusHandler reports when the current session expires. It reads
+// the session without touching it, so polling it doesn't keep users signed in.
+func SessionStatusHandler(c *gin.Context) {
+	session := sessions.Default(c)
+	if session.Get("user_email") == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"authenticated": false,
+		})
+		return
+	}
+
+	lifetime := SessionLifetime()
+	expiry, extendable := lifetime.Expiry(session)
+	remaining := time.Until(expiry)
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"authenticated": remaining > 0,
+		"expiresIn":     int(remaining.Seconds()),
+		"extendable":    extendable,
+		"warnBefore":    int(lifetime.Warn.Seconds()),
+	})
+}
+
+// KeepAliveHandler extends the session; AuthRequired has already recorded
+// the activity by the time it runs
+func KeepAliveHandler(c *gin.Context) {
+	SessionStatusHandler(c)
+}
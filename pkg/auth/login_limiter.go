@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginLimiter locks out an email or client address after too many failed
+// sign-in attempts
+type LoginLimiter struct {
+	mu          sync.Mutex
+	maxAttempts int
+	lockout     time.Duration
+	// backoff makes each failure past maxAttempts lock the key for twice as
+	// long as the one before, up to lockout, instead of for lockout at once
+	backoff  bool
+	failures map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count       int
+	first       time.Time
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// backoffBase is the delay after the first failure past the free attempts
+const backoffBase = time.Second
+
+// NewLoginLimiter allows maxAttempts failures per key within the lockout
+// period, then refuses that key for the lockout period
+func NewLoginLimiter(maxAttempts int, lockout time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+		failures:    make(map[string]*loginFailures),
+	}
+}
+
+// NewBackoffLimiter allows maxAttempts failures per key, then makes the key
+// wait a second after the next failure, doubling with each further failure up
+// to maxDelay. Failures are forgotten after maxDelay without any. Unlike a
+// hard lockout, somebody guessing can't keep the rightful user out for long.
+func NewBackoffLimiter(maxAttempts int, maxDelay time.Duration) *LoginLimiter {
+	l := NewLoginLimiter(maxAttempts, maxDelay)
+	l.backoff = true
+	return l
+}
+
+// Locked returns how long the keys are still locked out, or zero when none is
+func (l *LoginLimiter) Locked(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var wait time.Duration
+	for _, key := range keys {
+		if f, ok := l.failures[key]; ok {
+			if remaining := time.Until(f.lockedUntil); remaining > wait {
+				wait = remaining
+			}
+		}
+	}
+	return wait
+}
+
+// Fail records a failed attempt for each key
+func (l *LoginLimiter) Fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok || l.expired(f, now) {
+			f = &loginFailures{first: now}
+			l.failures[key] = f
+		}
+		f.count++
+		f.last = now
+		if over := f.count - l.maxAttempts; over >= 0 {
+			f.lockedUntil = now.Add(l.delay(over))
+		}
+	}
+}
+
+// delay returns how long a key is locked after its over-th failure past the
+// allowed attempts
+func (l *LoginLimiter) delay(over int) time.Duration {
+	if !l.backoff {
+		return l.lockout
+	}
+	if over >= 30 {
+		return l.lockout
+	}
+	return min(backoffBase<<over, l.lockout)
+}
+
+// expired reports whether a key's failures no longer count
+func (l *LoginLimiter) expired(f *loginFailures, now time.Time) bool {
+	since := f.first
+	if l.backoff {
+		since = f.last
+	}
+	return now.Sub(since) > l.lockout && now.After(f.lockedUntil)
+}
+
+// Reset forgets the failures of the keys after a successful sign-in
+func (l *LoginLimiter) Reset(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+// prune drops failures that no longer count. The caller must hold the lock.
+func (l *LoginLimiter) prune(now time.Time) {
+	for key, f := range l.failures {
+		if l.expired(f, now) {
+			delete(l.failures, key)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffLimiterDoublesDelay(t *testing.T) {
+	l := NewBackoffLimiter(3, 5*time.Second)
+
+	for i := 0; i < 2; i++ {
+		l.Fail("alice")
+	}
+	if wait := l.Locked("alice"); wait != 0 {
+		t.Fatalf("locked for %v before using up the free attempts", wait)
+	}
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		l.Fail("alice")
+		if wait := l.Locked("alice"); wait <= want-100*time.Millisecond || wait > want {
+			t.Errorf("locked for %v, want about %v", wait, want)
+		}
+	}
+
+	if wait := l.Locked("bob"); wait != 0 {
+		t.Errorf("bob locked for %v by alice's failures", wait)
+	}
+	l.Reset("alice")
+	if wait := l.Locked("alice"); wait != 0 {
+		t.Errorf("locked for %v after a reset", wait)
+	}
+}
+
+func TestLoginLimiterLocksOut(t *testing.T) {
+	l := NewLoginLimiter(2, time.Minute)
+
+	l.Fail("10.0.0.1")
+	if wait := l.Locked("10.0.0.1"); wait != 0 {
+		t.Fatalf("locked for %v after one failure", wait)
+	}
+	l.Fail("10.0.0.1")
+	if wait := l.Locked("10.0.0.1"); wait <= 59*time.Second {
+		t.Errorf("locked for %v, want the full minute", wait)
+	}
+}
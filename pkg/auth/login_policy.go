@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// loginRule is one allow or deny entry of the login policy
+type loginRule struct {
+	entry  string // as written, for log messages
+	source string // where the entry came from
+	kind   string // "email", "hd" or "group"
+	value  string
+}
+
+// parseLoginRule parses an entry: an email pattern such as
+// "alice@example.com", "*@example.com" or "*@*.example.com", "hd:example.com"
+// for a Google Workspace domain, or "group:wiki-users" for a groups claim
+func parseLoginRule(entry, source string) (loginRule, error) {
+	value := strings.ToLower(strings.TrimSpace(entry))
+	rule := loginRule{entry: strings.TrimSpace(entry), source: source, kind: "email"}
+	switch {
+	case strings.HasPrefix(value, "hd:"):
+		rule.kind, value = "hd", strings.TrimSpace(strings.TrimPrefix(value, "hd:"))
+	case strings.HasPrefix(value, "group:"):
+		rule.kind = "group"
+		// Group names keep their case
+		value = strings.TrimSpace(strings.TrimSpace(entry)[len("group:"):])
+	case strings.HasPrefix(value, "@"):
+		value = "*" + value
+	}
+	if value == "" {
+		return rule, fmt.Errorf("empty entry %q", entry)
+	}
+	if rule.kind == "email" {
+		if _, err := path.Match(value, ""); err != nil {
+			return rule, fmt.Errorf("invalid pattern %q: %v", entry, err)
+		}
+	}
+	rule.value = value
+	return rule, nil
+}
+
+// matches reports whether the rule applies to the identity
+func (r loginRule) matches(identity *Identity, groupsClaim string) bool {
+	switch r.kind {
+	case "hd":
+		return strings.ToLower(claimString(identity.Claims, "hd")) == r.value
+	case "group":
+		switch groups := identity.Claims[groupsClaim].(type) {
+		case string:
+			return groups == r.value
+		case []interface{}:
+			for _, group := range groups {
+				if group == r.value {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		ok, _ := path.Match(r.value, strings.ToLower(identity.Email))
+		return ok
+	}
+}
+
+// LoginPolicy decides who may sign in, from the login section of the config
+// and an optional allowlist file that is reloaded whenever it changes
+type LoginPolicy struct {
+	groupsClaim string
+	filePath    string
+	allow       []loginRule
+	deny        []loginRule
+
+	mu        sync.RWMutex
+	fileAllow []loginRule
+	fileDeny  []loginRule
+}
+
+// NewLoginPolicy builds the login policy and loads the allowlist file
+func NewLoginPolicy(cfg *config.Config) (*LoginPolicy, error) {
+	p := &LoginPolicy{
+		groupsClaim: cfg.Login.GroupsClaim,
+		filePath:    cfg.Login.AllowlistFile,
+	}
+	for _, entry := range cfg.Login.Allow {
+		rule, err := parseLoginRule(entry, "login.allow")
+		if err != nil {
+			return nil, fmt.Errorf("invalid login.allow: %v", err)
+		}
+		p.allow = append(p.allow, rule)
+	}
+	for _, entry := range cfg.Login.Deny {
+		rule, err := parseLoginRule(entry, "login.deny")
+		if err != nil {
+			return nil, fmt.Errorf("invalid login.deny: %v", err)
+		}
+		p.deny = append(p.deny, rule)
+	}
+	if p.filePath != "" {
+		if err := p.Reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.allow) == 0 && p.filePath == "" {
+		log.Printf("Login policy: no allowlist, anyone who can sign in with a provider may log in")
+	} else {
+		log.Printf("Login policy loaded: %d allow and %d deny entries, allowlist file %q", len(p.allow), len(p.deny), p.filePath)
+	}
+	return p, nil
+}
+
+// Reload rereads the allowlist file. Each line is an allow entry, or a deny
+// entry when prefixed with "!"; blank lines and "#" comments are ignored. A
+// missing file allows nobody. The previous entries are kept if the file has
+// errors.
+func (p *LoginPolicy) Reload() error {
+	data, err := os.ReadFile(p.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read allowlist file: %v", err)
+	}
+
+	var allow, deny []loginRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		source := fmt.Sprintf("%s:%d", filepath.Base(p.filePath), n)
+		if strings.HasPrefix(line, "!") {
+			rule, err := parseLoginRule(strings.TrimPrefix(line, "!"), source)
+			if err != nil {
+				return fmt.Errorf("invalid allowlist entry at %s: %v", source, err)
+			}
+			deny = append(deny, rule)
+			continue
+		}
+		rule, err := parseLoginRule(line, source)
+		if err != nil {
+			return fmt.Errorf("invalid allowlist entry at %s: %v", source, err)
+		}
+		allow = append(allow, rule)
+	}
+
+	p.mu.Lock()
+	p.fileAllow, p.fileDeny = allow, deny
+	p.mu.Unlock()
+	log.Printf("Loaded allowlist file %s: %d allow and %d deny entries", p.filePath, len(allow), len(deny))
+	return nil
+}
+
+// Watch reloads the allowlist file whenever it changes
+func (p *LoginPolicy) Watch() error {
+	if p.filePath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p.filePath), 0700); err != nil {
+		return fmt.Errorf("failed to create allowlist directory: %v", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch allowlist file: %v", err)
+	}
+	// Watch the directory so editors that replace the file are noticed too
+	if err := watcher.Add(filepath.Dir(p.filePath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch allowlist file: %v", err)
+	}
+
+	go func() {
+		target := filepath.Clean(p.filePath)
+		var debounce <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == target {
+					debounce = time.After(200 * time.Millisecond)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Error watching allowlist file: %v", err)
+			case <-debounce:
+				debounce = nil
+				if err := p.Reload(); err != nil {
+					log.Printf("Error reloading allowlist file, keeping the previous entries: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Check reports whether the identity may sign in and why
+func (p *LoginPolicy) Check(identity *Identity) (bool, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, rules := range [][]loginRule{p.deny, p.fileDeny} {
+		for _, rule := range rules {
+			if rule.matches(identity, p.groupsClaim) {
+				return false, fmt.Sprintf("matches deny entry %q (%s)", rule.entry, rule.source)
+			}
+		}
+	}
+	if len(p.allow) == 0 && p.filePath == "" {
+		return true, "no allowlist configured"
+	}
+	for _, rules := range [][]loginRule{p.allow, p.fileAllow} {
+		for _, rule := range rules {
+			if rule.matches(identity, p.groupsClaim) {
+				return true, fmt.Sprintf("matches allow entry %q (%s)", rule.entry, rule.source)
+			}
+		}
+	}
+	return false, "not on the allowlist"
+}
+
+// CheckEmail reports whether a user known only by email, such as the owner of
+// an access token, may still sign in. Domain entries are matched against the
+// email's domain. Group entries need the provider's claims, so they are only
+// checked at sign-in and never deny here.
+func (p *LoginPolicy) CheckEmail(email string) (bool, string) {
+	identity := &Identity{Email: email, Claims: map[string]interface{}{}}
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		identity.Claims["hd"] = email[at+1:]
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, rules := range [][]loginRule{p.deny, p.fileDeny} {
+		for _, rule := range rules {
+			if rule.kind != "group" && rule.matches(identity, p.groupsClaim) {
+				return false, fmt.Sprintf("matches deny entry %q (%s)", rule.entry, rule.source)
+			}
+		}
+	}
+	if len(p.allow) == 0 && p.filePath == "" {
+		return true, "no allowlist configured"
+	}
+	for _, rules := range [][]loginRule{p.allow, p.fileAllow} {
+		for _, rule := range rules {
+			if rule.kind == "group" {
+				return true, fmt.Sprintf("group entry %q (%s) is only checked at sign-in", rule.entry, rule.source)
+			}
+			if rule.matches(identity, p.groupsClaim) {
+				return true, fmt.Sprintf("matches allow entry %q (%s)", rule.entry, rule.source)
+			}
+		}
+	}
+	return false, "not on the allowlist"
+}
@@ -3,9 +3,9 @@ package auth
 import (
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
@@ -13,72 +13,199 @@ import (
 type User struct {
 	Email string
 	Name  string
+	// Provider is the login provider of session users, e.g. "google" or "local"
+	Provider string
+}
+
+// GuestName is the display name of anonymous visitors
+const GuestName = "Guest"
+
+// IsGuest reports whether the user is an anonymous visitor
+func (u User) IsGuest() bool {
+	return u.Email == ""
 }
 
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session := sessions.Default(c)
-
-		// Check if session exists and is valid
-		userEmail := session.Get("user_email")
-		userName := session.Get("user_name")
-		lastActivity := session.Get("last_activity")
-
-		log.Printf("Auth check - Email: %v, Name: %v, Last Activity: %v", userEmail, userName, lastActivity)
-
-		if userEmail == nil || userName == nil || lastActivity == nil {
-			log.Printf("Session invalid or expired, redirecting to login")
-			// Clear any existing session data
-			session.Clear()
-			session.Options(sessions.Options{
-				Path:     "/",
-				MaxAge:   -1,
-				HttpOnly: true,
-				Secure:   config.GetConfig().Session.Secure,
-				SameSite: http.SameSiteLaxMode,
-			})
-			if err := session.Save(); err != nil {
-				log.Printf("Error saving session: %v", err)
-			}
-			c.Redirect(http.StatusTemporaryRedirect, "/login")
-			c.Abort()
+		if !authenticateSession(c) {
+			rejectUnauthenticated(c)
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Check if session has expired (1 hour)
-		lastActivityTime := time.Unix(lastActivity.(int64), 0)
-		if time.Since(lastActivityTime) > 3600*time.Second {
-			log.Printf("Session expired, redirecting to login")
-			session.Clear()
-			session.Options(sessions.Options{
-				Path:     "/",
-				MaxAge:   -1,
-				HttpOnly: true,
-				Secure:   config.GetConfig().Session.Secure,
-				SameSite: http.SameSiteLaxMode,
-			})
-			if err := session.Save(); err != nil {
-				log.Printf("Error saving session: %v", err)
-			}
-			c.Redirect(http.StatusTemporaryRedirect, "/login")
-			c.Abort()
-			return
+// authenticateSession checks the session and puts its user in the context.
+// A missing, incomplete or expired session is cleared and reported as false.
+func authenticateSession(c *gin.Context) bool {
+	session := sessions.Default(c)
+
+	// Check if session exists and is valid
+	userEmail := session.Get("user_email")
+	userName := session.Get("user_name")
+	lastActivity := session.Get("last_activity")
+
+	log.Printf("Auth check - Email: %v, Name: %v, Last Activity: %v", userEmail, userName, lastActivity)
+
+	if userEmail == nil || userName == nil || lastActivity == nil {
+		log.Printf("Session invalid or expired")
+		// Clear any existing session data
+		if err := EndSession(session); err != nil {
+			log.Printf("Error saving session: %v", err)
 		}
+		return false
+	}
 
-		// Update last activity time
-		session.Set("last_activity", time.Now().Unix())
-		if err := session.Save(); err != nil {
+	// Check if session has expired, idle or past its maximum age
+	if expiry, _ := SessionLifetime().Expiry(session); !time.Now().Before(expiry) {
+		log.Printf("Session expired")
+		if err := EndSession(session); err != nil {
 			log.Printf("Error saving session: %v", err)
-			c.Redirect(http.StatusTemporaryRedirect, "/login")
-			c.Abort()
+		}
+		return false
+	}
+
+	// Update last activity time
+	if err := TouchSession(session); err != nil {
+		log.Printf("Error saving session: %v", err)
+		return false
+	}
+
+	// Set user info in context
+	provider, _ := session.Get("user_provider").(string)
+	c.Set("user", User{
+		Email:    userEmail.(string),
+		Name:     userName.(string),
+		Provider: provider,
+	})
+	return true
+}
+
+// AuthOptional lets anonymous visitors through as a guest user without an
+// email, leaving what they may see to the access policy. Visitors with a
+// session are checked exactly as by AuthRequired, and continue as a guest
+// once it has expired.
+func AuthOptional() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sessions.Default(c).Get("user_email") == nil || !authenticateSession(c) {
+			c.Set("user", User{Name: GuestName})
+		}
+		c.Next()
+	}
+}
+
+// rejectUnauthenticated aborts the request, answering API clients with a JSON
+// 401 and browsers with a redirect to the login page
+func rejectUnauthenticated(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		AbortAPI(c, http.StatusUnauthorized, "unauthenticated", "Authentication required")
+		return
+	}
+	c.Redirect(http.StatusTemporaryRedirect, "/login")
+	c.Abort()
+}
+
+// APIAuthRequired authenticates API requests with either a personal access
+// token sent as "Authorization: Bearer <token>" or the browser session
+func APIAuthRequired(tokens *TokenStore) gin.HandlerFunc {
+	sessionAuth := AuthRequired()
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			sessionAuth(c)
 			return
 		}
 
-		// Set user info in context
+		secret := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if secret == header || secret == "" {
+			AbortAPI(c, http.StatusUnauthorized, "unauthenticated", "Authorization header must use the Bearer scheme")
+			return
+		}
+
+		token, err := tokens.Authenticate(secret)
+		if err != nil {
+			log.Printf("Token authentication failed: %v", err)
+			AbortAPI(c, http.StatusUnauthorized, "unauthenticated", "Invalid or expired token")
+			return
+		}
+
+		log.Printf("Authenticated %s with token %s (%s)", token.OwnerEmail, token.ID, token.Name)
 		c.Set("user", User{
-			Email: userEmail.(string),
-			Name:  userName.(string),
+			Email: token.OwnerEmail,
+			Name:  token.OwnerName,
 		})
+		c.Set("auth_token", token)
+		c.Next()
+	}
+}
+
+// FeedAuthRequired authenticates feed requests with the token in the "token"
+// query parameter, falling back to the browser session when there is none
+func FeedAuthRequired(tokens *FeedTokenStore) gin.HandlerFunc {
+	sessionAuth := AuthRequired()
+	return func(c *gin.Context) {
+		secret := c.Query("token")
+		if secret == "" {
+			sessionAuth(c)
+			return
+		}
+
+		user, err := tokens.Authenticate(secret)
+		if err != nil {
+			log.Printf("Feed token authentication failed: %v", err)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// RequireScope rejects token-authenticated requests whose token lacks the
+// scope. Session-authenticated users are not restricted by scopes.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tokenAllows(c, scope) {
+			AbortAPI(c, http.StatusForbidden, "insufficient_scope", "Token is missing the '"+scope+"' scope")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireMethodScope requires the read scope for safe methods and the write
+// scope for everything else
+func RequireMethodScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := ScopeWrite
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			scope = ScopeRead
+		}
+		if !tokenAllows(c, scope) {
+			AbortAPI(c, http.StatusForbidden, "insufficient_scope", "Token is missing the '"+scope+"' scope")
+			return
+		}
 		c.Next()
 	}
 }
+
+// tokenAllows reports whether the request's token (if any) grants the scope
+func tokenAllows(c *gin.Context, scope string) bool {
+	value, exists := c.Get("auth_token")
+	if !exists {
+		return true
+	}
+	token, ok := value.(*Token)
+	return ok && token.HasScope(scope)
+}
+
+// AbortAPI aborts with the JSON error envelope used by every v1 API endpoint
+func AbortAPI(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthOptionalFallsBackToGuest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.AppConfig.Session.IdleMinutes = 30
+	config.AppConfig.Session.MaxAgeHours = 24
+
+	router := gin.New()
+	router.Use(sessions.Sessions("wiki_session", cookie.NewStore([]byte("test-secret"))))
+	router.GET("/signin", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set("user_email", "alice@example.com")
+		session.Set("user_name", "Alice")
+		StartSession(session, false)
+		if c.Query("idle") != "" {
+			// Last seen long past the idle timeout
+			session.Set(sessionActivityKey, time.Now().Add(-time.Hour).Unix())
+		}
+		session.Save()
+	})
+	router.GET("/page", AuthOptional(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.MustGet("user").(User).Name)
+	})
+
+	get := func(url string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		signIn string
+		want   string
+	}{
+		{"anonymous", "", GuestName},
+		{"signed in", "/signin", "Alice"},
+		{"expired session", "/signin?idle=1", GuestName},
+	}
+	for _, tt := range tests {
+		var cookies []*http.Cookie
+		if tt.signIn != "" {
+			cookies = get(tt.signIn, nil).Result().Cookies()
+		}
+		w := get("/page", cookies)
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s: GET /page = %d %q, want 200 %q", tt.name, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
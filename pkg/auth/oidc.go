@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider signs users in with any OpenID Connect identity provider.
+// Discovery happens on first use so an unreachable provider doesn't stop the
+// wiki from starting.
+type oidcProvider struct {
+	cfg         config.OIDCProvider
+	redirectURL string
+
+	mu          sync.Mutex
+	provider    *oidc.Provider
+	oauthConfig *oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+}
+
+func newOIDCProvider(cfg *config.Config, provider config.OIDCProvider) *oidcProvider {
+	return &oidcProvider{
+		cfg:         provider,
+		redirectURL: redirectURL(cfg, provider.RedirectURL, provider.ID),
+	}
+}
+
+func (p *oidcProvider) ID() string   { return p.cfg.ID }
+func (p *oidcProvider) Name() string { return p.cfg.Name }
+func (p *oidcProvider) Icon() string { return "fas fa-id-badge" }
+
+// discover fetches the provider's configuration and signing keys location
+func (p *oidcProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover %s: %v", p.cfg.Issuer, err)
+	}
+	log.Printf("Discovered OpenID Connect provider %s at %s", p.cfg.ID, p.cfg.Issuer)
+	p.provider = provider
+	p.oauthConfig = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.redirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint:     provider.Endpoint(),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return nil
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+	return p.oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce)), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := p.oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange token: %v", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	// Checks the signature, issuer, audience and expiry
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("ID token nonce does not match")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token claims: %v", err)
+	}
+
+	// Some providers only put profile claims in the userinfo response
+	if claimString(claims, p.cfg.EmailClaim) == "" || claimString(claims, p.cfg.NameClaim) == "" {
+		if userInfo, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil {
+			var extra map[string]interface{}
+			if err := userInfo.Claims(&extra); err == nil && extra["sub"] == claims["sub"] {
+				for name, value := range extra {
+					if _, exists := claims[name]; !exists {
+						claims[name] = value
+					}
+				}
+			}
+		}
+	}
+
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("email address is not verified")
+	}
+	identity := &Identity{
+		Email:   claimString(claims, p.cfg.EmailClaim),
+		Name:    claimString(claims, p.cfg.NameClaim),
+		Picture: claimString(claims, "picture"),
+		Claims:  claims,
+	}
+	if identity.Email == "" {
+		return nil, fmt.Errorf("ID token has no %q claim", p.cfg.EmailClaim)
+	}
+	if identity.Name == "" {
+		identity.Name = identity.Email
+	}
+	return identity, nil
+}
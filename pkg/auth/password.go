@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// LocalProviderID marks sessions signed in with a local account
+const LocalProviderID = "local"
+
+// PasswordLoginEnabled reports whether local accounts can sign in
+func (h *Handler) PasswordLoginEnabled() bool {
+	return h.accounts != nil
+}
+
+// lockedMessage tells the user how long to wait before trying again
+func lockedMessage(action string, wait time.Duration) string {
+	if wait < time.Minute {
+		return fmt.Sprintf("Too many failed attempts. Try %s again in %d seconds.", action, int(math.Ceil(wait.Seconds())))
+	}
+	return fmt.Sprintf("Too many failed attempts. Try %s again in %d minutes.", action, int(math.Ceil(wait.Minutes())))
+}
+
+// PasswordLogin signs in with a local account from the login form
+func (h *Handler) PasswordLogin(c *gin.Context) {
+	session := sessions.Default(c)
+	if h.accounts == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Password sign-in is not enabled"})
+		return
+	}
+
+	email := strings.TrimSpace(c.PostForm("email"))
+	emailKey := accountKey(email)
+	ip := c.ClientIP()
+	if wait := max(h.emailLimiter.Locked(emailKey), h.ipLimiter.Locked(ip)); wait > 0 {
+		log.Printf("Password sign-in for %s from %s refused, locked out", email, ip)
+		loginFailed(c, session, lockedMessage("signing in", wait))
+		return
+	}
+
+	account, err := h.accounts.Authenticate(email, c.PostForm("password"))
+	if err != nil {
+		log.Printf("Failed password sign-in for %s from %s", email, ip)
+		h.emailLimiter.Fail(emailKey)
+		h.ipLimiter.Fail(ip)
+		loginFailed(c, session, "Invalid email or password")
+		return
+	}
+	h.emailLimiter.Reset(emailKey)
+	h.ipLimiter.Reset(ip)
+
+	h.signIn(c, session, &Identity{
+		Email: account.Email,
+		Name:  account.Name,
+	}, LocalProviderID, c.PostForm("remember") == "1")
+}
+
+// ChangePassword changes the signed-in local user's password after checking
+// their current one
+func (h *Handler) ChangePassword(c *gin.Context) {
+	user, _ := c.Value("user").(User)
+	if h.accounts == nil || user.Provider != LocalProviderID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only local accounts have a password to change"})
+		return
+	}
+
+	var requestBody struct {
+		Current string `json:"current"`
+		New     string `json:"new"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to parse request: %v", err)})
+		return
+	}
+
+	emailKey := accountKey(user.Email)
+	if wait := h.emailLimiter.Locked(emailKey); wait > 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": lockedMessage("changing your password", wait)})
+		return
+	}
+	if _, err := h.accounts.Authenticate(user.Email, requestBody.Current); err != nil {
+		h.emailLimiter.Fail(emailKey)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	h.emailLimiter.Reset(emailKey)
+
+	if err := h.accounts.SetPassword(user.Email, requestBody.New); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("%s changed their password", user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+)
+
+// Identity is the user an identity provider signed in
+type Identity struct {
+	Email   string
+	Name    string
+	Picture string
+	// Claims holds everything the provider said about the user
+	Claims map[string]interface{}
+}
+
+// Provider signs users in through an external identity provider
+type Provider interface {
+	// ID names the provider in its /auth/<id> routes
+	ID() string
+	// Name is shown on the login button
+	Name() string
+	// Icon is the Font Awesome class of the login button
+	Icon() string
+	// AuthCodeURL returns where to send the browser to sign in
+	AuthCodeURL(ctx context.Context, state, nonce string) (string, error)
+	// Exchange trades the callback's code for the signed-in user
+	Exchange(ctx context.Context, code, nonce string) (*Identity, error)
+}
+
+// ProviderInfo describes a provider for the login page
+type ProviderInfo struct {
+	ID   string
+	Name string
+	Icon string
+}
+
+// redirectURL returns the configured callback URL or the default one under
+// the wiki's base URL
+func redirectURL(cfg *config.Config, configured, id string) string {
+	if configured != "" {
+		return configured
+	}
+	return fmt.Sprintf("%s/auth/%s/callback", cfg.Notify.BaseURL, id)
+}
+
+// getJSON fetches a JSON document with an authenticated client
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// googleProvider signs users in with their Google account
+type googleProvider struct {
+	oauthConfig *oauth2.Config
+}
+
+func newGoogleProvider(cfg *config.Config) *googleProvider {
+	return &googleProvider{
+		oauthConfig: &oauth2.Config{
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			RedirectURL:  redirectURL(cfg, cfg.Google.RedirectURL, "google"),
+			Scopes: []string{
+				"https://www.googleapis.com/auth/userinfo.email",
+				"https://www.googleapis.com/auth/userinfo.profile",
+			},
+			Endpoint: google.Endpoint,
+		},
+	}
+}
+
+func (p *googleProvider) ID() string   { return "google" }
+func (p *googleProvider) Name() string { return "Google" }
+func (p *googleProvider) Icon() string { return "fab fa-google" }
+
+func (p *googleProvider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	return p.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline), nil
+}
+
+func (p *googleProvider) Exchange(ctx context.Context, code, nonce string) (*Identity, error) {
+	token, err := p.oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange token: %v", err)
+	}
+
+	var userInfo map[string]interface{}
+	if err := getJSON(p.oauthConfig.Client(ctx, token), "https://www.googleapis.com/oauth2/v2/userinfo", &userInfo); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %v", err)
+	}
+	if verified, ok := userInfo["verified_email"].(bool); ok && !verified {
+		return nil, fmt.Errorf("email address is not verified")
+	}
+	return &Identity{
+		Email:   claimString(userInfo, "email"),
+		Name:    claimString(userInfo, "name"),
+		Picture: claimString(userInfo, "picture"),
+		Claims:  userInfo,
+	}, nil
+}
+
+// githubProvider signs users in with their GitHub account, using their
+// primary verified email address
+type githubProvider struct {
+	oauthConfig *oauth2.Config
+}
+
+func newGitHubProvider(cfg *config.Config) *githubProvider {
+	return &githubProvider{
+		oauthConfig: &oauth2.Config{
+			ClientID:     cfg.Auth.GitHub.ClientID,
+			ClientSecret: cfg.Auth.GitHub.ClientSecret,
+			RedirectURL:  redirectURL(cfg, cfg.Auth.GitHub.RedirectURL, "github"),
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     github.Endpoint,
+		},
+	}
+}
+
+func (p *githubProvider) ID() string   { return "github" }
+func (p *githubProvider) Name() string { return "GitHub" }
+func (p *githubProvider) Icon() string { return "fab fa-github" }
+
+func (p *githubProvider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	return p.oauthConfig.AuthCodeURL(state), nil
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, nonce string) (*Identity, error) {
+	token, err := p.oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange token: %v", err)
+	}
+	client := p.oauthConfig.Client(ctx, token)
+
+	var user map[string]interface{}
+	if err := getJSON(client, "https://api.github.com/user", &user); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %v", err)
+	}
+
+	// The profile email is optional and unverified, so ask for the primary one
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(client, "https://api.github.com/user/emails", &emails); err != nil {
+		return nil, fmt.Errorf("failed to get email addresses: %v", err)
+	}
+	identity := &Identity{
+		Name:    claimString(user, "name"),
+		Picture: claimString(user, "avatar_url"),
+		Claims:  user,
+	}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			identity.Email = email.Email
+		}
+	}
+	if identity.Email == "" {
+		return nil, fmt.Errorf("GitHub account has no verified primary email address")
+	}
+	if identity.Name == "" {
+		identity.Name = claimString(user, "login")
+	}
+	return identity, nil
+}
+
+// claimString reads a string claim, returning "" when it is missing
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
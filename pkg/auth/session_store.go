@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// defaultSessionTTL is how long a server-side session lives when the cookie
+// has no max age
+const defaultSessionTTL = 24 * time.Hour
+
+// SessionInfo describes a signed-in browser session
+type SessionInfo struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// SessionManager lists and revokes server-side sessions
+type SessionManager interface {
+	// List returns the signed-in sessions of a user, or of everyone when
+	// email is empty, most recently active first
+	List(email string) ([]SessionInfo, error)
+	// Revoke ends one session
+	Revoke(id string) error
+	// RevokeUser ends every session of a user and returns how many there were
+	RevokeUser(email string) (int, error)
+}
+
+// sessionRecord is what is kept in Redis for a session
+type sessionRecord struct {
+	SessionInfo
+	Values []byte `json:"values"`
+}
+
+// RedisSessionStore keeps session values in Redis. The cookie only carries a
+// signed, opaque session ID, so sessions can be listed and revoked.
+type RedisSessionStore struct {
+	client  *redis.Client
+	codecs  []securecookie.Codec
+	options *gsessions.Options
+}
+
+// NewRedisSessionStore creates a session store backed by Redis. The secret
+// signs the session ID cookie.
+func NewRedisSessionStore(client *redis.Client, secret []byte) (*RedisSessionStore, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	log.Printf("Storing sessions in Redis")
+	return &RedisSessionStore{
+		client:  client,
+		codecs:  securecookie.CodecsFromPairs(secret),
+		options: &gsessions.Options{Path: "/", MaxAge: int(defaultSessionTTL.Seconds())},
+	}, nil
+}
+
+// Redis keys used by the session store
+const sessionIndexKey = "session:index"
+
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+func userSessionsKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "session:user:" + hex.EncodeToString(sum[:16])
+}
+
+// Options sets the cookie options of new sessions
+func (s *RedisSessionStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+// Get returns the session for the request, cached for the rest of it
+func (s *RedisSessionStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request's cookie, or starts an empty
+// one when there is no cookie or the session was revoked or expired
+func (s *RedisSessionStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.codecs...); err != nil {
+		return session, nil
+	}
+	record, err := s.load(id)
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error loading session: %v", err)
+		}
+		return session, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(record.Values)).Decode(&session.Values); err != nil {
+		log.Printf("Error decoding session: %v", err)
+		return session, nil
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+// load reads a session record from Redis
+func (s *RedisSessionStore) load(id string) (*sessionRecord, error) {
+	data, err := s.client.Get(context.Background(), sessionKey(id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var record sessionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse session: %v", err)
+	}
+	return &record, nil
+}
+
+// Save writes the session to Redis and sets the cookie. A negative max age
+// deletes the session, as on logout.
+func (s *RedisSessionStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Revoke(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	email, _ := session.Values["user_email"].(string)
+	name, _ := session.Values["user_name"].(string)
+	now := time.Now().UTC()
+	info := SessionInfo{
+		Email:        email,
+		Name:         name,
+		IP:           requestIP(r),
+		UserAgent:    r.UserAgent(),
+		CreatedAt:    now,
+		LastActivity: now,
+	}
+
+	// Keep the ID while the same user stays signed in. Signing in gets a
+	// fresh ID so a session ID planted before login is worthless after it.
+	if session.ID != "" {
+		previous, err := s.load(session.ID)
+		if err == nil && previous.Email == email {
+			info.CreatedAt = previous.CreatedAt
+		} else {
+			if err == nil {
+				s.Revoke(session.ID)
+			}
+			session.ID = ""
+		}
+	}
+	if session.ID == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return fmt.Errorf("failed to generate session id: %v", err)
+		}
+		session.ID = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	info.ID = session.ID
+
+	var values bytes.Buffer
+	if err := gob.NewEncoder(&values).Encode(session.Values); err != nil {
+		return fmt.Errorf("failed to encode session: %v", err)
+	}
+	data, err := json.Marshal(sessionRecord{SessionInfo: info, Values: values.Bytes()})
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %v", err)
+	}
+
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	ctx := context.Background()
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, sessionKey(session.ID), data, ttl)
+	if email != "" {
+		pipe.SAdd(ctx, userSessionsKey(email), session.ID)
+		pipe.Expire(ctx, userSessionsKey(email), ttl)
+		pipe.ZAdd(ctx, sessionIndexKey, &redis.Z{Score: float64(now.Unix()), Member: session.ID})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save session: %v", err)
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return fmt.Errorf("failed to sign session cookie: %v", err)
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// requestIP returns the client address, preferring the proxy headers gin
+// trusts by default
+func requestIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if real := r.Header.Get("X-Real-IP"); real != "" {
+		return strings.TrimSpace(real)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// List returns signed-in sessions, dropping index entries of expired ones
+func (s *RedisSessionStore) List(email string) ([]SessionInfo, error) {
+	ctx := context.Background()
+	var ids []string
+	var err error
+	if email == "" {
+		ids, err = s.client.ZRange(ctx, sessionIndexKey, 0, -1).Result()
+	} else {
+		ids, err = s.client.SMembers(ctx, userSessionsKey(email)).Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	list := []SessionInfo{}
+	for _, id := range ids {
+		record, err := s.load(id)
+		if err != nil {
+			if err == redis.Nil {
+				s.client.ZRem(ctx, sessionIndexKey, id)
+				if email != "" {
+					s.client.SRem(ctx, userSessionsKey(email), id)
+				}
+			}
+			continue
+		}
+		if record.Email == "" {
+			continue
+		}
+		list = append(list, record.SessionInfo)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastActivity.After(list[j].LastActivity)
+	})
+	return list, nil
+}
+
+// Revoke ends one session
+func (s *RedisSessionStore) Revoke(id string) error {
+	ctx := context.Background()
+	record, err := s.load(id)
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, sessionKey(id))
+	pipe.ZRem(ctx, sessionIndexKey, id)
+	if record != nil && record.Email != "" {
+		pipe.SRem(ctx, userSessionsKey(record.Email), id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	return nil
+}
+
+// RevokeUser ends every session of a user
+func (s *RedisSessionStore) RevokeUser(email string) (int, error) {
+	ctx := context.Background()
+	ids, err := s.client.SMembers(ctx, userSessionsKey(email)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	for _, id := range ids {
+		pipe.Del(ctx, sessionKey(id))
+		pipe.ZRem(ctx, sessionIndexKey, id)
+	}
+	pipe.Del(ctx, userSessionsKey(email))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	log.Printf("Revoked %d sessions of %s", len(ids), email)
+	return len(ids), nil
+}
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Token scopes, each one implying the ones below it
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+
+	// tokenPrefix makes wiki tokens easy to recognise in logs and secret scanners
+	tokenPrefix = "wiki_pat_"
+)
+
+// scopeRank orders scopes so that a higher scope grants the lower ones
+var scopeRank = map[string]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// ValidScope reports whether the scope name is known
+func ValidScope(scope string) bool {
+	_, ok := scopeRank[scope]
+	return ok
+}
+
+// Token is a personal access token. Only the SHA-256 hash of the secret is stored.
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	OwnerEmail string     `json:"owner_email"`
+	OwnerName  string     `json:"owner_name"`
+	Hash       string     `json:"hash"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the token grants the given scope
+func (t *Token) HasScope(scope string) bool {
+	want := scopeRank[scope]
+	for _, s := range t.Scopes {
+		if scopeRank[s] >= want {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the token is past its expiry date
+func (t *Token) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// TokenStore persists personal access tokens in a JSON file
+type TokenStore struct {
+	mu     sync.Mutex
+	path   string
+	tokens []*Token
+	// policy, when set, rejects tokens of users who may no longer sign in
+	policy *LoginPolicy
+}
+
+// NewTokenStore loads the token file, creating an empty store if it doesn't exist
+func NewTokenStore(path string) (*TokenStore, error) {
+	s := &TokenStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("No token file at %s, starting with an empty token store", path)
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read token file: %v", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.tokens); err != nil {
+			return nil, fmt.Errorf("failed to parse token file: %v", err)
+		}
+	}
+	log.Printf("Loaded %d personal access tokens", len(s.tokens))
+	return s, nil
+}
+
+// save writes the tokens to disk. The caller must hold the lock.
+func (s *TokenStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create token directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tokens: %v", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace token file: %v", err)
+	}
+	return nil
+}
+
+// UsePolicy makes Authenticate reject the tokens of users the login policy
+// no longer lets sign in
+func (s *TokenStore) UsePolicy(policy *LoginPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// hashToken returns the hex encoded SHA-256 of a token secret
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create mints a new token for the user. The plaintext secret is returned once
+// and never stored.
+func (s *TokenStore) Create(owner User, name string, scopes []string, expiresAt *time.Time) (string, *Token, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return "", nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return "", nil, fmt.Errorf("expiry must be in the future")
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token id: %v", err)
+	}
+
+	token := &Token{
+		ID:         hex.EncodeToString(id),
+		Name:       name,
+		OwnerEmail: owner.Email,
+		OwnerName:  owner.Name,
+		Hash:       hashToken(secret),
+		Hint:       secret[len(secret)-4:],
+		Scopes:     scopes,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, token)
+	if err := s.save(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return "", nil, err
+	}
+
+	log.Printf("Created personal access token %s (%s) for %s with scopes %v", token.ID, token.Name, owner.Email, scopes)
+	return secret, token, nil
+}
+
+// List returns the tokens owned by the given email, newest first
+func (s *TokenStore) List(email string) []Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Token
+	for _, t := range s.tokens {
+		if t.OwnerEmail == email {
+			result = append(result, *t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Revoke deletes a token owned by the given email
+func (s *TokenStore) Revoke(email, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, t := range s.tokens {
+		if t.ID == id && t.OwnerEmail == email {
+			s.tokens = append(s.tokens[:i], s.tokens[i+1:]...)
+			if err := s.save(); err != nil {
+				return err
+			}
+			log.Printf("Revoked personal access token %s (%s) for %s", t.ID, t.Name, email)
+			return nil
+		}
+	}
+	return fmt.Errorf("token not found")
+}
+
+// RevokeUser deletes every token owned by the given email and returns how
+// many there were
+func (s *TokenStore) RevokeUser(email string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []*Token
+	for _, t := range s.tokens {
+		if !strings.EqualFold(t.OwnerEmail, email) {
+			kept = append(kept, t)
+		}
+	}
+	revoked := len(s.tokens) - len(kept)
+	if revoked == 0 {
+		return 0, nil
+	}
+	previous := s.tokens
+	s.tokens = kept
+	if err := s.save(); err != nil {
+		s.tokens = previous
+		return 0, err
+	}
+	log.Printf("Revoked %d personal access tokens of %s", revoked, email)
+	return revoked, nil
+}
+
+// Authenticate looks up the token matching the secret and records its use
+func (s *TokenStore) Authenticate(secret string) (*Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, fmt.Errorf("malformed token")
+	}
+	hash := hashToken(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) == 1 {
+			if t.Expired() {
+				return nil, fmt.Errorf("token expired")
+			}
+			if s.policy != nil {
+				if allowed, reason := s.policy.CheckEmail(t.OwnerEmail); !allowed {
+					return nil, fmt.Errorf("owner %s may no longer sign in: %s", t.OwnerEmail, reason)
+				}
+			}
+			// Only persist last-used once a minute to avoid rewriting the file on every request
+			now := time.Now()
+			if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > time.Minute {
+				t.LastUsedAt = &now
+				if err := s.save(); err != nil {
+					log.Printf("Warning: failed to record token use: %v", err)
+				}
+			}
+			token := *t
+			return &token, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid token")
+}
@@ -0,0 +1,107 @@
+package changes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+)
+
+// AtomFeed describes an Atom document built from a list of changes
+type AtomFeed struct {
+	Title   string
+	ID      string
+	SelfURL string
+	SiteURL string
+
+	// Link returns the absolute URL an entry points to
+	Link func(change types.Change) string
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Updated string     `xml:"updated"`
+	Link    atomLink   `xml:"link"`
+	Author  atomAuthor `xml:"author"`
+	Summary string     `xml:"summary,omitempty"`
+}
+
+type atomAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+// Title describes a change in a few words, e.g. "Moved docs/a to docs/b"
+func Title(change types.Change) string {
+	kind := ""
+	if change.Folder {
+		kind = "folder "
+	}
+	switch change.Action {
+	case types.ChangeCreated:
+		return fmt.Sprintf("Created %s%s", kind, change.Path)
+	case types.ChangeDeleted:
+		return fmt.Sprintf("Deleted %s%s", kind, change.Path)
+	case types.ChangeMoved:
+		return fmt.Sprintf("Moved %s%s to %s", kind, change.OldPath, change.Path)
+	default:
+		return fmt.Sprintf("Edited %s%s", kind, change.Path)
+	}
+}
+
+// Atom renders changes as an Atom feed
+func Atom(feed AtomFeed, changes []types.Change) ([]byte, error) {
+	updated := time.Now().UTC()
+	if len(changes) > 0 {
+		updated = changes[0].Time.UTC()
+	}
+
+	doc := atomFeed{
+		Title:   feed.Title,
+		ID:      feed.ID,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: feed.SelfURL},
+			{Href: feed.SiteURL},
+		},
+	}
+	for _, change := range changes {
+		author := atomAuthor{Name: change.AuthorName, Email: change.AuthorEmail}
+		if author.Name == "" {
+			author.Name = change.AuthorEmail
+		}
+		if author.Name == "" {
+			author.Name = "Unknown"
+		}
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:   Title(change),
+			ID:      feed.ID + "#" + change.ID,
+			Updated: change.Time.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: feed.Link(change)},
+			Author:  author,
+			Summary: change.Message,
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode feed: %v", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
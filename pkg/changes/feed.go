@@ -0,0 +1,87 @@
+package changes
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+)
+
+// Sources a list of changes can come from
+const (
+	SourceHistory = "history"
+	SourceJournal = "journal"
+)
+
+// Feed lists recent changes from the storage's own history, such as GitHub
+// commits, and falls back to the local journal when that isn't available.
+// History lookups are cached because each commit costs an API call.
+type Feed struct {
+	history types.ChangeLister
+	journal *Journal
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedChanges
+}
+
+type cachedChanges struct {
+	changes []types.Change
+	limit   int
+	at      time.Time
+}
+
+// NewFeed creates a feed. history may be nil when the storage keeps none.
+func NewFeed(history types.ChangeLister, journal *Journal, ttl time.Duration) *Feed {
+	return &Feed{
+		history: history,
+		journal: journal,
+		ttl:     ttl,
+		cache:   make(map[string]cachedChanges),
+	}
+}
+
+// Recent returns up to limit changes in a folder, newest first, and which
+// source they came from
+func (f *Feed) Recent(folder string, limit int) ([]types.Change, string) {
+	if f.history != nil {
+		f.mu.Lock()
+		cached, ok := f.cache[folder]
+		f.mu.Unlock()
+		if ok && cached.limit >= limit && time.Since(cached.at) < f.ttl {
+			return truncate(cached.changes, limit), SourceHistory
+		}
+
+		changes, err := f.history.RecentChanges(folder, limit)
+		if err == nil {
+			f.mu.Lock()
+			f.cache[folder] = cachedChanges{changes: changes, limit: limit, at: time.Now()}
+			f.mu.Unlock()
+			return changes, SourceHistory
+		}
+		log.Printf("Warning: change history unavailable, using the local journal: %v", err)
+	}
+
+	changes, _ := f.journal.RecentChanges(folder, limit)
+	return changes, SourceJournal
+}
+
+// Record adds a change made through the wiki to the journal. Cached history
+// is dropped so the change shows up once it has been committed.
+func (f *Feed) Record(change types.Change) {
+	if err := f.journal.Record(change); err != nil {
+		log.Printf("Warning: failed to record change to %s: %v", change.Path, err)
+	}
+
+	f.mu.Lock()
+	f.cache = make(map[string]cachedChanges)
+	f.mu.Unlock()
+}
+
+func truncate(changes []types.Change, limit int) []types.Change {
+	if len(changes) > limit {
+		return changes[:limit]
+	}
+	return changes
+}
@@ -0,0 +1,152 @@
+package changes
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+)
+
+// Journal is a local record of changes made through the wiki, used when the
+// storage has no history of its own. It keeps the latest entries in memory
+// and appends each one to a JSON lines file.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	size    int
+	entries []types.Change
+	lines   int
+}
+
+// NewJournal loads the journal file, keeping at most size entries
+func NewJournal(path string, size int) (*Journal, error) {
+	if size <= 0 {
+		size = 1000
+	}
+	j := &Journal{path: path, size: size}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return nil, fmt.Errorf("failed to read change journal: %v", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var change types.Change
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			log.Printf("Warning: skipping bad change journal line: %v", err)
+			continue
+		}
+		j.entries = append(j.entries, change)
+		j.lines++
+	}
+	if len(j.entries) > size {
+		j.entries = j.entries[len(j.entries)-size:]
+	}
+	log.Printf("Loaded %d entries from the change journal", len(j.entries))
+	return j, nil
+}
+
+// Record appends a change, filling in its id and time when unset
+func (j *Journal) Record(change types.Change) error {
+	if change.ID == "" {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return fmt.Errorf("failed to generate change id: %v", err)
+		}
+		change.ID = hex.EncodeToString(raw)
+	}
+	if change.Time.IsZero() {
+		change.Time = time.Now().UTC()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = append(j.entries, change)
+	if len(j.entries) > j.size {
+		j.entries = j.entries[len(j.entries)-j.size:]
+	}
+
+	// Rewrite the file once it holds twice as many lines as are kept
+	if j.lines >= 2*j.size {
+		return j.compact()
+	}
+
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to encode change: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open change journal: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write change journal: %v", err)
+	}
+	j.lines++
+	return nil
+}
+
+// compact rewrites the file with only the kept entries. The caller must hold
+// the lock.
+func (j *Journal) compact() error {
+	var buf bytes.Buffer
+	for _, change := range j.entries {
+		data, err := json.Marshal(change)
+		if err != nil {
+			return fmt.Errorf("failed to encode change: %v", err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated file
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write change journal: %v", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("failed to replace change journal: %v", err)
+	}
+	j.lines = len(j.entries)
+	return nil
+}
+
+// RecentChanges returns the latest changes in a folder, newest first. An
+// empty folder means the whole wiki.
+func (j *Journal) RecentChanges(folder string, limit int) ([]types.Change, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var result []types.Change
+	for i := len(j.entries) - 1; i >= 0 && len(result) < limit; i-- {
+		change := j.entries[i]
+		if InFolder(change.Path, folder) || (change.OldPath != "" && InFolder(change.OldPath, folder)) {
+			result = append(result, change)
+		}
+	}
+	return result, nil
+}
+
+// InFolder reports whether a wiki path is the folder or lies under it
+func InFolder(wikiPath, folder string) bool {
+	return folder == "" || wikiPath == folder || strings.HasPrefix(wikiPath, folder+"/")
+}
@@ -0,0 +1,630 @@
+package collab
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is how long a write to a client may take
+	writeWait = 10 * time.Second
+
+	// pongWait is how long a client may stay silent before it's dropped
+	pongWait = 60 * time.Second
+
+	// pingPeriod must be shorter than pongWait
+	pingPeriod = 50 * time.Second
+
+	// maxMessageSize bounds a single client message
+	maxMessageSize = 1 << 20
+
+	// maxSaveAttempts bounds how often a save is retried after merging in
+	// changes made outside the session
+	maxSaveAttempts = 3
+
+	// maxHistory bounds the operations kept for clients that fall behind.
+	// A client sending an operation against an older revision reloads.
+	maxHistory = 1000
+)
+
+// colors are assigned to editors in the order they join a session
+var colors = []string{"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4", "#f032e6", "#9a6324"}
+
+// Editor identifies a person taking part in a session
+type Editor struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// PersistFunc saves the combined result of a session. Base is the content the
+// session last loaded or saved; when the stored page no longer matches it the
+// function must return a *ConflictError instead of overwriting. Contributors
+// lists everyone who edited since the last save, most recent last.
+type PersistFunc func(path, base, content string, contributors []Editor) error
+
+// ConflictError reports that a page was changed outside its editing session
+type ConflictError struct {
+	// Current is the content now in storage
+	Current string
+}
+
+func (e *ConflictError) Error() string {
+	return "page was changed outside the editing session"
+}
+
+// LoadFunc returns the current content of a page
+type LoadFunc func() (string, error)
+
+// Hub keeps one editing session per page
+type Hub struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	// pending holds the pages whose session is being loaded or whose last
+	// session is still saving; joins for the page wait for the channel to close
+	pending map[string]chan struct{}
+	persist PersistFunc
+	idle    time.Duration
+}
+
+// NewHub creates a hub that saves sessions after idle time without edits
+func NewHub(persist PersistFunc, idle time.Duration) *Hub {
+	log.Printf("Collaborative editing enabled, saving after %v idle", idle)
+	return &Hub{
+		sessions: make(map[string]*session),
+		pending:  make(map[string]chan struct{}),
+		persist:  persist,
+		idle:     idle,
+	}
+}
+
+// Active returns the editors currently connected to a page
+func (h *Hub) Active(path string) []Editor {
+	h.mu.Lock()
+	s := h.sessions[path]
+	h.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var editors []Editor
+	for c := range s.clients {
+		editors = append(editors, c.editor)
+	}
+	return editors
+}
+
+// Serve runs a client connection until it closes
+func (h *Hub) Serve(path string, load LoadFunc, editor Editor, conn *websocket.Conn) {
+	c := &client{
+		id:     newClientID(),
+		editor: editor,
+		conn:   conn,
+		send:   make(chan []byte, 64),
+	}
+	s, err := h.join(path, load, c)
+	if err != nil {
+		log.Printf("Collab: failed to open session for %s: %v", path, err)
+		conn.WriteJSON(message{Type: "error", Message: "Failed to load page"})
+		conn.Close()
+		return
+	}
+
+	go c.writePump()
+	c.readPump(s)
+
+	h.leave(s, c)
+}
+
+// join adds a client to the session of a page, creating it on first use.
+// The page is loaded without the hub lock, so a slow read doesn't hold up
+// other pages; joins for the same page wait for it. The client is added under
+// the hub lock so the session can't close while it joins.
+func (h *Hub) join(path string, load LoadFunc, c *client) (*session, error) {
+	h.mu.Lock()
+	// Wait for a load or the previous session's final save, so the page isn't
+	// loaded twice or reloaded stale
+	for {
+		done, ok := h.pending[path]
+		if !ok {
+			break
+		}
+		h.mu.Unlock()
+		<-done
+		h.mu.Lock()
+	}
+
+	s, ok := h.sessions[path]
+	if !ok {
+		done := make(chan struct{})
+		h.pending[path] = done
+		h.mu.Unlock()
+
+		content, err := load()
+
+		h.mu.Lock()
+		delete(h.pending, path)
+		close(done)
+		if err != nil {
+			h.mu.Unlock()
+			return nil, err
+		}
+		s = newSession(h, path, content)
+		h.sessions[path] = s
+		log.Printf("Collab: opened session for %s", path)
+	}
+	s.add(c)
+	h.mu.Unlock()
+	return s, nil
+}
+
+// leave removes a client and closes the session once the last one is gone.
+// The final save runs without the hub lock, so other pages aren't held up;
+// only a new session for the same page waits for it.
+func (h *Hub) leave(s *session, c *client) {
+	h.mu.Lock()
+	if remaining := s.remove(c); remaining > 0 {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.sessions, s.path)
+	done := make(chan struct{})
+	h.pending[s.path] = done
+	h.mu.Unlock()
+
+	s.stopTimer()
+	s.flush()
+
+	h.mu.Lock()
+	delete(h.pending, s.path)
+	close(done)
+	h.mu.Unlock()
+	log.Printf("Collab: closed session for %s", s.path)
+}
+
+// message is the JSON envelope exchanged with browsers
+type message struct {
+	Type      string     `json:"type"`
+	Rev       int        `json:"rev"`
+	Op        *Operation `json:"op,omitempty"`
+	Text      *string    `json:"text,omitempty"`
+	Client    string     `json:"client,omitempty"`
+	Pos       *int       `json:"pos,omitempty"`
+	Peer      *peer      `json:"peer,omitempty"`
+	Peers     []peer     `json:"peers,omitempty"`
+	Message   string     `json:"message,omitempty"`
+	SavedAt   *time.Time `json:"savedAt,omitempty"`
+	ClientID  string     `json:"clientId,omitempty"`
+	ClientCol string     `json:"color,omitempty"`
+}
+
+// peer describes a connected editor to the others
+type peer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Color string `json:"color"`
+	Pos   int    `json:"pos"`
+}
+
+// session is the shared state of one page
+type session struct {
+	mu   sync.Mutex
+	hub  *Hub
+	path string
+	doc  []rune
+	rev  int
+	// history holds the operations clients may still have to be transformed
+	// against: history[i] turned revision historyRev+i into the next one
+	history    []Operation
+	historyRev int
+	clients    map[*client]bool
+	joined     int
+
+	// Save bookkeeping: base is the content last loaded from or written to
+	// storage and unsaved the operations that turn it into doc. contributors
+	// maps email to the revision of their last edit, savedRev is the revision
+	// last written to storage.
+	base         string
+	unsaved      []Operation
+	contributors map[string]int
+	editors      map[string]Editor
+	savedRev     int
+	timer        *time.Timer
+	saving       sync.Mutex
+}
+
+// newSession creates the session of a page holding its stored content
+func newSession(h *Hub, path, content string) *session {
+	return &session{
+		hub:          h,
+		path:         path,
+		doc:          []rune(content),
+		base:         content,
+		clients:      make(map[*client]bool),
+		contributors: make(map[string]int),
+		editors:      make(map[string]Editor),
+	}
+}
+
+// add registers a client and sends it the current document
+func (s *session) add(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.color = colors[s.joined%len(colors)]
+	c.rev = s.rev
+	s.joined++
+	s.clients[c] = true
+
+	var peers []peer
+	for other := range s.clients {
+		if other != c {
+			peers = append(peers, other.peer())
+		}
+	}
+	text := string(s.doc)
+	c.sendJSON(message{
+		Type:      "init",
+		Rev:       s.rev,
+		Text:      &text,
+		Peers:     peers,
+		ClientID:  c.id,
+		ClientCol: c.color,
+	})
+	p := c.peer()
+	s.broadcast(message{Type: "join", Peer: &p}, c)
+	log.Printf("Collab: %s joined %s (%d connected)", c.editor.Email, s.path, len(s.clients))
+}
+
+// remove unregisters a client and returns how many are left
+func (s *session) remove(c *client) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.clients[c] {
+		delete(s.clients, c)
+		close(c.send)
+		s.broadcast(message{Type: "leave", Client: c.id}, nil)
+		log.Printf("Collab: %s left %s (%d connected)", c.editor.Email, s.path, len(s.clients))
+	}
+	return len(s.clients)
+}
+
+// broadcast sends a message to every client except the one given
+func (s *session) broadcast(msg message, except *client) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Collab: failed to encode message: %v", err)
+		return
+	}
+	for c := range s.clients {
+		if c != except {
+			c.queue(data)
+		}
+	}
+}
+
+// receive applies an operation a client made against revision rev
+func (s *session) receive(c *client, rev int, op Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rev < s.historyRev || rev > s.rev {
+		return fmt.Errorf("invalid revision %d (current %d)", rev, s.rev)
+	}
+
+	// Transform against everything the client hadn't seen yet
+	for _, concurrent := range s.history[rev-s.historyRev:] {
+		var err error
+		op, _, err = Transform(op, concurrent)
+		if err != nil {
+			return err
+		}
+	}
+
+	doc, err := op.Apply(s.doc)
+	if err != nil {
+		return err
+	}
+	s.doc = doc
+	s.history = append(s.history, op)
+	s.unsaved = append(s.unsaved, op)
+	s.rev++
+
+	for other := range s.clients {
+		other.cursor = op.TransformPosition(other.cursor)
+	}
+
+	s.contributors[c.editor.Email] = s.rev
+	s.editors[c.editor.Email] = c.editor
+
+	// The client waits for this ack before sending its next operation, so
+	// that one can't be based on an older revision
+	c.rev = s.rev
+	c.sendJSON(message{Type: "ack", Rev: s.rev})
+	s.broadcast(message{Type: "op", Rev: s.rev, Op: &op, Client: c.id}, c)
+	s.trimHistory()
+	s.resetTimer()
+	return nil
+}
+
+// seen records that a client with no operation in flight has applied every
+// revision up to rev, so its next operation can't be based on an older one
+func (s *session) seen(c *client, rev int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rev > c.rev && rev <= s.rev {
+		c.rev = rev
+		s.trimHistory()
+	}
+}
+
+// trimHistory drops the operations every client has already seen, and beyond
+// maxHistory the oldest ones regardless. The caller must hold s.mu.
+func (s *session) trimHistory() {
+	oldest := s.rev
+	for c := range s.clients {
+		if c.rev < oldest {
+			oldest = c.rev
+		}
+	}
+	oldest = max(oldest, s.rev-maxHistory)
+	if drop := oldest - s.historyRev; drop > 0 {
+		s.history = s.history[drop:]
+		s.historyRev = oldest
+	}
+}
+
+// merge brings a change made outside the session into it, as an operation
+// from the server that every client applies. current is the content now in
+// storage, which becomes the new base. The caller must hold s.mu.
+func (s *session) merge(current string) error {
+	external := Diff(s.base, current)
+	for i, op := range s.unsaved {
+		var err error
+		if external, s.unsaved[i], err = Transform(external, op); err != nil {
+			return err
+		}
+	}
+	doc, err := external.Apply(s.doc)
+	if err != nil {
+		return err
+	}
+	s.doc = doc
+	s.base = current
+	s.history = append(s.history, external)
+	s.rev++
+	s.trimHistory()
+
+	for c := range s.clients {
+		c.cursor = external.TransformPosition(c.cursor)
+	}
+	s.broadcast(message{Type: "op", Rev: s.rev, Op: &external}, nil)
+	log.Printf("Collab: merged a change made outside the session into %s", s.path)
+	return nil
+}
+
+// moveCursor records and relays a client's cursor position
+func (s *session) moveCursor(c *client, pos int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pos < 0 || pos > len(s.doc) {
+		return
+	}
+	c.cursor = pos
+	s.broadcast(message{Type: "cursor", Client: c.id, Pos: &pos}, c)
+}
+
+// resetTimer schedules a save once edits stop. The caller must hold s.mu.
+func (s *session) resetTimer() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(s.hub.idle, s.flush)
+}
+
+// stopTimer cancels a pending idle save
+func (s *session) stopTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// flush saves the document if it changed since the last save, as a single
+// combined change attributed to everyone who edited. When the page was
+// changed outside the session, that change is merged in and the save retried.
+func (s *session) flush() {
+	s.saving.Lock()
+	defer s.saving.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		s.mu.Lock()
+		if s.rev == s.savedRev {
+			s.mu.Unlock()
+			return
+		}
+		rev := s.rev
+		base := s.base
+		saved := len(s.unsaved)
+		content := string(s.doc)
+		emails := make([]string, 0, len(s.contributors))
+		for email := range s.contributors {
+			emails = append(emails, email)
+		}
+		sort.Slice(emails, func(i, j int) bool {
+			return s.contributors[emails[i]] < s.contributors[emails[j]]
+		})
+		contributors := make([]Editor, 0, len(emails))
+		for _, email := range emails {
+			contributors = append(contributors, s.editors[email])
+		}
+		s.mu.Unlock()
+
+		err := s.hub.persist(s.path, base, content, contributors)
+		var conflict *ConflictError
+		if errors.As(err, &conflict) && attempt < maxSaveAttempts {
+			s.mu.Lock()
+			err = s.merge(conflict.Current)
+			s.mu.Unlock()
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			log.Printf("Collab: failed to save %s: %v", s.path, err)
+			s.mu.Lock()
+			s.broadcast(message{Type: "saveFailed", Message: "Failed to save changes, will retry"}, nil)
+			if len(s.clients) > 0 {
+				s.resetTimer()
+			}
+			s.mu.Unlock()
+			return
+		}
+
+		now := time.Now().UTC()
+		s.mu.Lock()
+		s.savedRev = rev
+		s.base = content
+		s.unsaved = s.unsaved[saved:]
+		// Only forget contributors whose edits are all included in this save
+		for email, last := range s.contributors {
+			if last <= rev {
+				delete(s.contributors, email)
+				delete(s.editors, email)
+			}
+		}
+		s.broadcast(message{Type: "saved", Rev: rev, SavedAt: &now}, nil)
+		s.mu.Unlock()
+		log.Printf("Collab: saved %s at revision %d", s.path, rev)
+		return
+	}
+}
+
+// client is one browser connection
+type client struct {
+	id     string
+	editor Editor
+	color  string
+	conn   *websocket.Conn
+	send   chan []byte
+	cursor int
+	// rev is the oldest revision the client may still send operations
+	// against. It moves when the client's operation is acknowledged or the
+	// client reports, with nothing in flight, which revisions it has seen.
+	rev int
+}
+
+// peer describes the client to other editors
+func (c *client) peer() peer {
+	return peer{ID: c.id, Name: c.editor.Name, Email: c.editor.Email, Color: c.color, Pos: c.cursor}
+}
+
+// sendJSON queues a message for the client
+func (c *client) sendJSON(msg message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Collab: failed to encode message: %v", err)
+		return
+	}
+	c.queue(data)
+}
+
+// queue hands data to the write pump, dropping the client if it can't keep up
+func (c *client) queue(data []byte) {
+	select {
+	case c.send <- data:
+	default:
+		log.Printf("Collab: dropping slow client %s", c.editor.Email)
+		c.conn.Close()
+	}
+}
+
+// readPump handles messages from the browser until the connection closes
+func (c *client) readPump(s *session) {
+	defer c.conn.Close()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Collab: read error from %s: %v", c.editor.Email, err)
+			}
+			return
+		}
+
+		switch msg.Type {
+		case "op":
+			if msg.Op == nil {
+				continue
+			}
+			if err := s.receive(c, msg.Rev, *msg.Op); err != nil {
+				// The client is out of sync; have it reload the document
+				log.Printf("Collab: rejected operation from %s on %s: %v", c.editor.Email, s.path, err)
+				c.sendJSON(message{Type: "error", Message: "Your editor got out of sync and will reload"})
+				return
+			}
+		case "seen":
+			s.seen(c, msg.Rev)
+		case "cursor":
+			if msg.Pos != nil {
+				s.moveCursor(c, *msg.Pos)
+			}
+		}
+	}
+}
+
+// writePump sends queued messages and keeps the connection alive
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// newClientID returns a random connection id
+func newClientID() string {
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
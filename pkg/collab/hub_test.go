@@ -0,0 +1,186 @@
+package collab
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestSession returns a session whose saves always succeed
+func newTestSession(content string) *session {
+	h := NewHub(func(path, base, content string, contributors []Editor) error { return nil }, time.Hour)
+	return newSession(h, "notes/meeting", content)
+}
+
+// newTestClient returns a client without a connection, with room to queue
+// every message a test sends it
+func newTestClient(email string) *client {
+	return &client{id: email, editor: Editor{Email: email}, send: make(chan []byte, 2*maxHistory)}
+}
+
+// appendOp returns an operation adding text to the end of a document of n characters
+func appendOp(n int, text string) Operation {
+	var o Operation
+	o.retain(n)
+	o.insert(text)
+	return o
+}
+
+func TestMergeExternalEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		unsaved  []string
+		external string
+		want     string
+	}{
+		{"edits apart", "hello world", []string{`[5,"!",6]`}, "hello brave world", "hello! brave world"},
+		// The stored change was made first, so its insert goes first
+		{"same offset", "abc", []string{`[3,"1"]`}, "abc2", "abc21"},
+		{"several unsaved edits", "one two", []string{`[3,"A",4]`, `[8,"B"]`}, "zero one two", "zero oneA twoB"},
+		{"external delete under an edit", "abcdef", []string{`[3,"X",3]`}, "af", "aXf"},
+		{"external change only", "abc", nil, "abcd", "abcd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSession(tt.base)
+			for _, wire := range tt.unsaved {
+				o := op(t, wire)
+				s.doc = []rune(apply(t, o, string(s.doc)))
+				s.unsaved = append(s.unsaved, o)
+				s.history = append(s.history, o)
+				s.rev++
+			}
+
+			if err := s.merge(tt.external); err != nil {
+				t.Fatalf("merge: %v", err)
+			}
+			if got := string(s.doc); got != tt.want {
+				t.Errorf("doc = %q, want %q", got, tt.want)
+			}
+			if s.base != tt.external {
+				t.Errorf("base = %q, want the stored content %q", s.base, tt.external)
+			}
+			// The unsaved operations must now turn the new base into the document
+			rebuilt := s.base
+			for _, o := range s.unsaved {
+				rebuilt = apply(t, o, rebuilt)
+			}
+			if rebuilt != string(s.doc) {
+				t.Errorf("unsaved operations rebuild %q, want %q", rebuilt, string(s.doc))
+			}
+		})
+	}
+}
+
+func TestReceiveTransformsStaleOperation(t *testing.T) {
+	s := newTestSession("abc")
+	defer s.stopTimer()
+	alice, bob := newTestClient("alice"), newTestClient("bob")
+	s.add(alice)
+	s.add(bob)
+
+	if err := s.receive(alice, 0, op(t, `["X",3]`)); err != nil {
+		t.Fatalf("receive alice: %v", err)
+	}
+	// Bob hadn't seen Alice's edit yet
+	if err := s.receive(bob, 0, op(t, `[3,"Y"]`)); err != nil {
+		t.Fatalf("receive bob: %v", err)
+	}
+	if got := string(s.doc); got != "XabcY" {
+		t.Errorf("doc = %q, want XabcY", got)
+	}
+}
+
+func TestHistoryTrimmedForWatchers(t *testing.T) {
+	s := newTestSession("")
+	defer s.stopTimer()
+	editor, watcher := newTestClient("editor"), newTestClient("watcher")
+	s.add(editor)
+	s.add(watcher)
+
+	for i := 0; i < 5; i++ {
+		if err := s.receive(editor, s.rev, appendOp(i, "x")); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+	}
+	if len(s.history) != 5 {
+		t.Fatalf("history has %d operations before the watcher reported, want 5", len(s.history))
+	}
+
+	s.seen(watcher, 5)
+	if len(s.history) != 0 || s.historyRev != 5 {
+		t.Errorf("history has %d operations from revision %d after every client saw revision 5",
+			len(s.history), s.historyRev)
+	}
+
+	// A revision the session hasn't reached is ignored
+	s.seen(watcher, 9)
+	if watcher.rev != 5 {
+		t.Errorf("watcher rev = %d after reporting an unknown revision", watcher.rev)
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	s := newTestSession("")
+	defer s.stopTimer()
+	editor, watcher := newTestClient("editor"), newTestClient("watcher")
+	s.add(editor)
+	s.add(watcher)
+
+	for i := 0; i < maxHistory+10; i++ {
+		if err := s.receive(editor, s.rev, appendOp(i, "x")); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+	}
+	if len(s.history) != maxHistory {
+		t.Errorf("history has %d operations, want at most %d", len(s.history), maxHistory)
+	}
+	// The watcher fell too far behind to send against its old revision
+	if err := s.receive(watcher, 0, op(t, `["y",`+strconv.Itoa(len(s.doc))+`]`)); err == nil {
+		t.Error("accepted an operation against a dropped revision")
+	}
+}
+
+func TestJoinLoadsWithoutHubLock(t *testing.T) {
+	h := NewHub(func(path, base, content string, contributors []Editor) error { return nil }, time.Hour)
+	release := make(chan struct{})
+	slowJoined := make(chan error)
+	go func() {
+		_, err := h.join("slow", func() (string, error) {
+			<-release
+			return "slow page", nil
+		}, newTestClient("alice"))
+		slowJoined <- err
+	}()
+
+	fastJoined := make(chan error)
+	go func() {
+		_, err := h.join("fast", func() (string, error) { return "fast page", nil }, newTestClient("bob"))
+		fastJoined <- err
+	}()
+	select {
+	case err := <-fastJoined:
+		if err != nil {
+			t.Fatalf("join fast: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("joining one page waited for another page to load")
+	}
+
+	close(release)
+	if err := <-slowJoined; err != nil {
+		t.Fatalf("join slow: %v", err)
+	}
+	// A second join of the loaded page reuses the session
+	s, err := h.join("slow", func() (string, error) {
+		t.Error("loaded a page that already has a session")
+		return "", nil
+	}, newTestClient("carol"))
+	if err != nil {
+		t.Fatalf("join slow again: %v", err)
+	}
+	if string(s.doc) != "slow page" || len(s.clients) != 2 {
+		t.Errorf("session has %q with %d clients, want the loaded page with 2", string(s.doc), len(s.clients))
+	}
+}
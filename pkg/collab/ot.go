@@ -0,0 +1,263 @@
+package collab
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Component is one step of an operation: retain N characters, insert a
+// string, or delete N characters. Lengths count Unicode code points so that
+// the browser (Array.from) and the server agree on positions.
+type Component struct {
+	Retain int
+	Insert string
+	Delete int
+}
+
+// Operation transforms a document of BaseLen characters into one of
+// TargetLen characters. On the wire it uses the ot.js format: positive
+// numbers retain, negative numbers delete and strings insert.
+type Operation struct {
+	Components []Component
+	BaseLen    int
+	TargetLen  int
+}
+
+func (o *Operation) retain(n int) {
+	if n <= 0 {
+		return
+	}
+	o.BaseLen += n
+	o.TargetLen += n
+	if last := len(o.Components) - 1; last >= 0 && o.Components[last].Retain > 0 {
+		o.Components[last].Retain += n
+		return
+	}
+	o.Components = append(o.Components, Component{Retain: n})
+}
+
+func (o *Operation) insert(s string) {
+	if s == "" {
+		return
+	}
+	o.TargetLen += utf8.RuneCountInString(s)
+	last := len(o.Components) - 1
+	if last >= 0 && o.Components[last].Insert != "" {
+		o.Components[last].Insert += s
+		return
+	}
+	// Keep inserts before deletes so equivalent operations look the same
+	if last >= 0 && o.Components[last].Delete > 0 {
+		if last > 0 && o.Components[last-1].Insert != "" {
+			o.Components[last-1].Insert += s
+			return
+		}
+		o.Components = append(o.Components, o.Components[last])
+		o.Components[last] = Component{Insert: s}
+		return
+	}
+	o.Components = append(o.Components, Component{Insert: s})
+}
+
+func (o *Operation) delete(n int) {
+	if n <= 0 {
+		return
+	}
+	o.BaseLen += n
+	if last := len(o.Components) - 1; last >= 0 && o.Components[last].Delete > 0 {
+		o.Components[last].Delete += n
+		return
+	}
+	o.Components = append(o.Components, Component{Delete: n})
+}
+
+// UnmarshalJSON decodes an operation from the ot.js wire format
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var raw []interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*o = Operation{}
+	for _, item := range raw {
+		switch v := item.(type) {
+		case float64:
+			n := int(v)
+			if float64(n) != v || n == 0 {
+				return fmt.Errorf("invalid operation component %v", v)
+			}
+			if n > 0 {
+				o.retain(n)
+			} else {
+				o.delete(-n)
+			}
+		case string:
+			o.insert(v)
+		default:
+			return fmt.Errorf("invalid operation component %v", v)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes an operation in the ot.js wire format
+func (o Operation) MarshalJSON() ([]byte, error) {
+	raw := make([]interface{}, 0, len(o.Components))
+	for _, c := range o.Components {
+		switch {
+		case c.Retain > 0:
+			raw = append(raw, c.Retain)
+		case c.Insert != "":
+			raw = append(raw, c.Insert)
+		case c.Delete > 0:
+			raw = append(raw, -c.Delete)
+		}
+	}
+	return json.Marshal(raw)
+}
+
+// Apply runs the operation on a document
+func (o Operation) Apply(doc []rune) ([]rune, error) {
+	if len(doc) != o.BaseLen {
+		return nil, fmt.Errorf("operation base length %d does not match document length %d", o.BaseLen, len(doc))
+	}
+	result := make([]rune, 0, o.TargetLen)
+	pos := 0
+	for _, c := range o.Components {
+		switch {
+		case c.Retain > 0:
+			result = append(result, doc[pos:pos+c.Retain]...)
+			pos += c.Retain
+		case c.Insert != "":
+			result = append(result, []rune(c.Insert)...)
+		case c.Delete > 0:
+			pos += c.Delete
+		}
+	}
+	return result, nil
+}
+
+// Diff returns an operation that turns a into b, replacing everything between
+// their common prefix and suffix
+func Diff(a, b string) Operation {
+	ar, br := []rune(a), []rune(b)
+	prefix := 0
+	for prefix < len(ar) && prefix < len(br) && ar[prefix] == br[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ar)-prefix && suffix < len(br)-prefix && ar[len(ar)-1-suffix] == br[len(br)-1-suffix] {
+		suffix++
+	}
+
+	var op Operation
+	op.retain(prefix)
+	op.insert(string(br[prefix : len(br)-suffix]))
+	op.delete(len(ar) - prefix - suffix)
+	op.retain(suffix)
+	return op
+}
+
+// TransformPosition moves a cursor position in the base document to the
+// matching position after the operation
+func (o Operation) TransformPosition(pos int) int {
+	index := 0
+	newPos := pos
+	for _, c := range o.Components {
+		if index > pos {
+			break
+		}
+		switch {
+		case c.Retain > 0:
+			index += c.Retain
+		case c.Insert != "":
+			newPos += utf8.RuneCountInString(c.Insert)
+		case c.Delete > 0:
+			newPos -= min(c.Delete, pos-index)
+			index += c.Delete
+		}
+	}
+	return newPos
+}
+
+// Transform takes two operations a and b that apply to the same document and
+// returns a' and b' such that b' applied after a equals a' applied after b
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLen != b.BaseLen {
+		return Operation{}, Operation{}, fmt.Errorf("both operations must have the same base length (%d != %d)", a.BaseLen, b.BaseLen)
+	}
+
+	var aPrime, bPrime Operation
+	ac, bc := a.Components, b.Components
+	i, j := 0, 0
+	var c1, c2 *Component
+	next := func(list []Component, k *int) *Component {
+		if *k >= len(list) {
+			return nil
+		}
+		c := list[*k]
+		*k++
+		return &c
+	}
+	c1, c2 = next(ac, &i), next(bc, &j)
+
+	for c1 != nil || c2 != nil {
+		// Inserts go first; a's inserts win ties so both sides agree
+		if c1 != nil && c1.Insert != "" {
+			aPrime.insert(c1.Insert)
+			bPrime.retain(utf8.RuneCountInString(c1.Insert))
+			c1 = next(ac, &i)
+			continue
+		}
+		if c2 != nil && c2.Insert != "" {
+			aPrime.retain(utf8.RuneCountInString(c2.Insert))
+			bPrime.insert(c2.Insert)
+			c2 = next(bc, &j)
+			continue
+		}
+		if c1 == nil || c2 == nil {
+			return Operation{}, Operation{}, fmt.Errorf("operations cannot be transformed: one is too short")
+		}
+
+		switch {
+		case c1.Retain > 0 && c2.Retain > 0:
+			n := min(c1.Retain, c2.Retain)
+			aPrime.retain(n)
+			bPrime.retain(n)
+			c1.Retain -= n
+			c2.Retain -= n
+		case c1.Delete > 0 && c2.Delete > 0:
+			// Both deleted the same text; nothing left to do for it
+			n := min(c1.Delete, c2.Delete)
+			c1.Delete -= n
+			c2.Delete -= n
+		case c1.Delete > 0 && c2.Retain > 0:
+			n := min(c1.Delete, c2.Retain)
+			aPrime.delete(n)
+			c1.Delete -= n
+			c2.Retain -= n
+		case c1.Retain > 0 && c2.Delete > 0:
+			n := min(c1.Retain, c2.Delete)
+			bPrime.delete(n)
+			c1.Retain -= n
+			c2.Delete -= n
+		default:
+			return Operation{}, Operation{}, fmt.Errorf("operations cannot be transformed: invalid components")
+		}
+
+		if c1.Retain == 0 && c1.Delete == 0 {
+			c1 = next(ac, &i)
+		}
+		if c2.Retain == 0 && c2.Delete == 0 {
+			c2 = next(bc, &j)
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
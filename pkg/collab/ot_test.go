@@ -0,0 +1,114 @@
+package collab
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// op decodes an operation from the ot.js wire format
+func op(t *testing.T, wire string) Operation {
+	t.Helper()
+	var o Operation
+	if err := json.Unmarshal([]byte(wire), &o); err != nil {
+		t.Fatalf("decode %s: %v", wire, err)
+	}
+	return o
+}
+
+// apply runs an operation on a string
+func apply(t *testing.T, o Operation, doc string) string {
+	t.Helper()
+	result, err := o.Apply([]rune(doc))
+	if err != nil {
+		t.Fatalf("apply %v to %q: %v", o, doc, err)
+	}
+	return string(result)
+}
+
+func TestTransformConverges(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		a, b string
+		want string
+	}{
+		{"insert/insert at different offsets", "abcdef", `[1,"X",5]`, `[4,"Y",2]`, "aXbcdYef"},
+		{"insert/insert at the same offset", "abcdef", `[3,"X",3]`, `[3,"Y",3]`, "abcXYdef"},
+		{"insert/delete before the insert", "abcdef", `[4,"X",2]`, `[1,-2,3]`, "adXef"},
+		{"insert/delete around the insert", "abcdef", `[3,"X",3]`, `[2,-2,2]`, "abXef"},
+		{"insert/delete at the same offset", "abcdef", `[2,"X",4]`, `[2,-2,2]`, "abXef"},
+		{"delete/delete of the same text", "abcdef", `[2,-2,2]`, `[2,-2,2]`, "abef"},
+		{"delete/delete overlapping", "abcdef", `[1,-3,2]`, `[2,-3,1]`, "af"},
+		{"delete/delete one inside the other", "abcdef", `[1,-4,1]`, `[2,-1,3]`, "af"},
+		{"delete/delete apart", "abcdef", `[-1,5]`, `[5,-1]`, "bcde"},
+		{"replace/replace overlapping", "abcdef", `[1,"X",-3,2]`, `[2,"Y",-3,1]`, "aXYf"},
+		{"multibyte text", "héllo wörld", `[6,"∂",5]`, `[1,-1,9]`, "hllo ∂wörld"},
+		{"empty document", "", `["X"]`, `["Y"]`, "XY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := op(t, tt.a), op(t, tt.b)
+			aPrime, bPrime, err := Transform(a, b)
+			if err != nil {
+				t.Fatalf("Transform: %v", err)
+			}
+			viaA := apply(t, bPrime, apply(t, a, tt.doc))
+			viaB := apply(t, aPrime, apply(t, b, tt.doc))
+			if viaA != viaB {
+				t.Fatalf("diverged: b' after a = %q, a' after b = %q", viaA, viaB)
+			}
+			if viaA != tt.want {
+				t.Errorf("result = %q, want %q", viaA, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformRejectsMismatchedOperations(t *testing.T) {
+	if _, _, err := Transform(op(t, `[3]`), op(t, `[4]`)); err == nil {
+		t.Error("transformed operations on documents of different lengths")
+	}
+}
+
+func TestApplyChecksLength(t *testing.T) {
+	if _, err := op(t, `[2,"X"]`).Apply([]rune("abc")); err == nil {
+		t.Error("applied an operation to a document of the wrong length")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"", ""},
+		{"", "new"},
+		{"old", ""},
+		{"same", "same"},
+		{"hello world", "hello brave world"},
+		{"hello brave world", "hello world"},
+		{"aaa", "aaaa"},
+		{"abcabc", "abXabc"},
+		{"naïve café", "naive cafe"},
+	}
+	for _, tt := range tests {
+		d := Diff(tt.a, tt.b)
+		if got := apply(t, d, tt.a); got != tt.b {
+			t.Errorf("Diff(%q, %q) applied = %q", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestOperationJSONRoundTrip(t *testing.T) {
+	wire := `[2,"ab",-3,1]`
+	data, err := json.Marshal(op(t, wire))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != wire {
+		t.Errorf("encoded %s, want %s", data, wire)
+	}
+	for _, bad := range []string{`[0]`, `[1.5]`, `[true]`} {
+		var o Operation
+		if err := json.Unmarshal([]byte(bad), &o); err == nil {
+			t.Errorf("decoded invalid operation %s", bad)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package comments
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+)
+
+const (
+	// Suffix names the sidecar file next to a page, e.g. deploy.comments.json
+	Suffix = ".comments.json"
+
+	// MaxBodyLength caps the size of a single comment in bytes
+	MaxBodyLength = 10000
+)
+
+// Comment is one comment on a page. Replies point at the comment that starts
+// their thread; threads are one level deep.
+type Comment struct {
+	ID          string     `json:"id"`
+	ParentID    string     `json:"parent_id,omitempty"`
+	AuthorEmail string     `json:"author_email"`
+	AuthorName  string     `json:"author_name"`
+	Body        string     `json:"body"`
+	CreatedAt   time.Time  `json:"created_at"`
+	EditedAt    *time.Time `json:"edited_at,omitempty"`
+
+	// Deleted comments that still have replies keep their place in the thread
+	Deleted bool `json:"deleted,omitempty"`
+
+	// Resolution is only set on the comment that starts a thread
+	Resolved   bool       `json:"resolved,omitempty"`
+	ResolvedBy string     `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Thread is a top-level comment and its replies, oldest first
+type Thread struct {
+	*Comment
+	Replies []*Comment `json:"replies"`
+}
+
+// File is the content of a page's comments sidecar
+type File struct {
+	Comments []*Comment `json:"comments"`
+}
+
+// Author identifies who writes a comment
+type Author struct {
+	Email string
+	Name  string
+}
+
+// Store reads and writes comment sidecars, one page at a time
+type Store struct {
+	mu sync.Mutex
+}
+
+// NewStore creates a comment store
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Load returns the comments on a page, or none when it has no sidecar
+func (s *Store) Load(sidecars types.SidecarStore, pagePath string) (*File, error) {
+	data, err := sidecars.ReadSidecar(pagePath, Suffix)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &File{}, nil
+		}
+		return nil, err
+	}
+
+	var f File
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse comments: %v", err)
+		}
+	}
+	return &f, nil
+}
+
+// Update loads a page's comments, applies change and writes them back. Writes
+// to the same wiki are serialised so concurrent comments aren't lost.
+func (s *Store) Update(sidecars types.SidecarStore, pagePath string, change func(f *File) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.Load(sidecars, pagePath)
+	if err != nil {
+		return err
+	}
+	if err := change(f); err != nil {
+		return err
+	}
+
+	if len(f.Comments) == 0 {
+		return sidecars.DeleteSidecar(pagePath, Suffix)
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode comments: %v", err)
+	}
+	return sidecars.WriteSidecar(pagePath, Suffix, append(data, '\n'))
+}
+
+// Move carries a page's comments over to its new path
+func (s *Store) Move(sidecars types.SidecarStore, oldPath, newPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := sidecars.ReadSidecar(oldPath, Suffix)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := sidecars.WriteSidecar(newPath, Suffix, data); err != nil {
+		return err
+	}
+	return sidecars.DeleteSidecar(oldPath, Suffix)
+}
+
+// Delete removes a page's comments once the page itself is deleted
+func (s *Store) Delete(sidecars types.SidecarStore, pagePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sidecars.DeleteSidecar(pagePath, Suffix)
+}
+
+// validBody trims a comment body and checks it
+func validBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("comment can't be empty")
+	}
+	if len(body) > MaxBodyLength {
+		return "", fmt.Errorf("comment is longer than %d characters", MaxBodyLength)
+	}
+	return body, nil
+}
+
+// find returns the comment with the given id
+func (f *File) find(id string) (*Comment, error) {
+	for _, c := range f.Comments {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("comment not found")
+}
+
+// Add appends a comment, or a reply when parentID is set. Replies to a reply
+// join the thread of the comment it belongs to.
+func (f *File) Add(author Author, parentID, body string) (*Comment, error) {
+	body, err := validBody(body)
+	if err != nil {
+		return nil, err
+	}
+	if parentID != "" {
+		parent, err := f.find(parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.ParentID != "" {
+			parentID = parent.ParentID
+		}
+	}
+
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate comment id: %v", err)
+	}
+	comment := &Comment{
+		ID:          hex.EncodeToString(raw),
+		ParentID:    parentID,
+		AuthorEmail: author.Email,
+		AuthorName:  author.Name,
+		Body:        body,
+		CreatedAt:   time.Now().UTC(),
+	}
+	f.Comments = append(f.Comments, comment)
+	return comment, nil
+}
+
+// Edit replaces the body of a comment written by the given email
+func (f *File) Edit(id, email, body string) (*Comment, error) {
+	comment, err := f.find(id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Deleted || !strings.EqualFold(comment.AuthorEmail, email) {
+		return nil, fmt.Errorf("only the author can edit a comment")
+	}
+	body, err = validBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	comment.Body = body
+	comment.EditedAt = &now
+	return comment, nil
+}
+
+// Delete removes a comment written by the given email. A comment that others
+// replied to is blanked instead so the thread still makes sense.
+func (f *File) Delete(id, email string) error {
+	comment, err := f.find(id)
+	if err != nil {
+		return err
+	}
+	if comment.Deleted || !strings.EqualFold(comment.AuthorEmail, email) {
+		return fmt.Errorf("only the author can delete a comment")
+	}
+
+	if f.hasReplies(id) {
+		comment.Deleted = true
+		comment.Body = ""
+		return nil
+	}
+	f.remove(id)
+
+	// Drop a blanked thread start once its last reply is gone
+	if comment.ParentID != "" {
+		if parent, err := f.find(comment.ParentID); err == nil && parent.Deleted && !f.hasReplies(parent.ID) {
+			f.remove(parent.ID)
+		}
+	}
+	return nil
+}
+
+// hasReplies reports whether any comment replies to the given one
+func (f *File) hasReplies(id string) bool {
+	for _, c := range f.Comments {
+		if c.ParentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// remove drops a comment from the file
+func (f *File) remove(id string) {
+	kept := f.Comments[:0]
+	for _, c := range f.Comments {
+		if c.ID != id {
+			kept = append(kept, c)
+		}
+	}
+	f.Comments = kept
+}
+
+// Resolve marks a thread resolved or reopens it
+func (f *File) Resolve(id string, resolved bool, by string) (*Comment, error) {
+	comment, err := f.find(id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.ParentID != "" {
+		return nil, fmt.Errorf("only a thread can be resolved, not a reply")
+	}
+
+	comment.Resolved = resolved
+	if resolved {
+		now := time.Now().UTC()
+		comment.ResolvedBy = by
+		comment.ResolvedAt = &now
+	} else {
+		comment.ResolvedBy = ""
+		comment.ResolvedAt = nil
+	}
+	return comment, nil
+}
+
+// Threads groups the comments into threads, oldest first
+func (f *File) Threads() []Thread {
+	threads := []Thread{}
+	index := make(map[string]int)
+	for _, c := range f.Comments {
+		if c.ParentID == "" {
+			index[c.ID] = len(threads)
+			threads = append(threads, Thread{Comment: c, Replies: []*Comment{}})
+		}
+	}
+	for _, c := range f.Comments {
+		if c.ParentID == "" {
+			continue
+		}
+		if i, ok := index[c.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, c)
+		}
+	}
+	return threads
+}
@@ -1,7 +1,13 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -9,22 +15,46 @@ import (
 // Config represents the application configuration
 type Config struct {
 	Server struct {
-		Port    string `mapstructure:"port"`
-		Host    string `mapstructure:"host"`
-		DataDir string `mapstructure:"data_dir"`
+		Port     string `mapstructure:"port"`
+		Host     string `mapstructure:"host"`
+		DataDir  string `mapstructure:"data_dir"`
+		StateDir string `mapstructure:"state_dir"`
+		// Proxies whose X-Forwarded-For header is believed; none by default
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
 	} `mapstructure:"server"`
 	Session struct {
 		Secret        string   `mapstructure:"secret"`
 		Name          string   `mapstructure:"name"`
-		AllowedEmails []string `mapstructure:"allowed_emails"`
+		AllowedEmails []string `mapstructure:"allowed_emails"` // Deprecated: merged into login.allow
 		Secure        bool     `mapstructure:"secure"`
+		Backend       string   `mapstructure:"backend"`
+		IdleMinutes   int      `mapstructure:"idle_minutes"`
+		MaxAgeHours   int      `mapstructure:"max_age_hours"`
+		RememberDays  int      `mapstructure:"remember_days"`
+		WarnMinutes   int      `mapstructure:"warn_minutes"`
 	} `mapstructure:"session"`
 	Google struct {
 		ClientID      string   `mapstructure:"client_id"`
 		ClientSecret  string   `mapstructure:"client_secret"`
 		RedirectURL   string   `mapstructure:"redirect_url"`
-		AllowedEmails []string `mapstructure:"allowed_emails"`
+		AllowedEmails []string `mapstructure:"allowed_emails"` // Deprecated: merged into login.allow
 	} `mapstructure:"google"`
+	Login struct {
+		Allow         []string `mapstructure:"allow"`
+		Deny          []string `mapstructure:"deny"`
+		AllowlistFile string   `mapstructure:"allowlist_file"`
+		GroupsClaim   string   `mapstructure:"groups_claim"`
+	} `mapstructure:"login"`
+	Auth struct {
+		GitHub OAuthProvider  `mapstructure:"github"`
+		OIDC   []OIDCProvider `mapstructure:"oidc"`
+		Local  struct {
+			Enabled        bool   `mapstructure:"enabled"`
+			File           string `mapstructure:"file"`
+			MaxAttempts    int    `mapstructure:"max_attempts"`
+			LockoutMinutes int    `mapstructure:"lockout_minutes"`
+		} `mapstructure:"local"`
+	} `mapstructure:"auth"`
 	GitHub struct {
 		Token      string `mapstructure:"token"`
 		Owner      string `mapstructure:"owner"`
@@ -41,6 +71,111 @@ type Config struct {
 	Wiki struct {
 		MaxCategoryLevel int `mapstructure:"max_category_level"`
 	} `mapstructure:"wiki"`
+	Access struct {
+		DefaultRole   string       `mapstructure:"default_role"`
+		Roles         []AccessRule `mapstructure:"roles"`
+		Folders       []FolderACL  `mapstructure:"folders"`
+		PublicFolders []string     `mapstructure:"public_folders"`
+	} `mapstructure:"access"`
+	Audit struct {
+		MaxSizeMB   int  `mapstructure:"max_size_mb"`
+		MaxFiles    int  `mapstructure:"max_files"`
+		MirrorRedis bool `mapstructure:"mirror_redis"`
+		RedisLength int  `mapstructure:"redis_length"`
+	} `mapstructure:"audit"`
+	Trash struct {
+		RetentionDays int `mapstructure:"retention_days"`
+	} `mapstructure:"trash"`
+	Share struct {
+		Secret       string `mapstructure:"secret"`
+		DefaultHours int    `mapstructure:"default_hours"`
+		MaxDays      int    `mapstructure:"max_days"`
+	} `mapstructure:"share"`
+	RateLimit struct {
+		Auth RateLimit `mapstructure:"auth"`
+		API  RateLimit `mapstructure:"api"`
+		Web  RateLimit `mapstructure:"web"`
+	} `mapstructure:"rate_limit"`
+	Drafts struct {
+		Backend       string `mapstructure:"backend"`
+		RetentionDays int    `mapstructure:"retention_days"`
+	} `mapstructure:"drafts"`
+	Favorites struct {
+		Backend string `mapstructure:"backend"`
+	} `mapstructure:"favorites"`
+	Collab struct {
+		IdleSeconds int `mapstructure:"idle_seconds"`
+	} `mapstructure:"collab"`
+	Presence struct {
+		TTLSeconds int  `mapstructure:"ttl_seconds"`
+		SoftLock   bool `mapstructure:"soft_lock"`
+	} `mapstructure:"presence"`
+	Notify struct {
+		BaseURL      string `mapstructure:"base_url"`
+		BatchMinutes int    `mapstructure:"batch_minutes"`
+		DigestHour   int    `mapstructure:"digest_hour"`
+		SMTP         struct {
+			Host     string `mapstructure:"host"`
+			Port     int    `mapstructure:"port"`
+			Username string `mapstructure:"username"`
+			Password string `mapstructure:"password"`
+			From     string `mapstructure:"from"`
+		} `mapstructure:"smtp"`
+	} `mapstructure:"notify"`
+	Webhooks struct {
+		TimeoutSeconds int `mapstructure:"timeout_seconds"`
+		MaxAttempts    int `mapstructure:"max_attempts"`
+		HistorySize    int `mapstructure:"history_size"`
+	} `mapstructure:"webhooks"`
+	Analytics struct {
+		RetentionDays int `mapstructure:"retention_days"`
+		PopularDays   int `mapstructure:"popular_days"`
+	} `mapstructure:"analytics"`
+	Changes struct {
+		JournalSize  int `mapstructure:"journal_size"`
+		CacheSeconds int `mapstructure:"cache_seconds"`
+	} `mapstructure:"changes"`
+}
+
+// AccessRule assigns a role to users whose email matches a pattern such as
+// "alice@example.com", "*@example.com" or "*"
+type AccessRule struct {
+	Match string `mapstructure:"match"`
+	Role  string `mapstructure:"role"`
+}
+
+// FolderACL overrides roles for a folder and all of its children
+type FolderACL struct {
+	Path    string       `mapstructure:"path"`
+	Default string       `mapstructure:"default"`
+	Rules   []AccessRule `mapstructure:"rules"`
+}
+
+// OAuthProvider configures an OAuth login provider
+type OAuthProvider struct {
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	RedirectURL  string `mapstructure:"redirect_url"`
+}
+
+// OIDCProvider configures an OpenID Connect login provider
+type OIDCProvider struct {
+	ID           string   `mapstructure:"id"`
+	Name         string   `mapstructure:"name"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+	EmailClaim   string   `mapstructure:"email_claim"`
+	NameClaim    string   `mapstructure:"name_claim"`
+}
+
+// RateLimit is a token bucket allowing Burst requests at once, refilled at
+// PerMinute requests a minute
+type RateLimit struct {
+	PerMinute int `mapstructure:"per_minute"`
+	Burst     int `mapstructure:"burst"`
 }
 
 var AppConfig Config
@@ -61,6 +196,75 @@ func LoadConfig() error {
 		return fmt.Errorf("failed to unmarshal config: %v", err)
 	}
 
+	// Keep application state (tokens, logs, ...) outside the synced data directory
+	if AppConfig.Server.StateDir == "" {
+		AppConfig.Server.StateDir = "./state"
+	}
+
+	// Keep sessions in signed cookies unless server-side sessions are enabled
+	if AppConfig.Session.Backend == "" {
+		AppConfig.Session.Backend = "cookie"
+	}
+
+	// Sign out after an hour idle or a day after signing in, warn 5 minutes
+	// ahead; remember me stays off unless remember_days is set
+	if AppConfig.Session.IdleMinutes == 0 {
+		AppConfig.Session.IdleMinutes = 60
+	}
+	if AppConfig.Session.MaxAgeHours == 0 {
+		AppConfig.Session.MaxAgeHours = 24
+	}
+	if AppConfig.Session.WarnMinutes == 0 {
+		AppConfig.Session.WarnMinutes = 5
+	}
+
+	// OpenID Connect providers read the standard claims unless configured otherwise
+	for i := range AppConfig.Auth.OIDC {
+		provider := &AppConfig.Auth.OIDC[i]
+		if provider.Name == "" {
+			provider.Name = provider.ID
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		if provider.EmailClaim == "" {
+			provider.EmailClaim = "email"
+		}
+		if provider.NameClaim == "" {
+			provider.NameClaim = "name"
+		}
+	}
+
+	// The old per-section email lists feed the login policy's allowlist
+	for _, legacy := range []struct {
+		key    string
+		emails []string
+	}{
+		{"session.allowed_emails", AppConfig.Session.AllowedEmails},
+		{"google.allowed_emails", AppConfig.Google.AllowedEmails},
+	} {
+		if len(legacy.emails) > 0 {
+			log.Printf("Warning: %s is deprecated, move its entries to login.allow", legacy.key)
+			AppConfig.Login.Allow = append(AppConfig.Login.Allow, legacy.emails...)
+		}
+	}
+	if AppConfig.Login.GroupsClaim == "" {
+		AppConfig.Login.GroupsClaim = "groups"
+	}
+
+	// Local accounts live in the state directory. After five wrong passwords
+	// each further try on an account is delayed, doubling up to 15 minutes,
+	// and a client address is locked out for 15 minutes after twenty.
+	if AppConfig.Auth.Local.File == "" {
+		AppConfig.Auth.Local.File = filepath.Join(AppConfig.Server.StateDir, "users.json")
+	}
+	if AppConfig.Auth.Local.MaxAttempts == 0 {
+		AppConfig.Auth.Local.MaxAttempts = 5
+	}
+	if AppConfig.Auth.Local.LockoutMinutes == 0 {
+		AppConfig.Auth.Local.LockoutMinutes = 15
+	}
+
 	// Set default Redis values if not specified
 	if AppConfig.Redis.Address == "" {
 		AppConfig.Redis.Address = "localhost:6379"
@@ -75,6 +279,122 @@ func LoadConfig() error {
 		AppConfig.Redis.ExpirationSeconds = 900 // 15 minutes default
 	}
 
+	// Authenticated users can edit unless configured otherwise
+	if AppConfig.Access.DefaultRole == "" {
+		AppConfig.Access.DefaultRole = "editor"
+	}
+	for i := range AppConfig.Access.Folders {
+		if AppConfig.Access.Folders[i].Default == "" {
+			AppConfig.Access.Folders[i].Default = "none"
+		}
+	}
+	for i, folder := range AppConfig.Access.PublicFolders {
+		AppConfig.Access.PublicFolders[i] = strings.Trim(folder, "/")
+	}
+
+	// Rotate the audit log at 10 MB and keep the last 10 rotated files
+	if AppConfig.Audit.MaxSizeMB == 0 {
+		AppConfig.Audit.MaxSizeMB = 10
+	}
+	if AppConfig.Audit.MaxFiles == 0 {
+		AppConfig.Audit.MaxFiles = 10
+	}
+	if AppConfig.Audit.RedisLength == 0 {
+		AppConfig.Audit.RedisLength = 1000
+	}
+
+	// Keep deleted pages for 30 days; a negative value keeps them until purged
+	if AppConfig.Trash.RetentionDays == 0 {
+		AppConfig.Trash.RetentionDays = 30
+	}
+
+	// Sign share links with a key derived from the session secret unless given
+	// their own, so one key never signs both session cookies and share links,
+	// and let them live for 3 days by default and 30 days at most
+	if AppConfig.Share.Secret == "" {
+		AppConfig.Share.Secret = deriveSecret(AppConfig.Session.Secret, "share-links")
+	}
+	if AppConfig.Share.DefaultHours == 0 {
+		AppConfig.Share.DefaultHours = 72
+	}
+	if AppConfig.Share.MaxDays == 0 {
+		AppConfig.Share.MaxDays = 30
+	}
+
+	// Throttle sign-in hardest and page views least; a negative per_minute
+	// turns a group's limit off
+	setRateLimitDefaults(&AppConfig.RateLimit.Auth, 10, 5)
+	setRateLimitDefaults(&AppConfig.RateLimit.API, 120, 30)
+	setRateLimitDefaults(&AppConfig.RateLimit.Web, 300, 60)
+
+	// Keep autosaved drafts on disk for 30 days unless configured otherwise
+	if AppConfig.Drafts.Backend == "" {
+		AppConfig.Drafts.Backend = "file"
+	}
+	if AppConfig.Drafts.RetentionDays == 0 {
+		AppConfig.Drafts.RetentionDays = 30
+	}
+
+	// Keep favorites and recently viewed pages in files by default
+	if AppConfig.Favorites.Backend == "" {
+		AppConfig.Favorites.Backend = "file"
+	}
+
+	// Save collaborative sessions after 15 seconds without edits
+	if AppConfig.Collab.IdleSeconds == 0 {
+		AppConfig.Collab.IdleSeconds = 15
+	}
+
+	// Forget editors a minute after their last heartbeat
+	if AppConfig.Presence.TTLSeconds == 0 {
+		AppConfig.Presence.TTLSeconds = 60
+	}
+
+	// Batch immediate notifications for 5 minutes, send daily digests at 08:00
+	if AppConfig.Notify.BaseURL == "" {
+		AppConfig.Notify.BaseURL = fmt.Sprintf("http://localhost:%s", AppConfig.Server.Port)
+	}
+	AppConfig.Notify.BaseURL = strings.TrimSuffix(AppConfig.Notify.BaseURL, "/")
+	if AppConfig.Notify.BatchMinutes == 0 {
+		AppConfig.Notify.BatchMinutes = 5
+	}
+	if AppConfig.Notify.DigestHour == 0 {
+		AppConfig.Notify.DigestHour = 8
+	}
+	if AppConfig.Notify.SMTP.Port == 0 {
+		AppConfig.Notify.SMTP.Port = 25
+	}
+	if AppConfig.Notify.SMTP.From == "" {
+		AppConfig.Notify.SMTP.From = "wiki@localhost"
+	}
+
+	// Give webhook receivers 10 seconds, try each delivery 5 times
+	if AppConfig.Webhooks.TimeoutSeconds == 0 {
+		AppConfig.Webhooks.TimeoutSeconds = 10
+	}
+	if AppConfig.Webhooks.MaxAttempts == 0 {
+		AppConfig.Webhooks.MaxAttempts = 5
+	}
+	if AppConfig.Webhooks.HistorySize == 0 {
+		AppConfig.Webhooks.HistorySize = 500
+	}
+
+	// Keep 1000 journal entries, cache GitHub history for 2 minutes
+	if AppConfig.Changes.JournalSize == 0 {
+		AppConfig.Changes.JournalSize = 1000
+	}
+	if AppConfig.Changes.CacheSeconds == 0 {
+		AppConfig.Changes.CacheSeconds = 120
+	}
+
+	// Keep daily view counts for 90 days, rank popular pages over 30
+	if AppConfig.Analytics.RetentionDays == 0 {
+		AppConfig.Analytics.RetentionDays = 90
+	}
+	if AppConfig.Analytics.PopularDays == 0 {
+		AppConfig.Analytics.PopularDays = 30
+	}
+
 	// Set default Wiki values if not specified
 	if AppConfig.Wiki.MaxCategoryLevel == 0 {
 		AppConfig.Wiki.MaxCategoryLevel = 4 // Default to 4 levels
@@ -83,6 +403,27 @@ func LoadConfig() error {
 	return nil
 }
 
+// setRateLimitDefaults fills in a rate limit left unset
+func setRateLimitDefaults(limit *RateLimit, perMinute, burst int) {
+	if limit.PerMinute == 0 {
+		limit.PerMinute = perMinute
+	}
+	if limit.Burst == 0 {
+		limit.Burst = burst
+	}
+}
+
+// deriveSecret returns a key for one purpose derived from a shared secret, so
+// that a value signed for one purpose is never valid for another
+func deriveSecret(secret, purpose string) string {
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // GetConfig returns the current configuration
 func GetConfig() *Config {
 	return &AppConfig
@@ -0,0 +1,191 @@
+package drafts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Draft is a user's unpublished copy of a page
+type Draft struct {
+	Key       string    `json:"key"`
+	Folder    string    `json:"folder"`
+	OldTitle  string    `json:"old_title"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Key identifies the page a draft belongs to. Drafts of new pages are keyed
+// by folder so each user has one new-page draft per folder.
+func Key(folder, oldTitle string) string {
+	if oldTitle == "" {
+		return "new:" + folder
+	}
+	if folder == "" {
+		return oldTitle
+	}
+	return folder + "/" + oldTitle
+}
+
+// Store keeps drafts per user, outside of the wiki storage
+type Store interface {
+	Get(email, key string) (*Draft, error)
+	Save(email string, draft *Draft) error
+	Delete(email, key string) error
+}
+
+// hashKey turns an arbitrary string into a safe file or key name
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:16])
+}
+
+// FileStore keeps drafts as JSON files under a directory
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+	ttl time.Duration
+}
+
+// NewFileStore creates a draft store in the given directory. Drafts older than
+// ttl are ignored and removed when read.
+func NewFileStore(dir string, ttl time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create drafts directory: %v", err)
+	}
+	log.Printf("Storing drafts in %s", dir)
+	return &FileStore{dir: dir, ttl: ttl}, nil
+}
+
+// path returns the file holding a user's draft
+func (s *FileStore) path(email, key string) string {
+	return filepath.Join(s.dir, hashKey(strings.ToLower(email)), hashKey(key)+".json")
+}
+
+// Get returns the user's draft, or nil if there is none
+func (s *FileStore) Get(email, key string) (*Draft, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := s.path(email, key)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read draft: %v", err)
+	}
+
+	var draft Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, fmt.Errorf("failed to parse draft: %v", err)
+	}
+	if s.ttl > 0 && time.Since(draft.UpdatedAt) > s.ttl {
+		os.Remove(file)
+		return nil, nil
+	}
+	return &draft, nil
+}
+
+// Save writes the user's draft, replacing any previous one
+func (s *FileStore) Save(email string, draft *Draft) error {
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return fmt.Errorf("failed to encode draft: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := s.path(email, draft.Key)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %v", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write draft: %v", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to save draft: %v", err)
+	}
+	return nil
+}
+
+// Delete removes the user's draft if there is one
+func (s *FileStore) Delete(email, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(email, key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete draft: %v", err)
+	}
+	return nil
+}
+
+// RedisStore keeps drafts in Redis with an expiry
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisStore creates a draft store backed by Redis
+func NewRedisStore(client *redis.Client, ttl time.Duration) (*RedisStore, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	log.Printf("Storing drafts in Redis")
+	return &RedisStore{client: client, ttl: ttl}, nil
+}
+
+// redisKey returns the Redis key of a user's draft
+func redisKey(email, key string) string {
+	return "draft:" + hashKey(strings.ToLower(email)) + ":" + hashKey(key)
+}
+
+// Get returns the user's draft, or nil if there is none
+func (s *RedisStore) Get(email, key string) (*Draft, error) {
+	data, err := s.client.Get(context.Background(), redisKey(email, key)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read draft: %v", err)
+	}
+
+	var draft Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, fmt.Errorf("failed to parse draft: %v", err)
+	}
+	return &draft, nil
+}
+
+// Save writes the user's draft, replacing any previous one
+func (s *RedisStore) Save(email string, draft *Draft) error {
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return fmt.Errorf("failed to encode draft: %v", err)
+	}
+	if err := s.client.Set(context.Background(), redisKey(email, draft.Key), data, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save draft: %v", err)
+	}
+	return nil
+}
+
+// Delete removes the user's draft if there is one
+func (s *RedisStore) Delete(email, key string) error {
+	if err := s.client.Del(context.Background(), redisKey(email, key)).Err(); err != nil {
+		return fmt.Errorf("failed to delete draft: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package favorites
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// MaxRecent is how many recently viewed pages are kept per user
+	MaxRecent = 20
+
+	// MaxFavorites caps a user's favorites so the sidebar stays usable
+	MaxFavorites = 200
+)
+
+// Item is a starred page or folder, or a recently viewed page. Time is when
+// it was starred or last viewed.
+type Item struct {
+	Path   string    `json:"path"`
+	Folder bool      `json:"folder,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// same reports whether two items point at the same page or folder
+func (i Item) same(path string, folder bool) bool {
+	return i.Path == path && i.Folder == folder
+}
+
+// Store keeps each user's favorites and recently viewed pages, outside of
+// the wiki storage
+type Store interface {
+	// Favorites returns the user's favorites in the order they were starred
+	Favorites(email string) ([]Item, error)
+	// AddFavorite stars a page or folder; starring it again is a no-op
+	AddFavorite(email, path string, folder bool) error
+	// RemoveFavorite unstars a page or folder
+	RemoveFavorite(email, path string, folder bool) error
+	// RecordView puts a page at the top of the user's recently viewed pages
+	RecordView(email, path string) error
+	// RecentlyViewed returns the user's last viewed pages, newest first
+	RecentlyViewed(email string) ([]Item, error)
+}
+
+// hashKey turns an email into a safe file or key name
+func hashKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// addItem appends an item unless it is already in the list
+func addItem(items []Item, path string, folder bool) ([]Item, error) {
+	for _, item := range items {
+		if item.same(path, folder) {
+			return items, nil
+		}
+	}
+	if len(items) >= MaxFavorites {
+		return items, fmt.Errorf("you can have at most %d favorites", MaxFavorites)
+	}
+	return append(items, Item{Path: path, Folder: folder, Time: time.Now().UTC()}), nil
+}
+
+// removeItem drops an item from the list
+func removeItem(items []Item, path string, folder bool) []Item {
+	kept := items[:0]
+	for _, item := range items {
+		if !item.same(path, folder) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// userFile is what the file store keeps for one user
+type userFile struct {
+	Favorites []Item `json:"favorites"`
+	Recent    []Item `json:"recent"`
+}
+
+// FileStore keeps one JSON file per user under a directory
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore creates a favorites store in the given directory
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create favorites directory: %v", err)
+	}
+	log.Printf("Storing favorites in %s", dir)
+	return &FileStore{dir: dir}, nil
+}
+
+// load reads a user's file. The caller must hold the lock.
+func (s *FileStore) load(email string) (*userFile, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, hashKey(email)+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &userFile{}, nil
+		}
+		return nil, fmt.Errorf("failed to read favorites: %v", err)
+	}
+
+	var f userFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse favorites: %v", err)
+	}
+	return &f, nil
+}
+
+// save writes a user's file. The caller must hold the lock.
+func (s *FileStore) save(email string, f *userFile) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode favorites: %v", err)
+	}
+	file := filepath.Join(s.dir, hashKey(email)+".json")
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write favorites: %v", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to save favorites: %v", err)
+	}
+	return nil
+}
+
+// update loads a user's file, applies change and writes it back
+func (s *FileStore) update(email string, change func(f *userFile) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load(email)
+	if err != nil {
+		return err
+	}
+	if err := change(f); err != nil {
+		return err
+	}
+	return s.save(email, f)
+}
+
+// Favorites returns the user's favorites
+func (s *FileStore) Favorites(email string) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load(email)
+	if err != nil {
+		return nil, err
+	}
+	return f.Favorites, nil
+}
+
+// AddFavorite stars a page or folder
+func (s *FileStore) AddFavorite(email, path string, folder bool) error {
+	return s.update(email, func(f *userFile) error {
+		var err error
+		f.Favorites, err = addItem(f.Favorites, path, folder)
+		return err
+	})
+}
+
+// RemoveFavorite unstars a page or folder
+func (s *FileStore) RemoveFavorite(email, path string, folder bool) error {
+	return s.update(email, func(f *userFile) error {
+		f.Favorites = removeItem(f.Favorites, path, folder)
+		return nil
+	})
+}
+
+// RecordView puts a page at the top of the user's recently viewed pages
+func (s *FileStore) RecordView(email, path string) error {
+	return s.update(email, func(f *userFile) error {
+		recent := append([]Item{{Path: path, Time: time.Now().UTC()}}, removeItem(f.Recent, path, false)...)
+		if len(recent) > MaxRecent {
+			recent = recent[:MaxRecent]
+		}
+		f.Recent = recent
+		return nil
+	})
+}
+
+// RecentlyViewed returns the user's last viewed pages
+func (s *FileStore) RecentlyViewed(email string) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.load(email)
+	if err != nil {
+		return nil, err
+	}
+	return f.Recent, nil
+}
+
+// RedisStore keeps favorites in a Redis hash and recently viewed pages in a
+// capped Redis list, both keyed by the user's email
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a favorites store backed by Redis
+func NewRedisStore(client *redis.Client) (*RedisStore, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	log.Printf("Storing favorites in Redis")
+	return &RedisStore{client: client}, nil
+}
+
+// favoriteField names an item inside the favorites hash
+func favoriteField(path string, folder bool) string {
+	if folder {
+		return "folder:" + path
+	}
+	return "page:" + path
+}
+
+// Favorites returns the user's favorites in the order they were starred
+func (s *RedisStore) Favorites(email string) ([]Item, error) {
+	fields, err := s.client.HGetAll(context.Background(), "favorites:"+hashKey(email)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read favorites: %v", err)
+	}
+
+	items := make([]Item, 0, len(fields))
+	for _, data := range fields {
+		var item Item
+		if err := json.Unmarshal([]byte(data), &item); err == nil {
+			items = append(items, item)
+		}
+	}
+	sortByTime(items)
+	return items, nil
+}
+
+// AddFavorite stars a page or folder
+func (s *RedisStore) AddFavorite(email, path string, folder bool) error {
+	ctx := context.Background()
+	key := "favorites:" + hashKey(email)
+	count, err := s.client.HLen(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read favorites: %v", err)
+	}
+	if count >= MaxFavorites {
+		return fmt.Errorf("you can have at most %d favorites", MaxFavorites)
+	}
+
+	data, err := json.Marshal(Item{Path: path, Folder: folder, Time: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to encode favorite: %v", err)
+	}
+	if err := s.client.HSetNX(ctx, key, favoriteField(path, folder), data).Err(); err != nil {
+		return fmt.Errorf("failed to save favorite: %v", err)
+	}
+	return nil
+}
+
+// RemoveFavorite unstars a page or folder
+func (s *RedisStore) RemoveFavorite(email, path string, folder bool) error {
+	if err := s.client.HDel(context.Background(), "favorites:"+hashKey(email), favoriteField(path, folder)).Err(); err != nil {
+		return fmt.Errorf("failed to remove favorite: %v", err)
+	}
+	return nil
+}
+
+// RecordView puts a page at the top of the user's recently viewed pages. The
+// list holds paths only, their view times live in a companion hash.
+func (s *RedisStore) RecordView(email, path string) error {
+	ctx := context.Background()
+	key := "recent:" + hashKey(email)
+	pipe := s.client.TxPipeline()
+	pipe.LRem(ctx, key, 0, path)
+	pipe.LPush(ctx, key, path)
+	pipe.LTrim(ctx, key, 0, MaxRecent-1)
+	pipe.HSet(ctx, key+":times", path, time.Now().UTC().Format(time.RFC3339))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record view: %v", err)
+	}
+	return nil
+}
+
+// RecentlyViewed returns the user's last viewed pages, newest first
+func (s *RedisStore) RecentlyViewed(email string) ([]Item, error) {
+	ctx := context.Background()
+	key := "recent:" + hashKey(email)
+	paths, err := s.client.LRange(ctx, key, 0, MaxRecent-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recently viewed pages: %v", err)
+	}
+	times, err := s.client.HGetAll(ctx, key+":times").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recently viewed pages: %v", err)
+	}
+
+	items := make([]Item, 0, len(paths))
+	kept := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		item := Item{Path: path}
+		item.Time, _ = time.Parse(time.RFC3339, times[path])
+		items = append(items, item)
+		kept[path] = true
+	}
+
+	// Forget the view times of pages that fell off the list
+	var stale []string
+	for path := range times {
+		if !kept[path] {
+			stale = append(stale, path)
+		}
+	}
+	if len(stale) > 0 {
+		s.client.HDel(ctx, key+":times", stale...)
+	}
+	return items, nil
+}
+
+// sortByTime orders items oldest first
+func sortByTime(items []Item) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Time.Before(items[j].Time)
+	})
+}
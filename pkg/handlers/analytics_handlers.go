@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/analytics"
+	"github.com/gin-gonic/gin"
+)
+
+// popularLimit is how many pages the "popular in this folder" block shows
+const popularLimit = 5
+
+var (
+	viewTracker analytics.Tracker
+	popularDays int
+)
+
+// InitAnalyticsHandlers sets the tracker counting page views and the number
+// of days popular pages are ranked over
+func InitAnalyticsHandlers(t analytics.Tracker, days int) {
+	viewTracker = t
+	popularDays = days
+}
+
+// recordPageView counts a view of a page by the current user
+func recordPageView(c *gin.Context, pagePath string) {
+	if viewTracker == nil {
+		return
+	}
+	if err := viewTracker.RecordView(pagePath, currentUser(c).Email); err != nil {
+		log.Printf("Error recording page view of %s: %v", pagePath, err)
+	}
+}
+
+// popularPage is a page in the "popular in this folder" block
+type popularPage struct {
+	Path  string
+	Name  string
+	URL   string
+	Views int64
+}
+
+// popularInFolder returns the most read pages under a folder that the
+// current user can see and that still exist
+func popularInFolder(c *gin.Context, folder string) []popularPage {
+	if viewTracker == nil {
+		return nil
+	}
+
+	// Ask for extra pages since some may be hidden or deleted
+	top, err := viewTracker.TopPages(folder, popularDays, popularLimit*4)
+	if err != nil {
+		log.Printf("Error loading popular pages in %q: %v", folder, err)
+		return nil
+	}
+
+	var pages []popularPage
+	for _, stats := range top {
+		if !can(c, stats.Path, access.RoleViewer) {
+			continue
+		}
+		if _, err := store.GetPage(stats.Path); err != nil {
+			continue
+		}
+		pages = append(pages, popularPage{
+			Path:  stats.Path,
+			Name:  getNameFromPath(stats.Path),
+			URL:   pageURL(stats.Path),
+			Views: stats.Views,
+		})
+		if len(pages) == popularLimit {
+			break
+		}
+	}
+	return pages
+}
+
+// topPage is a row of the most read pages on the analytics page
+type topPage struct {
+	analytics.PageStats
+	URL string
+}
+
+// dayBar is a day on the analytics page, with its bar width in percent
+type dayBar struct {
+	analytics.DayStats
+	Percent int
+}
+
+// AnalyticsPageHandler shows wiki-wide views per day and the most read pages
+func AnalyticsPageHandler(c *gin.Context) {
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days <= 0 {
+		days = popularDays
+	}
+	days = min(days, 365)
+	folder := FolderQueryFromRequest(c)
+
+	var daily []dayBar
+	var top []topPage
+	if viewTracker != nil {
+		stats, err := viewTracker.Daily(days)
+		if err != nil {
+			log.Printf("Error loading daily views: %v", err)
+		}
+		var most int64
+		for _, day := range stats {
+			most = max(most, day.Views)
+		}
+		for _, day := range stats {
+			bar := dayBar{DayStats: day}
+			if most > 0 {
+				bar.Percent = int(day.Views * 100 / most)
+			}
+			daily = append(daily, bar)
+		}
+
+		pages, err := viewTracker.TopPages(folder, days, 50)
+		if err != nil {
+			log.Printf("Error loading top pages: %v", err)
+		}
+		for _, stats := range pages {
+			top = append(top, topPage{PageStats: stats, URL: pageURL(stats.Path)})
+		}
+	}
+
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	folders, err := store.ListFolders()
+	if err != nil {
+		log.Printf("Error listing folders: %v", err)
+	}
+
+	c.HTML(http.StatusOK, "analytics.html", gin.H{
+		"Title":      "Analytics",
+		"Days":       days,
+		"Folder":     folder,
+		"Folders":    folders,
+		"Daily":      daily,
+		"TopPages":   top,
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       currentUser(c),
+	})
+}
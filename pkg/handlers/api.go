@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/audit"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/trash"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Default and maximum page sizes for paginated API lists
+	defaultPerPage = 50
+	maxPerPage     = 200
+)
+
+// APIPage is the JSON representation of a page in the v1 API
+type APIPage struct {
+	Path    string `json:"path"`
+	Title   string `json:"title"`
+	Folder  string `json:"folder"`
+	Content string `json:"content,omitempty"`
+	ETag    string `json:"etag"`
+}
+
+// APIFolder is the JSON representation of a folder in the v1 API
+type APIFolder struct {
+	Path     string      `json:"path"`
+	Name     string      `json:"name"`
+	Parent   string      `json:"parent"`
+	Children []APIFolder `json:"children,omitempty"`
+}
+
+// APIPagination describes the page of results returned by a list endpoint
+type APIPagination struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// apiJSON writes a JSON payload with a strong ETag computed from the body and
+// answers conditional GETs with 304 Not Modified
+func apiJSON(c *gin.Context, status int, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Failed to encode response: %v", err))
+		return
+	}
+
+	etag := `"` + hashContent(data) + `"`
+	c.Header("ETag", etag)
+	if c.Request.Method == http.MethodGet && etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(status, "application/json; charset=utf-8", data)
+}
+
+// hashContent returns the hex encoded SHA-256 of the given content
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// pageETag returns the quoted ETag for a page body
+func pageETag(content string) string {
+	return `"` + hashContent([]byte(content)) + `"`
+}
+
+// etagMatches reports whether an If-Match / If-None-Match header matches the ETag
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanAPIPath normalizes a wiki path from the API, rejecting traversal attempts
+func cleanAPIPath(raw string) (string, error) {
+	path := strings.Trim(strings.TrimSpace(raw), "/")
+	path = strings.TrimSuffix(path, ".txt")
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid path %q", raw)
+		}
+	}
+	return path, nil
+}
+
+// paginate parses page/per_page query parameters and returns the bounds to slice
+func paginate(c *gin.Context, total int) (APIPagination, int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	totalPages := (total + perPage - 1) / perPage
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	return APIPagination{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}, start, end
+}
+
+// toAPIPage converts a storage page into its API representation
+func toAPIPage(page *types.Page, includeContent bool) APIPage {
+	path := strings.TrimSuffix(page.Path, ".txt")
+	result := APIPage{
+		Path:   path,
+		Title:  page.Title,
+		Folder: getParentPath(path),
+		ETag:   pageETag(page.Content),
+	}
+	if includeContent {
+		result.Content = page.Content
+	}
+	return result
+}
+
+// APIListPagesHandler lists pages, optionally restricted to a single folder
+func APIListPagesHandler(c *gin.Context) {
+	folder, err := access.CleanPath(strings.Trim(c.Query("folder"), "/"))
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	var pages []types.Page
+	if folder != "" {
+		pages, err = store.GetPagesInFolder(folder)
+	} else {
+		pages, err = store.ListPages()
+	}
+	if err != nil {
+		log.Printf("API: error listing pages: %v", err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to list pages: %v", err))
+		return
+	}
+
+	pages = filterPages(pages, viewFilter(c))
+	sort.Slice(pages, func(i, j int) bool {
+		return strings.ToLower(pages[i].Path) < strings.ToLower(pages[j].Path)
+	})
+
+	pagination, start, end := paginate(c, len(pages))
+	data := make([]APIPage, 0, end-start)
+	for i := start; i < end; i++ {
+		data = append(data, toAPIPage(&pages[i], false))
+	}
+
+	apiJSON(c, http.StatusOK, gin.H{
+		"data":       data,
+		"pagination": pagination,
+	})
+}
+
+// APIGetPageHandler returns a single page with its content
+func APIGetPageHandler(c *gin.Context) {
+	path, err := cleanAPIPath(c.Param("path"))
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	page, err := store.GetPage(path)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusNotFound, "not_found", fmt.Sprintf("Page %q not found", path))
+		return
+	}
+
+	c.Header("ETag", pageETag(page.Content))
+	if etagMatches(c.GetHeader("If-None-Match"), pageETag(page.Content)) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": toAPIPage(page, true)})
+}
+
+// APICreatePageHandler creates a new page and fails if it already exists
+func APICreatePageHandler(c *gin.Context) {
+	var requestBody struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+		Summary string `json:"summary"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_body", fmt.Sprintf("Failed to parse request: %v", err))
+		return
+	}
+
+	path, err := cleanAPIPath(requestBody.Path)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	if !can(c, path, access.RoleEditor) {
+		auth.AbortAPI(c, http.StatusForbidden, "forbidden", fmt.Sprintf("You need the editor role on %q", path))
+		return
+	}
+	if _, err := store.GetPage(path); err == nil {
+		auth.AbortAPI(c, http.StatusConflict, "already_exists", fmt.Sprintf("Page %q already exists", path))
+		return
+	}
+
+	if folder := getParentPath(path); folder != "" && !folderExists(folder) {
+		auth.AbortAPI(c, http.StatusUnprocessableEntity, "folder_not_found", fmt.Sprintf("Folder %q does not exist", folder))
+		return
+	}
+
+	page := &types.Page{
+		Title:   getNameFromPath(path),
+		Path:    path,
+		Content: requestBody.Content,
+		Body:    []byte(requestBody.Content),
+	}
+	if err := storeFor(c, requestBody.Summary).CreatePage(page); err != nil {
+		log.Printf("API: error creating page %s: %v", path, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to create page: %v", err))
+		return
+	}
+
+	log.Printf("API: created page %s", path)
+	recordAudit(c, audit.Entry{
+		Action:    audit.ActionPageCreate,
+		Path:      path,
+		AfterHash: auditHash(&page.Content),
+	})
+	pageChanged(c, notify.ActionCreated, path, "", nil, &page.Content)
+	c.Header("ETag", pageETag(page.Content))
+	c.Header("Location", "/api/v1/pages/"+path)
+	c.JSON(http.StatusCreated, gin.H{"data": toAPIPage(page, true)})
+}
+
+// APIUpdatePageHandler replaces the content of an existing page. An If-Match
+// header guards against overwriting concurrent edits.
+func APIUpdatePageHandler(c *gin.Context) {
+	path, err := cleanAPIPath(c.Param("path"))
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	var requestBody struct {
+		Content string `json:"content"`
+		Summary string `json:"summary"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_body", fmt.Sprintf("Failed to parse request: %v", err))
+		return
+	}
+
+	existing, err := store.GetPage(path)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusNotFound, "not_found", fmt.Sprintf("Page %q not found", path))
+		return
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagMatches(ifMatch, pageETag(existing.Content)) {
+		auth.AbortAPI(c, http.StatusPreconditionFailed, "etag_mismatch", "Page has been modified since it was fetched")
+		return
+	}
+
+	page := &types.Page{
+		Title:   existing.Title,
+		Path:    existing.Path,
+		Content: requestBody.Content,
+		Body:    []byte(requestBody.Content),
+	}
+	if err := storeFor(c, requestBody.Summary).UpdatePage(page); err != nil {
+		log.Printf("API: error updating page %s: %v", path, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to update page: %v", err))
+		return
+	}
+
+	log.Printf("API: updated page %s", path)
+	recordAudit(c, audit.Entry{
+		Action:     audit.ActionPageUpdate,
+		Path:       path,
+		BeforeHash: auditHash(&existing.Content),
+		AfterHash:  auditHash(&page.Content),
+	})
+	pageChanged(c, notify.ActionUpdated, path, "", &existing.Content, &page.Content)
+	c.Header("ETag", pageETag(page.Content))
+	c.JSON(http.StatusOK, gin.H{"data": toAPIPage(page, true)})
+}
+
+// APIMovePageHandler moves (renames) a page to a new path
+func APIMovePageHandler(c *gin.Context) {
+	path, err := cleanAPIPath(c.Param("path"))
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	var requestBody struct {
+		Destination string `json:"destination"`
+		Summary     string `json:"summary"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_body", fmt.Sprintf("Failed to parse request: %v", err))
+		return
+	}
+	destination, err := cleanAPIPath(requestBody.Destination)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_destination", err.Error())
+		return
+	}
+
+	if !can(c, destination, access.RoleEditor) {
+		auth.AbortAPI(c, http.StatusForbidden, "forbidden", fmt.Sprintf("You need the editor role on %q", destination))
+		return
+	}
+
+	existing, err := store.GetPage(path)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusNotFound, "not_found", fmt.Sprintf("Page %q not found", path))
+		return
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagMatches(ifMatch, pageETag(existing.Content)) {
+		auth.AbortAPI(c, http.StatusPreconditionFailed, "etag_mismatch", "Page has been modified since it was fetched")
+		return
+	}
+	if destination == path {
+		c.JSON(http.StatusOK, gin.H{"data": toAPIPage(existing, true)})
+		return
+	}
+	if _, err := store.GetPage(destination); err == nil {
+		auth.AbortAPI(c, http.StatusConflict, "already_exists", fmt.Sprintf("Page %q already exists", destination))
+		return
+	}
+	if folder := getParentPath(destination); folder != "" && !folderExists(folder) {
+		auth.AbortAPI(c, http.StatusUnprocessableEntity, "folder_not_found", fmt.Sprintf("Folder %q does not exist", folder))
+		return
+	}
+
+	moved := &types.Page{
+		Title:   getNameFromPath(destination),
+		Path:    destination,
+		Content: existing.Content,
+		Body:    []byte(existing.Content),
+	}
+	if err := renamePage(c, storeFor(c, requestBody.Summary), existing, moved); err != nil {
+		log.Printf("API: error moving page %s to %s: %v", path, destination, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to move page: %v", err))
+		return
+	}
+
+	log.Printf("API: moved page %s to %s", path, destination)
+	recordAudit(c, audit.Entry{
+		Action:     audit.ActionPageMove,
+		Path:       destination,
+		OldPath:    path,
+		BeforeHash: auditHash(&existing.Content),
+		AfterHash:  auditHash(&moved.Content),
+	})
+	moveComments(c, path, destination)
+	pageChanged(c, notify.ActionMoved, destination, path, &existing.Content, &moved.Content)
+	c.Header("ETag", pageETag(moved.Content))
+	c.Header("Location", "/api/v1/pages/"+destination)
+	c.JSON(http.StatusOK, gin.H{"data": toAPIPage(moved, true)})
+}
+
+// APIDeletePageHandler deletes a page
+func APIDeletePageHandler(c *gin.Context) {
+	path, err := cleanAPIPath(c.Param("path"))
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	existing, err := store.GetPage(path)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusNotFound, "not_found", fmt.Sprintf("Page %q not found", path))
+		return
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagMatches(ifMatch, pageETag(existing.Content)) {
+		auth.AbortAPI(c, http.StatusPreconditionFailed, "etag_mismatch", "Page has been modified since it was fetched")
+		return
+	}
+
+	trashed, err := moveToTrash(c, trash.KindPage, path)
+	if err != nil {
+		log.Printf("API: error moving page %s to trash: %v", path, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "trash_error", fmt.Sprintf("Failed to move page to trash: %v", err))
+		return
+	}
+	if err := storeFor(c, "").DeletePage(existing.Path); err != nil {
+		discardTrashItem(trashed)
+		log.Printf("API: error deleting page %s: %v", path, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to delete page: %v", err))
+		return
+	}
+
+	log.Printf("API: deleted page %s", path)
+	deleteComments(c, path)
+	recordAudit(c, audit.Entry{
+		Action:     audit.ActionPageDelete,
+		Path:       path,
+		BeforeHash: auditHash(&existing.Content),
+	})
+	pageChanged(c, notify.ActionDeleted, path, "", &existing.Content, nil)
+	c.Status(http.StatusNoContent)
+}
+
+// APIListFoldersHandler lists all folders as a flat, paginated list
+func APIListFoldersHandler(c *gin.Context) {
+	folders, err := store.ListFolders()
+	if err != nil {
+		log.Printf("API: error listing folders: %v", err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to list folders: %v", err))
+		return
+	}
+	folders = filterFolders(folders, viewFilter(c))
+
+	if parent := c.Query("parent"); parent != "" {
+		parent = strings.Trim(parent, "/")
+		var filtered []string
+		for _, folder := range folders {
+			if getParentPath(folder) == parent {
+				filtered = append(filtered, folder)
+			}
+		}
+		folders = filtered
+	}
+
+	sort.Strings(folders)
+	pagination, start, end := paginate(c, len(folders))
+	data := make([]APIFolder, 0, end-start)
+	for _, folder := range folders[start:end] {
+		data = append(data, APIFolder{
+			Path:   folder,
+			Name:   getNameFromPath(folder),
+			Parent: getParentPath(folder),
+		})
+	}
+
+	apiJSON(c, http.StatusOK, gin.H{
+		"data":       data,
+		"pagination": pagination,
+	})
+}
+
+// APIFolderTreeHandler returns the complete folder hierarchy as a nested tree
+func APIFolderTreeHandler(c *gin.Context) {
+	folders, err := store.ListFolders()
+	if err != nil {
+		log.Printf("API: error listing folders: %v", err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to list folders: %v", err))
+		return
+	}
+
+	folders = filterFolders(folders, viewFilter(c))
+	root := strings.Trim(c.Query("root"), "/")
+	sort.Strings(folders)
+	apiJSON(c, http.StatusOK, gin.H{
+		"data": buildAPIFolderTree(folders, root),
+	})
+}
+
+// buildAPIFolderTree recursively nests folders under the given parent
+func buildAPIFolderTree(folders []string, parent string) []APIFolder {
+	tree := []APIFolder{}
+	for _, folder := range folders {
+		if getParentPath(folder) == parent {
+			tree = append(tree, APIFolder{
+				Path:     folder,
+				Name:     getNameFromPath(folder),
+				Parent:   parent,
+				Children: buildAPIFolderTree(folders, folder),
+			})
+		}
+	}
+	return tree
+}
+
+// APICreateFolderHandler creates a folder, enforcing the configured nesting limit
+func APICreateFolderHandler(c *gin.Context) {
+	var requestBody struct {
+		Path string `json:"path"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_body", fmt.Sprintf("Failed to parse request: %v", err))
+		return
+	}
+
+	path, err := cleanAPIPath(requestBody.Path)
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	if !can(c, path, access.RoleEditor) {
+		auth.AbortAPI(c, http.StatusForbidden, "forbidden", fmt.Sprintf("You need the editor role on %q", path))
+		return
+	}
+	if folderExists(path) {
+		auth.AbortAPI(c, http.StatusConflict, "already_exists", fmt.Sprintf("Folder %q already exists", path))
+		return
+	}
+	if parent := getParentPath(path); parent != "" && !folderExists(parent) {
+		auth.AbortAPI(c, http.StatusUnprocessableEntity, "folder_not_found", fmt.Sprintf("Parent folder %q does not exist", parent))
+		return
+	}
+
+	maxLevel := config.GetMaxCategoryLevel()
+	if levels := strings.Count(path, "/") + 1; levels > maxLevel {
+		auth.AbortAPI(c, http.StatusUnprocessableEntity, "max_depth", fmt.Sprintf("Maximum category nesting level reached (%d levels max)", maxLevel))
+		return
+	}
+
+	if err := storeFor(c, "").CreateFolder(path); err != nil {
+		log.Printf("API: error creating folder %s: %v", path, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to create folder: %v", err))
+		return
+	}
+	invalidateStoreCache()
+
+	log.Printf("API: created folder %s", path)
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionFolderCreate,
+		Path:   path,
+	})
+	folderChanged(c, notify.ActionCreated, path)
+	c.JSON(http.StatusCreated, gin.H{
+		"data": APIFolder{
+			Path:   path,
+			Name:   getNameFromPath(path),
+			Parent: getParentPath(path),
+		},
+	})
+}
+
+// APIDeleteFolderHandler deletes a folder and everything in it
+func APIDeleteFolderHandler(c *gin.Context) {
+	path, err := cleanAPIPath(c.Param("path"))
+	if err != nil {
+		auth.AbortAPI(c, http.StatusBadRequest, "invalid_path", err.Error())
+		return
+	}
+
+	if !folderExists(path) {
+		auth.AbortAPI(c, http.StatusNotFound, "not_found", fmt.Sprintf("Folder %q not found", path))
+		return
+	}
+
+	trashed, err := moveToTrash(c, trash.KindFolder, path)
+	if err != nil {
+		log.Printf("API: error moving folder %s to trash: %v", path, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "trash_error", fmt.Sprintf("Failed to move folder to trash: %v", err))
+		return
+	}
+	if err := storeFor(c, "").DeleteFolder(path); err != nil {
+		discardTrashItem(trashed)
+		log.Printf("API: error deleting folder %s: %v", path, err)
+		auth.AbortAPI(c, http.StatusInternalServerError, "storage_error", fmt.Sprintf("Failed to delete folder: %v", err))
+		return
+	}
+	invalidateStoreCache()
+
+	log.Printf("API: deleted folder %s", path)
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionFolderDelete,
+		Path:   path,
+	})
+	folderChanged(c, notify.ActionDeleted, path)
+	c.Status(http.StatusNoContent)
+}
+
+// folderExists reports whether the folder is known to the storage
+func folderExists(path string) bool {
+	folders, err := store.ListFolders()
+	if err != nil {
+		log.Printf("Error listing folders: %v", err)
+		return false
+	}
+	for _, folder := range folders {
+		if folder == path {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateStoreCache drops cached folder and page lists if the storage caches them
+func invalidateStoreCache() {
+	if cacheable, ok := store.(interface{ InvalidateCache() error }); ok {
+		if err := cacheable.InvalidateCache(); err != nil {
+			log.Printf("Warning: Failed to invalidate cache: %v", err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/audit"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+var auditLog *audit.Logger
+
+// InitAuditHandlers sets the audit logger used to record mutations
+func InitAuditHandlers(logger *audit.Logger) {
+	auditLog = logger
+}
+
+// auditHash returns the content hash stored in audit entries, or "" when
+// there is no content (page did not exist before, or no longer exists after)
+func auditHash(content *string) string {
+	if content == nil {
+		return ""
+	}
+	return hashContent([]byte(*content))
+}
+
+// recordAudit fills in who, where from and how, then appends the entry
+func recordAudit(c *gin.Context, entry audit.Entry) {
+	user := currentUser(c)
+	entry.Actor = user.Email
+	entry.ActorName = user.Name
+	entry.IP = c.ClientIP()
+
+	token, _ := c.Value("auth_token").(*auth.Token)
+	switch {
+	case token != nil:
+		entry.Via = "token:" + token.Name
+	case strings.HasPrefix(c.Request.URL.Path, "/api/v1/"):
+		entry.Via = "api"
+	default:
+		entry.Via = "web"
+	}
+
+	auditLog.Record(entry)
+}
+
+// AuditPageHandler shows the audit log with optional filters
+func AuditPageHandler(c *gin.Context) {
+	filter := audit.Filter{
+		Actor:  strings.TrimSpace(c.Query("actor")),
+		Action: c.Query("action"),
+		Path:   strings.Trim(c.Query("path"), "/"),
+		Limit:  500,
+	}
+	if since, err := time.Parse("2006-01-02", c.Query("since")); err == nil {
+		filter.Since = since
+	}
+	if until, err := time.Parse("2006-01-02", c.Query("until")); err == nil {
+		// Include the whole "until" day
+		filter.Until = until.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	var entries []audit.Entry
+	if auditLog != nil {
+		var err error
+		entries, err = auditLog.Query(filter)
+		if err != nil {
+			log.Printf("Error reading audit log: %v", err)
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+				"error": "Failed to read audit log",
+			})
+			return
+		}
+	}
+
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	c.HTML(http.StatusOK, "audit.html", gin.H{
+		"Title":   "Audit Log",
+		"Entries": entries,
+		"Actions": []string{
+			audit.ActionPageCreate,
+			audit.ActionPageUpdate,
+			audit.ActionPageDelete,
+			audit.ActionPageMove,
+			audit.ActionFolderCreate,
+			audit.ActionFolderDelete,
+			audit.ActionTrashRestore,
+			audit.ActionTrashPurge,
+			audit.ActionShareCreate,
+			audit.ActionShareRevoke,
+			audit.ActionSync,
+		},
+		"Filter": gin.H{
+			"Actor":  filter.Actor,
+			"Action": filter.Action,
+			"Path":   filter.Path,
+			"Since":  c.Query("since"),
+			"Until":  c.Query("until"),
+		},
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       currentUser(c),
+	})
+}
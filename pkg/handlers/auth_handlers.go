@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
@@ -12,8 +13,13 @@ import (
 var authHandler *auth.Handler
 
 // InitAuthHandlers initializes the auth handlers with configuration
-func InitAuthHandlers(cfg *config.Config) {
-	authHandler = auth.NewHandler(cfg)
+func InitAuthHandlers(cfg *config.Config, tokens *auth.TokenStore) error {
+	handler, err := auth.NewHandler(cfg, tokens)
+	if err != nil {
+		return err
+	}
+	authHandler = handler
+	return nil
 }
 
 func LoginHandler(c *gin.Context) {
@@ -25,39 +31,61 @@ func LoginHandler(c *gin.Context) {
 		session.Save()
 	}
 	c.HTML(http.StatusOK, "login.html", gin.H{
-		"Error": error,
+		"Error":         error,
+		"Providers":     authHandler.Providers(),
+		"Password":      authHandler.PasswordLoginEnabled(),
+		"RememberDays":  config.GetConfig().Session.RememberDays,
+		"CSRFToken":     auth.CSRFToken(c),
+		"PublicFolders": config.GetConfig().Access.PublicFolders,
 	})
 }
 
-func GoogleLoginHandler(c *gin.Context) {
+// ProviderLoginHandler starts signing in with the provider in the URL
+func ProviderLoginHandler(c *gin.Context) {
 	authHandler.Login(c)
 }
 
-func GoogleCallbackHandler(c *gin.Context) {
+// ProviderCallbackHandler finishes signing in with the provider in the URL
+func ProviderCallbackHandler(c *gin.Context) {
 	authHandler.Callback(c)
 }
 
-func LogoutHandler(c *gin.Context) {
-	session := sessions.Default(c)
+// PasswordLoginHandler signs in with a local account
+func PasswordLoginHandler(c *gin.Context) {
+	authHandler.PasswordLogin(c)
+}
 
-	// Clear all session data
-	session.Clear()
+// PasswordPageHandler shows the password change form for local accounts
+func PasswordPageHandler(c *gin.Context) {
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
 
-	// Set cookie options to expire immediately and be secure
-	session.Options(sessions.Options{
-		Path:     "/",
-		MaxAge:   -1, // Set to -1 to expire the cookie immediately
-		HttpOnly: true,
-		Secure:   config.GetConfig().Session.Secure,
-		SameSite: http.SameSiteLaxMode,
+	c.HTML(http.StatusOK, "password.html", gin.H{
+		"Title":      "Change Password",
+		"MinLength":  auth.MinPasswordLength,
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       currentUser(c),
 	})
+}
+
+// ChangePasswordHandler changes the current local user's password
+func ChangePasswordHandler(c *gin.Context) {
+	authHandler.ChangePassword(c)
+}
+
+func LogoutHandler(c *gin.Context) {
+	session := sessions.Default(c)
 
-	// Save the session to ensure changes are applied
-	if err := session.Save(); err != nil {
+	// Clear all session data and expire the cookie immediately
+	if err := auth.EndSession(session); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear session"})
 		return
 	}
 
 	// Redirect to login page
-	c.Redirect(http.StatusTemporaryRedirect, "/login")
+	c.Redirect(http.StatusSeeOther, "/login")
 }
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/changes"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultChangeLimit is how many changes the page and feeds show
+	defaultChangeLimit = 50
+
+	// maxChangeLimit caps ?limit=, since GitHub history costs an API call per commit
+	maxChangeLimit = 100
+)
+
+var (
+	changeFeed    *changes.Feed
+	feedTokens    *auth.FeedTokenStore
+	changeBaseURL string
+)
+
+// InitChangeHandlers sets the feed of recent changes, the tokens feed readers
+// authenticate with and the base URL used for links in feeds
+func InitChangeHandlers(f *changes.Feed, tokens *auth.FeedTokenStore, baseURL string) {
+	changeFeed = f
+	feedTokens = tokens
+	changeBaseURL = baseURL
+
+	if reporter, ok := store.(types.SyncReporter); ok {
+		reporter.OnSync(recordSyncChanges)
+	}
+}
+
+// recordChange adds a change made through the wiki to the change journal
+func recordChange(action, wikiPath, oldPath string, folder bool, authorEmail, authorName string) {
+	if changeFeed == nil {
+		return
+	}
+
+	change := types.Change{
+		Path:        wikiPath,
+		Folder:      folder,
+		AuthorName:  authorName,
+		AuthorEmail: authorEmail,
+	}
+	switch action {
+	case notify.ActionCreated, notify.ActionRestored:
+		change.Action = types.ChangeCreated
+	case notify.ActionDeleted:
+		change.Action = types.ChangeDeleted
+	case notify.ActionMoved:
+		change.Action = types.ChangeMoved
+		change.OldPath = oldPath
+	default:
+		change.Action = types.ChangeUpdated
+	}
+	changeFeed.Record(change)
+}
+
+// recordSyncChanges journals the pages a GitHub sync pulled in
+func recordSyncChanges(synced []types.SyncChange) {
+	for _, sc := range synced {
+		action := notify.ActionUpdated
+		if sc.Before == nil {
+			action = notify.ActionCreated
+		}
+		recordChange(action, sc.Path, "", false, "", "GitHub sync")
+	}
+}
+
+// visibleChanges fetches recent changes and drops those the current user
+// can't see. A move is only shown when both paths are visible.
+func visibleChanges(c *gin.Context, folder string, limit int) ([]types.Change, string) {
+	// Ask for more than needed so filtering doesn't leave the list short
+	all, source := changeFeed.Recent(folder, min(limit*2, maxChangeLimit))
+
+	result := []types.Change{}
+	for _, change := range all {
+		if !can(c, change.Path, access.RoleViewer) {
+			continue
+		}
+		if change.OldPath != "" && !can(c, change.OldPath, access.RoleViewer) {
+			continue
+		}
+		result = append(result, change)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, source
+}
+
+// changeLimit reads ?limit=, falling back to the default
+func changeLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultChangeLimit
+	}
+	return min(limit, maxChangeLimit)
+}
+
+// changeLink returns the page or folder a change points to, relative to the wiki root
+func changeLink(change types.Change) string {
+	if change.Folder {
+		if change.Action == types.ChangeDeleted {
+			return "/category/" + getParentPath(change.Path)
+		}
+		return "/category/" + change.Path
+	}
+	if change.Action == types.ChangeDeleted {
+		return "/trash"
+	}
+	return pageURL(change.Path)
+}
+
+// feedURL returns the absolute URL of a folder's Atom feed for the token
+func feedURL(folder, token string) string {
+	params := url.Values{}
+	if folder != "" {
+		params.Set("folder", folder)
+	}
+	params.Set("token", token)
+	return changeBaseURL + "/feed.atom?" + params.Encode()
+}
+
+// recentChange is a change as shown on the recent changes page
+type recentChange struct {
+	types.Change
+	Title string
+	Link  string
+}
+
+// RecentChangesHandler lists the latest changes across the wiki or a folder
+func RecentChangesHandler(c *gin.Context) {
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	folder := FolderQueryFromRequest(c)
+
+	var list []recentChange
+	source := ""
+	if changeFeed != nil {
+		found, from := visibleChanges(c, folder, changeLimit(c))
+		source = from
+		for _, change := range found {
+			list = append(list, recentChange{
+				Change: change,
+				Title:  changes.Title(change),
+				Link:   changeLink(change),
+			})
+		}
+	}
+
+	subscribe := ""
+	if feedTokens != nil {
+		token, err := feedTokens.Get(currentUser(c))
+		if err != nil {
+			log.Printf("Error getting feed token: %v", err)
+		} else {
+			subscribe = feedURL(folder, token)
+		}
+	}
+
+	folders, err := store.ListFolders()
+	if err != nil {
+		log.Printf("Error listing folders: %v", err)
+	}
+
+	c.HTML(http.StatusOK, "recent.html", gin.H{
+		"Title":      "Recent Changes",
+		"Changes":    list,
+		"Source":     source,
+		"Folder":     folder,
+		"Folders":    filterFolders(folders, viewFilter(c)),
+		"FeedURL":    subscribe,
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       currentUser(c),
+	})
+}
+
+// ResetFeedTokenHandler replaces the current user's feed token, breaking
+// feed URLs that were shared by mistake
+func ResetFeedTokenHandler(c *gin.Context) {
+	if feedTokens == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Feeds are disabled"})
+		return
+	}
+
+	token, err := feedTokens.Reset(currentUser(c))
+	if err != nil {
+		log.Printf("Error resetting feed token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset feed token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"feedURL": feedURL(FolderQueryFromRequest(c), token),
+	})
+}
+
+// FeedHandler serves recent changes as an Atom feed, for the whole wiki or
+// the folder in ?folder=
+func FeedHandler(c *gin.Context) {
+	if changeFeed == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	folder := FolderQueryFromRequest(c)
+
+	found, _ := visibleChanges(c, folder, changeLimit(c))
+
+	title := "Daniel's Wiki: recent changes"
+	siteURL := changeBaseURL + "/recent"
+	selfURL := changeBaseURL + "/feed.atom"
+	if folder != "" {
+		title = fmt.Sprintf("Daniel's Wiki: recent changes in %s", folder)
+		siteURL += "?folder=" + url.QueryEscape(folder)
+		selfURL += "?folder=" + url.QueryEscape(folder)
+	}
+
+	data, err := changes.Atom(changes.AtomFeed{
+		Title:   title,
+		ID:      selfURL,
+		SelfURL: selfURL,
+		SiteURL: siteURL,
+		Link: func(change types.Change) string {
+			return changeBaseURL + changeLink(change)
+		},
+	}, found)
+	if err != nil {
+		log.Printf("Error rendering feed: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", data)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/audit"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/collab"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var collabHub *collab.Hub
+
+// upgrader accepts WebSocket connections from the wiki's own pages only
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// InitCollabHandlers starts the hub that keeps collaborative editing sessions.
+// Sessions are saved after idle time without edits and when the last editor
+// leaves.
+func InitCollabHandlers(idle time.Duration) {
+	collabHub = collab.NewHub(persistCollabPage, idle)
+}
+
+// CollabHandler upgrades the connection and joins the page's editing session
+func CollabHandler(c *gin.Context) {
+	if collabHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Collaborative editing is disabled"})
+		return
+	}
+
+	pagePath := PagePathFromRequest(c)
+	if _, err := store.GetPage(pagePath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Page not found: %v", err),
+		})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		log.Printf("Collab: failed to upgrade connection: %v", err)
+		return
+	}
+
+	user := currentUser(c)
+	name := user.Name
+	if name == "" {
+		name = user.Email
+	}
+	load := func() (string, error) {
+		page, err := store.GetPage(pagePath)
+		if err != nil {
+			return "", err
+		}
+		return page.Content, nil
+	}
+	collabHub.Serve(pagePath, load, collab.Editor{Email: user.Email, Name: name}, conn)
+}
+
+// persistCollabPage saves the result of a collaborative session as one change,
+// authored by the last editor and crediting everyone who took part. A page
+// saved outside the session since base is never overwritten; the session
+// merges the change in and tries again.
+func persistCollabPage(pagePath, base, content string, contributors []collab.Editor) error {
+	existing, err := store.GetPage(pagePath)
+	if err != nil {
+		return fmt.Errorf("failed to load page: %v", err)
+	}
+	if existing.Content == content {
+		return nil
+	}
+	if existing.Content != base {
+		return &collab.ConflictError{Current: existing.Content}
+	}
+
+	names := make([]string, 0, len(contributors))
+	for _, editor := range contributors {
+		names = append(names, editor.Name)
+	}
+	var last collab.Editor
+	if len(contributors) > 0 {
+		last = contributors[len(contributors)-1]
+	}
+
+	writer := store
+	if committer, ok := store.(types.Committer); ok {
+		writer = committer.WithCommit(types.CommitInfo{
+			AuthorName:  last.Name,
+			AuthorEmail: last.Email,
+			Message:     fmt.Sprintf("Collaborative edit of %s by %s", pagePath, strings.Join(names, ", ")),
+		})
+	}
+
+	page := &types.Page{
+		Title:   getNameFromPath(pagePath),
+		Path:    pagePath,
+		Content: content,
+		Body:    []byte(content),
+	}
+	if err := writer.UpdatePage(page); err != nil {
+		return err
+	}
+
+	auditLog.Record(audit.Entry{
+		Actor:      last.Email,
+		ActorName:  last.Name,
+		Action:     audit.ActionPageUpdate,
+		Path:       pagePath,
+		BeforeHash: auditHash(&existing.Content),
+		AfterHash:  auditHash(&content),
+		Via:        "collab",
+	})
+	pageChangedBy(auth.User{Email: last.Email, Name: strings.Join(names, ", ")},
+		notify.ActionUpdated, pagePath, "", &existing.Content, &content)
+	return nil
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/comments"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/gin-gonic/gin"
+)
+
+var commentStore *comments.Store
+
+// InitCommentHandlers sets the store for comments kept next to each page and
+// has the storage copy comments along with pages when it syncs
+func InitCommentHandlers(s *comments.Store) {
+	commentStore = s
+
+	if syncer, ok := store.(types.SidecarSyncer); ok {
+		syncer.SyncSidecar(comments.Suffix)
+	}
+}
+
+// commentSidecars returns the storage that comments are written through,
+// with the commit attributed to the current user, or nil when the storage
+// can't keep files next to pages
+func commentSidecars(c *gin.Context, summary string) types.SidecarStore {
+	if commentStore == nil {
+		return nil
+	}
+	sidecars, _ := storeFor(c, summary).(types.SidecarStore)
+	return sidecars
+}
+
+// moveComments carries a page's comments along when the page is moved
+func moveComments(c *gin.Context, oldPath, newPath string) {
+	sidecars := commentSidecars(c, fmt.Sprintf("Move comments of %s to %s", oldPath, newPath))
+	if sidecars == nil {
+		return
+	}
+	if err := commentStore.Move(sidecars, oldPath, newPath); err != nil {
+		log.Printf("Warning: failed to move comments from %s to %s: %v", oldPath, newPath, err)
+	}
+}
+
+// deleteComments removes a deleted page's comments. The trash keeps a copy
+// with the page, so they come back when it is restored.
+func deleteComments(c *gin.Context, pagePath string) {
+	sidecars := commentSidecars(c, "Delete comments of "+pagePath)
+	if sidecars == nil {
+		return
+	}
+	if err := commentStore.Delete(sidecars, pagePath); err != nil {
+		log.Printf("Warning: failed to delete comments of %s: %v", pagePath, err)
+	}
+}
+
+// commentsResponse is the JSON the comments section is rendered from
+func commentsResponse(c *gin.Context, pagePath string, f *comments.File) gin.H {
+	return gin.H{
+		"threads":    f.Threads(),
+		"me":         currentUser(c).Email,
+		"canComment": can(c, pagePath, access.RoleEditor),
+		"canResolve": can(c, pagePath, access.RoleAdmin),
+	}
+}
+
+// updateComments applies a change to a page's comments and responds with the
+// updated threads
+func updateComments(c *gin.Context, summary string, change func(f *comments.File) error) {
+	pagePath := PagePathFromRequest(c)
+	sidecars := commentSidecars(c, summary)
+	if sidecars == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Comments are not supported by this storage"})
+		return
+	}
+	if _, err := store.GetPage(pagePath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Page not found"})
+		return
+	}
+
+	var updated *comments.File
+	var changeErr error
+	err := commentStore.Update(sidecars, pagePath, func(f *comments.File) error {
+		changeErr = change(f)
+		updated = f
+		return changeErr
+	})
+	if changeErr != nil {
+		// Not the author, no such comment, empty body and the like
+		c.JSON(http.StatusBadRequest, gin.H{"error": changeErr.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving comments on %s: %v", pagePath, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save comments"})
+		return
+	}
+	c.JSON(http.StatusOK, commentsResponse(c, pagePath, updated))
+}
+
+// ListCommentsHandler returns a page's comment threads
+func ListCommentsHandler(c *gin.Context) {
+	pagePath := PagePathFromRequest(c)
+	sidecars, ok := store.(types.SidecarStore)
+	if commentStore == nil || !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Comments are not supported by this storage"})
+		return
+	}
+
+	f, err := commentStore.Load(sidecars, pagePath)
+	if err != nil {
+		log.Printf("Error loading comments on %s: %v", pagePath, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load comments"})
+		return
+	}
+	c.JSON(http.StatusOK, commentsResponse(c, pagePath, f))
+}
+
+// commentRequest is the body for adding or editing a comment
+type commentRequest struct {
+	Body     string `json:"body"`
+	ParentID string `json:"parent_id"`
+}
+
+// AddCommentHandler starts a thread or replies to one
+func AddCommentHandler(c *gin.Context) {
+	var req commentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	user := currentUser(c)
+	summary := fmt.Sprintf("Comment on %s by %s", PagePathFromRequest(c), user.Name)
+	updateComments(c, summary, func(f *comments.File) error {
+		_, err := f.Add(comments.Author{Email: user.Email, Name: user.Name}, req.ParentID, req.Body)
+		return err
+	})
+}
+
+// EditCommentHandler changes the body of the current user's comment
+func EditCommentHandler(c *gin.Context) {
+	var req commentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	user := currentUser(c)
+	summary := fmt.Sprintf("Edit comment on %s by %s", PagePathFromRequest(c), user.Name)
+	updateComments(c, summary, func(f *comments.File) error {
+		_, err := f.Edit(c.Param("id"), user.Email, req.Body)
+		return err
+	})
+}
+
+// DeleteCommentHandler deletes the current user's comment
+func DeleteCommentHandler(c *gin.Context) {
+	user := currentUser(c)
+	summary := fmt.Sprintf("Delete comment on %s by %s", PagePathFromRequest(c), user.Name)
+	updateComments(c, summary, func(f *comments.File) error {
+		return f.Delete(c.Param("id"), user.Email)
+	})
+}
+
+// ResolveCommentHandler resolves or reopens a thread
+func ResolveCommentHandler(c *gin.Context) {
+	var req struct {
+		Resolved bool `json:"resolved"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	user := currentUser(c)
+	verb := "Resolve"
+	if !req.Resolved {
+		verb = "Reopen"
+	}
+	summary := fmt.Sprintf("%s comment thread on %s", verb, PagePathFromRequest(c))
+	updateComments(c, summary, func(f *comments.File) error {
+		_, err := f.Resolve(c.Param("id"), req.Resolved, user.Email)
+		return err
+	})
+}
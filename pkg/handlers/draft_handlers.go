@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/drafts"
+	"github.com/gin-gonic/gin"
+)
+
+var draftStore drafts.Store
+
+// InitDraftHandlers sets the store used for autosaved drafts
+func InitDraftHandlers(s drafts.Store) {
+	draftStore = s
+}
+
+// draftTarget returns the wiki path a draft will be published to, used for
+// permission checks
+func draftTarget(folder, oldTitle string) string {
+	if oldTitle == "" {
+		return folder
+	}
+	if folder == "" {
+		return oldTitle
+	}
+	return folder + "/" + oldTitle
+}
+
+// loadDraft returns the current user's draft of a page, ignoring drafts that
+// match the published content
+func loadDraft(c *gin.Context, folder, oldTitle, published string) *drafts.Draft {
+	if draftStore == nil {
+		return nil
+	}
+	draft, err := draftStore.Get(currentUser(c).Email, drafts.Key(folder, oldTitle))
+	if err != nil {
+		log.Printf("Error loading draft: %v", err)
+		return nil
+	}
+	if draft == nil || (draft.Content == published && (draft.Title == oldTitle || draft.Title == "")) {
+		return nil
+	}
+	return draft
+}
+
+// clearDraft removes the current user's draft once the page is published
+func clearDraft(c *gin.Context, folder, oldTitle string) {
+	if draftStore == nil {
+		return
+	}
+	if err := draftStore.Delete(currentUser(c).Email, drafts.Key(folder, oldTitle)); err != nil {
+		log.Printf("Warning: failed to clear draft: %v", err)
+	}
+}
+
+// SaveDraftHandler autosaves the editor content without touching storage
+func SaveDraftHandler(c *gin.Context) {
+	if draftStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Drafts are disabled"})
+		return
+	}
+
+	var requestBody struct {
+		Folder   string `json:"folder"`
+		OldTitle string `json:"oldTitle"`
+		Title    string `json:"title"`
+		Content  string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	if !can(c, draftTarget(requestBody.Folder, requestBody.OldTitle), access.RoleEditor) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You don't have permission to edit this page",
+		})
+		return
+	}
+
+	draft := &drafts.Draft{
+		Key:       drafts.Key(requestBody.Folder, requestBody.OldTitle),
+		Folder:    requestBody.Folder,
+		OldTitle:  requestBody.OldTitle,
+		Title:     requestBody.Title,
+		Content:   requestBody.Content,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := draftStore.Save(currentUser(c).Email, draft); err != nil {
+		log.Printf("Error saving draft: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save draft",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"savedAt": draft.UpdatedAt,
+	})
+}
+
+// DiscardDraftHandler deletes the current user's draft of a page
+func DiscardDraftHandler(c *gin.Context) {
+	if draftStore == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if err := draftStore.Delete(currentUser(c).Email, drafts.Key(c.Query("folder"), c.Query("oldTitle"))); err != nil {
+		log.Printf("Error discarding draft: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to discard draft",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
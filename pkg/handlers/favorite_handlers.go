@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/favorites"
+	"github.com/gin-gonic/gin"
+)
+
+var favoriteStore favorites.Store
+
+// InitFavoriteHandlers sets the store for favorites and recently viewed pages
+func InitFavoriteHandlers(s favorites.Store) {
+	favoriteStore = s
+}
+
+// pageURL returns the view URL of a page given its wiki path
+func pageURL(pagePath string) string {
+	link := "/view/" + url.PathEscape(getNameFromPath(pagePath))
+	if parent := getParentPath(pagePath); parent != "" {
+		link += "?folder=" + url.QueryEscape(parent)
+	}
+	return link
+}
+
+// personalLink is a favorite or recently viewed page as shown to its user
+type personalLink struct {
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Folder bool   `json:"folder"`
+	URL    string `json:"url"`
+}
+
+// personalLinks turns stored items into links, dropping pages and folders
+// that were moved or deleted since or that the user can no longer see
+func personalLinks(c *gin.Context, items []favorites.Item) []personalLink {
+	var folders map[string]bool
+	links := []personalLink{}
+	for _, item := range items {
+		if !can(c, item.Path, access.RoleViewer) {
+			continue
+		}
+
+		link := personalLink{Path: item.Path, Name: getNameFromPath(item.Path), Folder: item.Folder}
+		if item.Folder {
+			if folders == nil {
+				folders = make(map[string]bool)
+				list, err := store.ListFolders()
+				if err != nil {
+					log.Printf("Error listing folders: %v", err)
+				}
+				for _, folder := range list {
+					folders[folder] = true
+				}
+			}
+			if !folders[item.Path] {
+				continue
+			}
+			link.URL = "/category/" + item.Path
+		} else {
+			if _, err := store.GetPage(item.Path); err != nil {
+				continue
+			}
+			link.URL = pageURL(item.Path)
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// userFavorites returns the current user's favorites as links
+func userFavorites(c *gin.Context) []personalLink {
+	if favoriteStore == nil {
+		return []personalLink{}
+	}
+	items, err := favoriteStore.Favorites(currentUser(c).Email)
+	if err != nil {
+		log.Printf("Error loading favorites: %v", err)
+	}
+	return personalLinks(c, items)
+}
+
+// recentlyViewed returns the current user's recently viewed pages as links
+func recentlyViewed(c *gin.Context) []personalLink {
+	if favoriteStore == nil {
+		return []personalLink{}
+	}
+	items, err := favoriteStore.RecentlyViewed(currentUser(c).Email)
+	if err != nil {
+		log.Printf("Error loading recently viewed pages: %v", err)
+	}
+	return personalLinks(c, items)
+}
+
+// isFavorite reports whether the current user starred the page or folder
+func isFavorite(c *gin.Context, wikiPath string, folder bool) bool {
+	if favoriteStore == nil {
+		return false
+	}
+	items, err := favoriteStore.Favorites(currentUser(c).Email)
+	if err != nil {
+		return false
+	}
+	for _, item := range items {
+		if item.Path == wikiPath && item.Folder == folder {
+			return true
+		}
+	}
+	return false
+}
+
+// recordView adds a page to the current user's recently viewed pages
+func recordView(c *gin.Context, pagePath string) {
+	if favoriteStore == nil || currentUser(c).IsGuest() {
+		return
+	}
+	if err := favoriteStore.RecordView(currentUser(c).Email, pagePath); err != nil {
+		log.Printf("Error recording view of %s: %v", pagePath, err)
+	}
+}
+
+// FavoritesHandler returns the current user's favorites and recently viewed
+// pages for the sidebar
+func FavoritesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"favorites": userFavorites(c),
+		"recent":    recentlyViewed(c),
+	})
+}
+
+// favoriteRequest identifies a page or folder to star
+type favoriteRequest struct {
+	Path   string `json:"path"`
+	Folder bool   `json:"folder"`
+}
+
+// AddFavoriteHandler stars a page or folder
+func AddFavoriteHandler(c *gin.Context) {
+	if favoriteStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Favorites are disabled"})
+		return
+	}
+
+	var req favoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+	req.Path = strings.Trim(req.Path, "/")
+	if req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is required"})
+		return
+	}
+	if !can(c, req.Path, access.RoleViewer) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to view this page"})
+		return
+	}
+
+	if err := favoriteStore.AddFavorite(currentUser(c).Email, req.Path, req.Folder); err != nil {
+		log.Printf("Error adding favorite: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "favorite": true})
+}
+
+// RemoveFavoriteHandler unstars a page or folder
+func RemoveFavoriteHandler(c *gin.Context) {
+	if favoriteStore == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "favorite": false})
+		return
+	}
+
+	wikiPath := strings.Trim(c.Query("path"), "/")
+	folder := c.Query("folder") == "true"
+	if err := favoriteStore.RemoveFavorite(currentUser(c).Email, wikiPath, folder); err != nil {
+		log.Printf("Error removing favorite: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove favorite"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "favorite": false})
+}
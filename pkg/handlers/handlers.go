@@ -8,10 +8,13 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/audit"
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
-	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/models"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage"
 	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/trash"
 	"github.com/gin-gonic/gin"
 )
 
@@ -29,6 +32,53 @@ func GetStorage() types.Storage {
 	return store
 }
 
+// storeFor returns the storage with changes attributed to the current user.
+// A non-empty summary is used as the commit message.
+func storeFor(c *gin.Context, summary string) types.Storage {
+	committer, ok := store.(types.Committer)
+	if !ok {
+		return store
+	}
+	user := currentUser(c)
+	return committer.WithCommit(types.CommitInfo{
+		AuthorName:  user.Name,
+		AuthorEmail: user.Email,
+		Message:     summary,
+	})
+}
+
+// renamePage saves a page under its new path and removes the page it was
+// renamed from. When the old page can't be removed the new one is removed
+// again, so a failed rename never leaves two copies behind. A copy of the old
+// page goes to the trash first and only stays there when removing the old page
+// failed, in case it was removed from part of the storage.
+func renamePage(c *gin.Context, writer types.Storage, oldPage, page *types.Page) error {
+	oldPath := strings.TrimSuffix(oldPage.Path, ".txt")
+	if oldPath == strings.TrimSuffix(page.Path, ".txt") {
+		return writer.UpdatePage(page)
+	}
+
+	trashed, err := moveToTrash(c, trash.KindPage, oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to move old page to trash: %v", err)
+	}
+	if err := writer.UpdatePage(page); err != nil {
+		discardTrashItem(trashed)
+		return fmt.Errorf("failed to create new page: %v", err)
+	}
+	if err := writer.DeletePage(oldPage.Path); err != nil {
+		if rollbackErr := writer.DeletePage(page.Path); rollbackErr != nil {
+			log.Printf("Error removing %s after failed rename: %v", page.Path, rollbackErr)
+		}
+		if trashed != nil {
+			log.Printf("Kept %s in the trash as %s after failed rename", oldPath, trashed.ID)
+		}
+		return fmt.Errorf("failed to delete old page: %v", err)
+	}
+	discardTrashItem(trashed)
+	return nil
+}
+
 // HomeHandler handles the home page
 func HomeHandler(c *gin.Context) {
 	log.Println("=== HomeHandler START ===")
@@ -43,9 +93,9 @@ func HomeHandler(c *gin.Context) {
 		return
 	}
 
-	// Filter to only show root-level folders
+	// Filter to only show root-level folders the user may see
 	var rootFolders []string
-	for _, folder := range allFolders {
+	for _, folder := range filterFolders(allFolders, viewFilter(c)) {
 		// Only include folders that don't have a slash (not children)
 		if !strings.Contains(folder, "/") {
 			rootFolders = append(rootFolders, folder)
@@ -61,9 +111,10 @@ func HomeHandler(c *gin.Context) {
 	log.Printf("User: %+v", user)
 
 	c.HTML(http.StatusOK, "home.html", gin.H{
-		"Categories": rootFolders,
-		"User":       user,
-		"SideMenu":   models.GetMenuItems("", store),
+		"Categories":     rootFolders,
+		"Favorites":      userFavorites(c),
+		"RecentlyViewed": recentlyViewed(c),
+		"User":           user,
 	})
 
 	log.Println("=== HomeHandler END ===")
@@ -124,7 +175,7 @@ func ViewHandler(c *gin.Context) {
 	log.Printf("Page content preview: %s", page.Content[:min(100, len(page.Content))])
 
 	// Get the folder tree to use in the sidebar
-	folderTree, err := GetFolderTree(store, folderPath)
+	folderTree, err := GetFolderTree(store, folderPath, viewFilter(c))
 	if err != nil {
 		log.Printf("Error building folder tree: %v", err)
 		// Continue without folder tree - not critical
@@ -161,8 +212,16 @@ func ViewHandler(c *gin.Context) {
 		"FolderPath":  folderPath,
 		"CurrentPath": folderPath, // For highlighting the active folder
 		"Breadcrumbs": breadcrumbs,
+		"CanEdit":     can(c, fullPath, access.RoleEditor),
+		"Editors":     otherEditors(c, fullPath),
+		"PagePath":    fullPath,
+		"Watching":    isWatching(c, fullPath, false),
+		"Favorite":    isFavorite(c, fullPath, false),
+		"Share":       shareOptions(c, fullPath),
 		"User":        c.MustGet("user"),
 	})
+	recordView(c, fullPath)
+	recordPageView(c, fullPath)
 	log.Printf("=== ViewHandler END: %s ===", title)
 }
 
@@ -198,7 +257,7 @@ func EditHandler(c *gin.Context) {
 	log.Printf("Folder path from query: %s", folderPath)
 
 	// Get the folder tree to use in the sidebar
-	folderTree, err := GetFolderTree(store, folderPath)
+	folderTree, err := GetFolderTree(store, folderPath, viewFilter(c))
 	if err != nil {
 		log.Printf("Error building folder tree: %v", err)
 		// Continue without folder tree - not critical
@@ -231,15 +290,32 @@ func EditHandler(c *gin.Context) {
 	// Handle new page creation
 	if decodedTitle == "" || decodedTitle == "new" {
 		log.Printf("Creating new page form")
+
+		// Start from the requested template, or the folder's default one
+		templates := listPageTemplates(c, folderPath)
+		selectedTemplate := c.Query("template")
+		if selectedTemplate == "" {
+			selectedTemplate = defaultTemplateFor(folderPath)
+		}
+		content := ""
+		for _, tmpl := range templates {
+			if tmpl.Name == selectedTemplate {
+				content = tmpl.Content
+			}
+		}
+
 		c.HTML(http.StatusOK, "edit.html", gin.H{
-			"Title":       "",
-			"Content":     "",
-			"IsNewPage":   true,
-			"FolderPath":  folderPath, // Pass the folder path to the template
-			"FolderTree":  folderTree,
-			"CurrentPath": folderPath,  // For highlighting the active folder
-			"Breadcrumbs": breadcrumbs, // Add breadcrumbs
-			"User":        c.MustGet("user"),
+			"Title":            "",
+			"Content":          content,
+			"IsNewPage":        true,
+			"Templates":        templates,
+			"SelectedTemplate": selectedTemplate,
+			"Draft":            loadDraft(c, folderPath, "", content),
+			"FolderPath":       folderPath, // Pass the folder path to the template
+			"FolderTree":       folderTree,
+			"CurrentPath":      folderPath,  // For highlighting the active folder
+			"Breadcrumbs":      breadcrumbs, // Add breadcrumbs
+			"User":             c.MustGet("user"),
 		})
 		log.Printf("=== EditHandler END (new page) ===")
 		return
@@ -268,10 +344,31 @@ func EditHandler(c *gin.Context) {
 	}
 
 	log.Printf("Found page, title: %s, content length: %d", page.Title, len(page.Content))
+
+	// Warn before joining someone else's edit unless the user chose to go ahead
+	editors := otherEditors(c, fullPath)
+	if softLock && len(editors) > 0 && c.Query("force") == "" {
+		log.Printf("Page %s is being edited by %s", fullPath, editors[0].Email)
+		c.HTML(http.StatusOK, "edit_locked.html", gin.H{
+			"Title":       page.Title,
+			"FolderPath":  folderPath,
+			"Editors":     editors,
+			"FolderTree":  folderTree,
+			"CurrentPath": folderPath,
+			"Breadcrumbs": breadcrumbs,
+			"User":        c.MustGet("user"),
+		})
+		log.Printf("=== EditHandler END (locked) ===")
+		return
+	}
+	touchPresence(c, fullPath)
+
 	c.HTML(http.StatusOK, "edit.html", gin.H{
 		"Title":       page.Title,
 		"Content":     page.Content,
 		"IsNewPage":   false,
+		"Editors":     editors,
+		"Draft":       loadDraft(c, folderPath, page.Title, page.Content),
 		"FolderPath":  folderPath, // Pass the folder path to the template
 		"FolderTree":  folderTree,
 		"CurrentPath": folderPath,  // For highlighting the active folder
@@ -291,6 +388,7 @@ func SaveHandler(c *gin.Context) {
 		Content  string `json:"content"`
 		Folder   string `json:"folder"`
 		OldTitle string `json:"oldTitle"` // Add oldTitle to track title changes
+		Summary  string `json:"summary"`  // Optional edit summary used as the commit message
 	}
 
 	if err := c.ShouldBindJSON(&requestBody); err != nil {
@@ -329,6 +427,16 @@ func SaveHandler(c *gin.Context) {
 		log.Printf("Creating/updating page at root: %s", filePath)
 	}
 
+	if !can(c, filePath, access.RoleEditor) {
+		log.Printf("Access denied: cannot save %s", filePath)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You don't have permission to edit this page",
+		})
+		return
+	}
+
+	writer := storeFor(c, requestBody.Summary)
+
 	// Create a page object with the new title and path
 	page := &types.Page{
 		Title:   title,
@@ -346,34 +454,48 @@ func SaveHandler(c *gin.Context) {
 			oldFilePath = oldTitle
 		}
 		log.Printf("Checking for old page at: %s", oldFilePath)
+		if !can(c, oldFilePath, access.RoleEditor) {
+			log.Printf("Access denied: cannot rename %s", oldFilePath)
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You don't have permission to edit this page",
+			})
+			return
+		}
 
 		oldPage, err := store.GetPage(oldFilePath)
 		if err == nil {
-			// Old page exists, delete it first
-			log.Printf("Found old page, deleting: %s", oldFilePath)
-			if err := store.DeletePage(oldPage.Path); err != nil {
-				log.Printf("Error deleting old page: %v", err)
+			// Old page exists, move it to the new title
+			log.Printf("Found old page, saving it as: %s", filePath)
+			if err := renamePage(c, writer, oldPage, page); err != nil {
+				log.Printf("Error saving page: %v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": fmt.Sprintf("Failed to delete old page: %v", err),
+					"error": fmt.Sprintf("Failed to save page: %v", err),
 				})
 				return
 			}
-			log.Printf("Successfully deleted old page: %s", oldFilePath)
+			log.Printf("Successfully saved page: %s", filePath)
 
-			// Now create the new page
-			log.Printf("Creating new page: %s", filePath)
-			if err := store.CreatePage(page); err != nil {
-				log.Printf("Error creating new page: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": fmt.Sprintf("Failed to create new page: %v", err),
-				})
-				return
+			action := audit.ActionPageMove
+			if oldPage.Path == page.Path {
+				action = audit.ActionPageUpdate
+			}
+			recordAudit(c, audit.Entry{
+				Action:     action,
+				Path:       filePath,
+				OldPath:    oldFilePath,
+				BeforeHash: auditHash(&oldPage.Content),
+				AfterHash:  auditHash(&page.Content),
+			})
+			if action == audit.ActionPageMove {
+				moveComments(c, oldFilePath, filePath)
+				pageChanged(c, notify.ActionMoved, filePath, oldFilePath, &oldPage.Content, &page.Content)
+			} else {
+				pageChanged(c, notify.ActionUpdated, filePath, "", &oldPage.Content, &page.Content)
 			}
-			log.Printf("Successfully created new page: %s", filePath)
 		} else {
 			// Old page doesn't exist, create new one
 			log.Printf("Old page not found, creating new page: %s", filePath)
-			if err := store.CreatePage(page); err != nil {
+			if err := writer.CreatePage(page); err != nil {
 				log.Printf("Error creating page: %v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": fmt.Sprintf("Failed to create page: %v", err),
@@ -381,14 +503,20 @@ func SaveHandler(c *gin.Context) {
 				return
 			}
 			log.Printf("Successfully created new page: %s", filePath)
+			recordAudit(c, audit.Entry{
+				Action:    audit.ActionPageCreate,
+				Path:      filePath,
+				AfterHash: auditHash(&page.Content),
+			})
+			pageChanged(c, notify.ActionCreated, filePath, "", nil, &page.Content)
 		}
 	} else {
 		// No old title, check if page exists at new path
-		_, err := store.GetPage(filePath)
+		existing, err := store.GetPage(filePath)
 		if err != nil {
 			// Page doesn't exist, create it
 			log.Printf("Page doesn't exist, creating new page: %s", filePath)
-			if err := store.CreatePage(page); err != nil {
+			if err := writer.CreatePage(page); err != nil {
 				log.Printf("Error creating page: %v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": fmt.Sprintf("Failed to create page: %v", err),
@@ -396,10 +524,16 @@ func SaveHandler(c *gin.Context) {
 				return
 			}
 			log.Printf("Successfully created new page: %s", filePath)
+			recordAudit(c, audit.Entry{
+				Action:    audit.ActionPageCreate,
+				Path:      filePath,
+				AfterHash: auditHash(&page.Content),
+			})
+			pageChanged(c, notify.ActionCreated, filePath, "", nil, &page.Content)
 		} else {
 			// Page exists, update it
 			log.Printf("Page exists, updating: %s", filePath)
-			if err := store.UpdatePage(page); err != nil {
+			if err := writer.UpdatePage(page); err != nil {
 				log.Printf("Error updating page: %v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": fmt.Sprintf("Failed to update page: %v", err),
@@ -407,9 +541,19 @@ func SaveHandler(c *gin.Context) {
 				return
 			}
 			log.Printf("Successfully updated page: %s", filePath)
+			recordAudit(c, audit.Entry{
+				Action:     audit.ActionPageUpdate,
+				Path:       filePath,
+				BeforeHash: auditHash(&existing.Content),
+				AfterHash:  auditHash(&page.Content),
+			})
+			pageChanged(c, notify.ActionUpdated, filePath, "", &existing.Content, &page.Content)
 		}
 	}
 
+	// The page is published, so the autosaved draft is no longer needed
+	clearDraft(c, folderPath, oldTitle)
+
 	log.Println("=== SaveHandler END ===")
 	// Include folder path in redirect URL if present
 	redirectURL := "/view/" + url.QueryEscape(title)
@@ -452,7 +596,26 @@ func DeleteHandler(c *gin.Context) {
 		log.Printf("Deleting page at root: %s", fullPath)
 	}
 
-	if err := store.DeletePage(fullPath); err != nil {
+	// Capture the content hash for the audit log before it's gone
+	var beforeHash string
+	if existing, err := store.GetPage(fullPath); err == nil {
+		beforeHash = auditHash(&existing.Content)
+	}
+
+	// Keep a copy in the trash so the page can be restored
+	trashed, err := moveToTrash(c, trash.KindPage, fullPath)
+	if err != nil {
+		log.Printf("Error moving page to trash: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to move page to trash: %v", err),
+		})
+		return
+	}
+
+	writer := storeFor(c, "")
+	if err := writer.DeletePage(fullPath); err != nil {
+		discardTrashItem(trashed)
 		log.Printf("Error deleting page: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -462,6 +625,13 @@ func DeleteHandler(c *gin.Context) {
 	}
 
 	log.Printf("Successfully deleted page: %s", fullPath)
+	deleteComments(c, fullPath)
+	recordAudit(c, audit.Entry{
+		Action:     audit.ActionPageDelete,
+		Path:       fullPath,
+		BeforeHash: beforeHash,
+	})
+	pageChanged(c, notify.ActionDeleted, fullPath, "", nil, nil)
 	log.Printf("=== DeleteHandler END: %s ===", title)
 
 	// Return success response with redirect URL
@@ -545,6 +715,14 @@ func CategoryCreateHandler(c *gin.Context) {
 		fullPath = categoryName
 	}
 
+	if !can(c, fullPath, access.RoleEditor) {
+		log.Printf("Access denied: cannot create category %s", fullPath)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You don't have permission to create categories here",
+		})
+		return
+	}
+
 	// Get max category level from config
 	maxLevel := config.GetMaxCategoryLevel()
 
@@ -565,7 +743,8 @@ func CategoryCreateHandler(c *gin.Context) {
 	log.Printf("Creating category at path: %s", fullPath)
 
 	// Create the category folder
-	if err := store.CreateFolder(fullPath); err != nil {
+	writer := storeFor(c, "")
+	if err := writer.CreateFolder(fullPath); err != nil {
 		log.Printf("Error creating category: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to create category: %v", err),
@@ -581,6 +760,11 @@ func CategoryCreateHandler(c *gin.Context) {
 	}
 
 	log.Printf("Successfully created category: %s", fullPath)
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionFolderCreate,
+		Path:   fullPath,
+	})
+	folderChanged(c, notify.ActionCreated, fullPath)
 	log.Println("=== CategoryCreateHandler END ===")
 
 	c.JSON(http.StatusOK, gin.H{
@@ -591,7 +775,7 @@ func CategoryCreateHandler(c *gin.Context) {
 
 // DeleteFolderHandler handles deleting a folder
 func DeleteFolderHandler(c *gin.Context) {
-	path := c.Query("path")
+	path := QueryPathFromRequest(c)
 	if path == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Folder path is required",
@@ -609,7 +793,19 @@ func DeleteFolderHandler(c *gin.Context) {
 	}
 
 	// Delete the folder
-	if err := store.DeleteFolder(path); err != nil {
+	// Keep a copy in the trash so the folder can be restored
+	trashed, err := moveToTrash(c, trash.KindFolder, path)
+	if err != nil {
+		log.Printf("Error moving folder to trash: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to move folder to trash: %v", err),
+		})
+		return
+	}
+
+	writer := storeFor(c, "")
+	if err := writer.DeleteFolder(path); err != nil {
+		discardTrashItem(trashed)
 		log.Printf("Error deleting folder: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to delete folder: %v", err),
@@ -625,12 +821,17 @@ func DeleteFolderHandler(c *gin.Context) {
 	}
 
 	log.Printf("Successfully deleted folder: %s", path)
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionFolderDelete,
+		Path:   path,
+	})
+	folderChanged(c, notify.ActionDeleted, path)
 	log.Printf("=== DeleteFolderHandler END ===")
 
 	// Return success response with redirect URL
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
-		"message":  fmt.Sprintf("Folder '%s' deleted successfully", path),
+		"message":  fmt.Sprintf("Folder '%s' moved to trash", path),
 		"redirect": redirectURL,
 	})
 }
@@ -649,14 +850,14 @@ type FolderTreeItem struct {
 func CategoryHandler(c *gin.Context) {
 	pathParam := c.Param("path")
 
-	// When using *path, the param includes the leading slash,
-	// so we need to trim it to get the actual path
-	path := strings.TrimPrefix(pathParam, "/")
+	// When using *path, the param includes the leading slash; this is the
+	// same cleaned path the access check saw
+	path := WildcardPathFromRequest(c)
 
 	log.Printf("=== CategoryHandler START: %s (from param: %s) ===", path, pathParam)
 
-	// Force refresh parameter check
-	forceRefresh := c.Query("refresh") == "true"
+	// Force refresh parameter check; anonymous visitors can't flush the cache
+	forceRefresh := c.Query("refresh") == "true" && !currentUser(c).IsGuest()
 	if forceRefresh {
 		log.Printf("Force refresh requested, invalidating folder cache")
 		// Access the storage as CachedGitHubStorage to invalidate cache
@@ -668,7 +869,7 @@ func CategoryHandler(c *gin.Context) {
 	}
 
 	// Get the folder tree using our helper function
-	folderTree, err := GetFolderTree(store, path)
+	folderTree, err := GetFolderTree(store, path, viewFilter(c))
 	if err != nil {
 		log.Printf("Error building folder tree: %v", err)
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
@@ -687,6 +888,8 @@ func CategoryHandler(c *gin.Context) {
 		return
 	}
 
+	notes = filterPages(notes, viewFilter(c))
+
 	log.Printf("Found %d notes in folder %s", len(notes), path)
 	for _, note := range notes {
 		log.Printf("Note: %s, Path: %s", note.Title, note.Path)
@@ -702,8 +905,8 @@ func CategoryHandler(c *gin.Context) {
 		return
 	}
 
-	// Get the subfolders for this folder
-	subFolders := getDirectChildren(allFolders, path)
+	// Get the subfolders for this folder that the user may see
+	subFolders := getDirectChildren(filterFolders(allFolders, viewFilter(c)), path)
 
 	// Set folder name - use last part of path or "Home" for root
 	folderName := getNameFromPath(path)
@@ -756,9 +959,12 @@ func CategoryHandler(c *gin.Context) {
 		"MaxLevel":        maxLevel,
 		"MaxLevelReached": isMaxLevel,
 		"ParentFolderSha": parentFolderSha,
-		"User": gin.H{
-			"Name": "Admin",
-		},
+		"CanEdit":         can(c, path, access.RoleEditor),
+		"CanAdmin":        can(c, path, access.RoleAdmin),
+		"WatchingFolder":  isWatching(c, path, true),
+		"FavoriteFolder":  isFavorite(c, path, true),
+		"PopularPages":    popularInFolder(c, path),
+		"User":            c.MustGet("user"),
 	})
 
 	log.Printf("=== CategoryHandler END ===")
@@ -932,13 +1138,15 @@ func buildFolderSubtree(folders []string, rootFolder string, path string) []Fold
 	return children
 }
 
-// GetFolderTree retrieves the folder tree structure for the sidebar
-func GetFolderTree(store storage.Storage, currentPath string) ([]FolderTreeItem, error) {
+// GetFolderTree retrieves the folder tree structure for the sidebar, leaving
+// out any folder or note the visible predicate rejects
+func GetFolderTree(store storage.Storage, currentPath string, visible func(string) bool) ([]FolderTreeItem, error) {
 	// Get all folders
 	allFolders, err := store.ListFolders()
 	if err != nil {
 		return nil, err
 	}
+	allFolders = filterFolders(allFolders, visible)
 
 	// Create a map of folders to determine parent-child relationships
 	folderPathsMap := make(map[string]bool)
@@ -954,7 +1162,7 @@ func GetFolderTree(store storage.Storage, currentPath string) ([]FolderTreeItem,
 	if err != nil {
 		log.Printf("Error getting root notes: %v", err)
 	} else {
-		for _, note := range rootNotes {
+		for _, note := range filterPages(rootNotes, visible) {
 			noteItem := FolderTreeItem{
 				Name:        note.Title,
 				Path:        note.Path,
@@ -1013,12 +1221,12 @@ func GetFolderTree(store storage.Storage, currentPath string) ([]FolderTreeItem,
 func GetFolderChildrenHandler(c *gin.Context) {
 	pathParam := c.Param("path")
 
-	// Trim the leading slash from the path parameter
-	parentPath := strings.TrimPrefix(pathParam, "/")
+	// The cleaned path without the leading slash, as the access check saw it
+	parentPath := WildcardPathFromRequest(c)
 
 	log.Printf("=== GetFolderChildrenHandler START: %s (from param: %s) ===", parentPath, pathParam)
 
-	// Get all folders
+	// Get all folders the user may see
 	allFolders, err := store.ListFolders()
 	if err != nil {
 		log.Printf("Error getting folders: %v", err)
@@ -1027,6 +1235,7 @@ func GetFolderChildrenHandler(c *gin.Context) {
 		})
 		return
 	}
+	allFolders = filterFolders(allFolders, viewFilter(c))
 
 	// Get direct children folders of the specified folder
 	var children []FolderTreeItem
@@ -1061,7 +1270,7 @@ func GetFolderChildrenHandler(c *gin.Context) {
 		// Continue even if we couldn't get notes
 	} else {
 		// Add notes as children
-		for _, note := range notes {
+		for _, note := range filterPages(notes, viewFilter(c)) {
 			// Extract just the filename without .txt extension
 			noteName := note.Title
 
@@ -1120,6 +1329,7 @@ func HandleSync(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, audit.Entry{Action: audit.ActionSync})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Sync completed successfully"})
 }
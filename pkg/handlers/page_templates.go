@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/gin-gonic/gin"
+)
+
+// templatesFolder holds the pages offered as templates on the new page form
+const templatesFolder = "_templates"
+
+// PageTemplate is a template offered on the new page form, with its
+// placeholders already filled in
+type PageTemplate struct {
+	Name    string
+	Content string
+}
+
+// listPageTemplates returns the templates the user can see, with placeholders
+// filled in for a new page in the given folder
+func listPageTemplates(c *gin.Context, folderPath string) []PageTemplate {
+	pages, err := store.GetPagesInFolder(templatesFolder)
+	if err != nil {
+		// No _templates folder yet
+		return nil
+	}
+
+	fill := placeholderReplacer(c, folderPath)
+	var templates []PageTemplate
+	for _, page := range pages {
+		name := strings.TrimSuffix(page.Title, ".txt")
+		if !can(c, templatesFolder+"/"+name, access.RoleViewer) {
+			continue
+		}
+		templates = append(templates, PageTemplate{
+			Name:    name,
+			Content: fill.Replace(page.Content),
+		})
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// placeholderReplacer fills in the placeholders supported in templates
+func placeholderReplacer(c *gin.Context, folderPath string) *strings.Replacer {
+	user := currentUser(c)
+	now := time.Now()
+	name := user.Name
+	if name == "" {
+		name = user.Email
+	}
+	return strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{time}}", now.Format("15:04"),
+		"{{datetime}}", now.Format("2006-01-02 15:04"),
+		"{{user}}", name,
+		"{{email}}", user.Email,
+		"{{folder}}", folderPath,
+	)
+}
+
+// defaultTemplateFor returns the template named in the .folder metadata of the
+// folder or its nearest ancestor that names one
+func defaultTemplateFor(folderPath string) string {
+	reader, ok := store.(types.FolderMetaReader)
+	if !ok {
+		return ""
+	}
+	for folder := folderPath; folder != ""; folder = getParentPath(folder) {
+		content, err := reader.ReadFolderMeta(folder)
+		if err != nil {
+			continue
+		}
+		if name := parseFolderMeta(content)["template"]; name != "" {
+			log.Printf("Folder %s uses default template %s", folder, name)
+			return name
+		}
+	}
+	return ""
+}
+
+// parseFolderMeta reads "key: value" lines from a .folder file. Other lines,
+// such as the marker text written when the folder was created, are ignored.
+func parseFolderMeta(content []byte) map[string]string {
+	meta := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" || strings.Contains(key, " ") {
+			continue
+		}
+		meta[key] = strings.TrimSpace(value)
+	}
+	return meta
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/gin-gonic/gin"
+)
+
+var accessPolicy *access.Policy
+
+// InitAccessHandlers sets the access policy used by handlers and tree builders
+func InitAccessHandlers(p *access.Policy) {
+	accessPolicy = p
+}
+
+// can reports whether the current user holds the role on the wiki path
+func can(c *gin.Context, wikiPath string, role access.Role) bool {
+	if accessPolicy == nil {
+		return true
+	}
+	return accessPolicy.Can(currentUser(c).Email, wikiPath, role)
+}
+
+// viewFilter returns a predicate telling whether the current user may see a path
+func viewFilter(c *gin.Context) func(string) bool {
+	return func(wikiPath string) bool {
+		return can(c, wikiPath, access.RoleViewer)
+	}
+}
+
+// filterFolders keeps only the folders accepted by the predicate
+func filterFolders(folders []string, visible func(string) bool) []string {
+	var result []string
+	for _, folder := range folders {
+		if visible(folder) {
+			result = append(result, folder)
+		}
+	}
+	return result
+}
+
+// filterPages keeps only the pages accepted by the predicate
+func filterPages(pages []types.Page, visible func(string) bool) []types.Page {
+	var result []types.Page
+	for _, page := range pages {
+		if visible(strings.TrimSuffix(page.Path, ".txt")) {
+			result = append(result, page)
+		}
+	}
+	return result
+}
+
+// requestPath returns the cleaned form of a wiki path taken from a request.
+// The path extractors below all go through it, so access.Require checks the
+// same path the handler then uses. Paths CleanPath rejects are returned as
+// they are, for Require and the policy to refuse.
+func requestPath(raw string) string {
+	if cleaned, err := access.CleanPath(raw); err == nil {
+		return cleaned
+	}
+	return raw
+}
+
+// PagePathFromRequest builds the wiki path from the :title param and the
+// optional folder query parameter used by the page routes
+func PagePathFromRequest(c *gin.Context) string {
+	title, err := url.QueryUnescape(c.Param("title"))
+	if err != nil {
+		title = c.Param("title")
+	}
+	folder, err := url.QueryUnescape(c.Query("folder"))
+	if err != nil {
+		folder = c.Query("folder")
+	}
+	folder = strings.TrimSuffix(folder, "/")
+	if folder == "" {
+		return requestPath(title)
+	}
+	if title == "" || title == "new" {
+		return requestPath(folder)
+	}
+	return requestPath(folder + "/" + title)
+}
+
+// WildcardPathFromRequest returns the *path route parameter without its leading slash
+func WildcardPathFromRequest(c *gin.Context) string {
+	return requestPath(strings.TrimPrefix(c.Param("path"), "/"))
+}
+
+// QueryPathFromRequest returns the "path" query parameter
+func QueryPathFromRequest(c *gin.Context) string {
+	return requestPath(c.Query("path"))
+}
+
+// FolderQueryFromRequest returns the "folder" query parameter
+func FolderQueryFromRequest(c *gin.Context) string {
+	return requestPath(c.Query("folder"))
+}
+
+// RootPath is used for actions that apply to the whole wiki
+func RootPath(c *gin.Context) string {
+	return ""
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/presence"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	presenceStore presence.Store
+	softLock      bool
+)
+
+// InitPresenceHandlers sets the store used to track who is editing a page.
+// With lock enabled, opening a page someone else is editing shows a warning
+// first.
+func InitPresenceHandlers(s presence.Store, lock bool) {
+	presenceStore = s
+	softLock = lock
+}
+
+// otherEditors returns everyone but the current user who has the page open.
+// Anonymous visitors aren't told who is editing.
+func otherEditors(c *gin.Context, pagePath string) []presence.Editor {
+	if presenceStore == nil || currentUser(c).IsGuest() {
+		return nil
+	}
+	editors, err := presenceStore.List(pagePath)
+	if err != nil {
+		log.Printf("Error loading presence: %v", err)
+		return nil
+	}
+
+	email := currentUser(c).Email
+	var others []presence.Editor
+	for _, editor := range editors {
+		if !strings.EqualFold(editor.Email, email) {
+			others = append(others, editor)
+		}
+	}
+	return others
+}
+
+// touchPresence records that the current user has the page open
+func touchPresence(c *gin.Context, pagePath string) {
+	if presenceStore == nil {
+		return
+	}
+	user := currentUser(c)
+	name := user.Name
+	if name == "" {
+		name = user.Email
+	}
+	if err := presenceStore.Touch(pagePath, user.Email, name); err != nil {
+		log.Printf("Warning: failed to record presence: %v", err)
+	}
+}
+
+// PresenceHandler lists who else is editing a page
+func PresenceHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"editors": nonNilEditors(otherEditors(c, PagePathFromRequest(c))),
+	})
+}
+
+// HeartbeatHandler keeps the current user listed as an editor of a page and
+// returns who else is editing it
+func HeartbeatHandler(c *gin.Context) {
+	pagePath := PagePathFromRequest(c)
+	touchPresence(c, pagePath)
+	c.JSON(http.StatusOK, gin.H{
+		"editors": nonNilEditors(otherEditors(c, pagePath)),
+	})
+}
+
+// LeavePresenceHandler removes the current user from a page's editors
+func LeavePresenceHandler(c *gin.Context) {
+	if presenceStore != nil {
+		if err := presenceStore.Leave(PagePathFromRequest(c), currentUser(c).Email); err != nil {
+			log.Printf("Warning: failed to clear presence: %v", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// nonNilEditors makes sure an empty list encodes as [] rather than null
+func nonNilEditors(editors []presence.Editor) []presence.Editor {
+	if editors == nil {
+		return []presence.Editor{}
+	}
+	return editors
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// sessionManager is nil when sessions live in cookies and can't be listed
+var sessionManager auth.SessionManager
+
+// InitSessionHandlers initializes the session listing and revocation handlers
+func InitSessionHandlers(manager auth.SessionManager) {
+	sessionManager = manager
+}
+
+// requireSessionManager responds with an error when sessions are kept in
+// cookies and reports whether the request can go on
+func requireSessionManager(c *gin.Context) bool {
+	if sessionManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Session management requires session.backend: redis",
+		})
+		return false
+	}
+	return true
+}
+
+// renderSessions shows a list of sessions, everyone's on the admin page
+func renderSessions(c *gin.Context, email string, admin bool) {
+	var list []auth.SessionInfo
+	if sessionManager != nil {
+		var err error
+		list, err = sessionManager.List(email)
+		if err != nil {
+			log.Printf("Error listing sessions: %v", err)
+		}
+	}
+
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	title := "Sessions"
+	if admin {
+		title = "Active Sessions"
+	}
+	c.HTML(http.StatusOK, "sessions.html", gin.H{
+		"Title":      title,
+		"Enabled":    sessionManager != nil,
+		"Admin":      admin,
+		"Sessions":   list,
+		"CurrentID":  sessions.Default(c).ID(),
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       currentUser(c),
+	})
+}
+
+// SessionsPageHandler shows where the current user is signed in
+func SessionsPageHandler(c *gin.Context) {
+	renderSessions(c, currentUser(c).Email, false)
+}
+
+// AdminSessionsPageHandler shows every signed-in session
+func AdminSessionsPageHandler(c *gin.Context) {
+	renderSessions(c, "", true)
+}
+
+// RevokeSessionHandler signs out one of the current user's sessions
+func RevokeSessionHandler(c *gin.Context) {
+	if !requireSessionManager(c) {
+		return
+	}
+
+	id := c.Param("id")
+	list, err := sessionManager.List(currentUser(c).Email)
+	if err != nil {
+		log.Printf("Error listing sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list sessions",
+		})
+		return
+	}
+	for _, session := range list {
+		if session.ID == id {
+			revokeSession(c, id)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "Session not found",
+	})
+}
+
+// AdminRevokeSessionHandler signs out any session
+func AdminRevokeSessionHandler(c *gin.Context) {
+	if !requireSessionManager(c) {
+		return
+	}
+	revokeSession(c, c.Param("id"))
+}
+
+// revokeSession ends a session and responds
+func revokeSession(c *gin.Context, id string) {
+	if err := sessionManager.Revoke(id); err != nil {
+		log.Printf("Error revoking session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke session",
+		})
+		return
+	}
+	log.Printf("%s revoked a session", currentUser(c).Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// revokeTokens deletes a user's personal access tokens along with their
+// sessions, responding with an error and reporting false when that fails
+func revokeTokens(c *gin.Context, email string) bool {
+	if _, err := tokenStore.RevokeUser(email); err != nil {
+		log.Printf("Error revoking tokens of %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke access tokens",
+		})
+		return false
+	}
+	return true
+}
+
+// AdminRevokeUserSessionsHandler signs a user out of every session
+func AdminRevokeUserSessionsHandler(c *gin.Context) {
+	if !requireSessionManager(c) {
+		return
+	}
+
+	email := c.Query("email")
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Email is required",
+		})
+		return
+	}
+	count, err := sessionManager.RevokeUser(email)
+	if err != nil {
+		log.Printf("Error revoking sessions of %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+	if !revokeTokens(c, email) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"revoked": count,
+	})
+}
+
+// LogoutEverywhereHandler signs the current user out of every browser,
+// including this one
+func LogoutEverywhereHandler(c *gin.Context) {
+	if !requireSessionManager(c) {
+		return
+	}
+
+	if _, err := sessionManager.RevokeUser(currentUser(c).Email); err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+	if !revokeTokens(c, currentUser(c).Email) {
+		return
+	}
+
+	// The current session is gone from the store; expire its cookie too
+	if err := auth.EndSession(sessions.Default(c)); err != nil {
+		log.Printf("Error clearing session: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"redirect": "/login",
+	})
+}
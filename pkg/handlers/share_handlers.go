@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/audit"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/share"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Wrong share link passwords allowed per visitor before a lockout
+	sharePasswordAttempts = 5
+	sharePasswordLockout  = 15 * time.Minute
+
+	// Wrong passwords allowed per link, from all visitors together, before
+	// each further attempt is delayed. Client addresses can be spoofed or
+	// rotated, so this bounds guessing whatever the address.
+	shareLinkAttempts = 20
+)
+
+var (
+	shareStore   *share.Store
+	shareLimiter *auth.LoginLimiter
+	// shareLinkLimiter slows down guessing on a link rather than locking it,
+	// so nobody can keep its recipients out
+	shareLinkLimiter *auth.LoginLimiter
+	shareBaseURL     string
+)
+
+// InitShareHandlers initializes the share link handlers
+func InitShareHandlers(s *share.Store, baseURL string) {
+	shareStore = s
+	shareBaseURL = baseURL
+	shareLimiter = auth.NewLoginLimiter(sharePasswordAttempts, sharePasswordLockout)
+	shareLinkLimiter = auth.NewBackoffLimiter(shareLinkAttempts, sharePasswordLockout)
+}
+
+// shareURL returns the public URL of a share link token
+func shareURL(token string) string {
+	return shareBaseURL + "/s/" + token
+}
+
+// shareUnlockedKey is the session key marking a password-protected link as unlocked
+func shareUnlockedKey(id string) string {
+	return "share_unlocked_" + id
+}
+
+// shareOptions returns the share dialog settings for a page, or nil when the
+// current user can't share it
+func shareOptions(c *gin.Context, pagePath string) gin.H {
+	if shareStore == nil || !can(c, pagePath, access.RoleEditor) {
+		return nil
+	}
+	cfg := config.GetConfig()
+	return gin.H{
+		"DefaultHours": cfg.Share.DefaultHours,
+		"MaxHours":     cfg.Share.MaxDays * 24,
+	}
+}
+
+// CreateShareHandler creates a share link for a page
+func CreateShareHandler(c *gin.Context) {
+	if shareStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Share links are not enabled"})
+		return
+	}
+
+	var requestBody struct {
+		Hours    int    `json:"hours"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	cfg := config.GetConfig()
+	hours := requestBody.Hours
+	if hours == 0 {
+		hours = cfg.Share.DefaultHours
+	}
+	if hours < 0 || hours > cfg.Share.MaxDays*24 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Share links can last between 1 hour and %d days", cfg.Share.MaxDays),
+		})
+		return
+	}
+
+	pagePath := PagePathFromRequest(c)
+	if !can(c, pagePath, access.RoleViewer) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to share this page"})
+		return
+	}
+	if _, err := store.GetPage(pagePath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Page not found"})
+		return
+	}
+
+	user := currentUser(c)
+	token, link, err := shareStore.Create(pagePath, user.Email, time.Duration(hours)*time.Hour, requestBody.Password)
+	if err != nil {
+		log.Printf("Error creating share link for %s: %v", pagePath, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionShareCreate,
+		Path:   pagePath,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"id":        link.ID,
+		"url":       shareURL(token),
+		"expiresAt": link.ExpiresAt,
+	})
+}
+
+// RevokeShareHandler revokes a share link; only its creator and admins may
+func RevokeShareHandler(c *gin.Context) {
+	if shareStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Share links are not enabled"})
+		return
+	}
+
+	id := c.Param("id")
+	link, ok := shareStore.Get(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if link.CreatedBy != currentUser(c).Email && !can(c, "", access.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the link's creator or an admin can revoke it"})
+		return
+	}
+
+	if err := shareStore.Revoke(id, currentUser(c).Email); err != nil {
+		log.Printf("Error revoking share link %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionShareRevoke,
+		Path:   link.Path,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// SharesPageHandler lists every active share link for admins
+func SharesPageHandler(c *gin.Context) {
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	// Link each shared page for the list
+	type shareRow struct {
+		share.Link
+		PageURL string
+	}
+	var links []shareRow
+	if shareStore != nil {
+		for _, link := range shareStore.Active() {
+			links = append(links, shareRow{Link: link, PageURL: pageURL(link.Path)})
+		}
+	}
+
+	c.HTML(http.StatusOK, "shares.html", gin.H{
+		"Title":      "Share Links",
+		"Enabled":    shareStore != nil,
+		"Links":      links,
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       currentUser(c),
+	})
+}
+
+// resolveShare returns the link in the URL, rendering an error page when it
+// can't be opened
+func resolveShare(c *gin.Context) (*share.Link, bool) {
+	// Keep the token out of Referer headers, search engines and caches
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Cache-Control", "no-store")
+
+	if shareStore == nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "Share links are not enabled",
+		})
+		return nil, false
+	}
+
+	link, err := shareStore.Resolve(c.Param("token"))
+	if err != nil {
+		log.Printf("Rejected share link from %s: %v", c.ClientIP(), err)
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "This share link is invalid, has expired or was revoked",
+		})
+		return nil, false
+	}
+	// A link shows no more than its creator could see now
+	if accessPolicy != nil && !accessPolicy.Can(link.CreatedBy, link.Path, access.RoleViewer) {
+		log.Printf("Refused share link %s: %s can no longer view %s", link.ID, link.CreatedBy, link.Path)
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "This share link is invalid, has expired or was revoked",
+		})
+		return nil, false
+	}
+	return link, true
+}
+
+// shareLockedMessage tells the visitor how long to wait before trying again
+func shareLockedMessage(wait time.Duration) string {
+	if wait < time.Minute {
+		return fmt.Sprintf("Too many wrong passwords. Try again in %d seconds.", int(math.Ceil(wait.Seconds())))
+	}
+	return fmt.Sprintf("Too many wrong passwords. Try again in %d minutes.", int(math.Ceil(wait.Minutes())))
+}
+
+// renderShareLocked shows the password prompt of a protected link
+func renderShareLocked(c *gin.Context, status int, message string) {
+	c.HTML(status, "share.html", gin.H{
+		"Locked":    true,
+		"Error":     message,
+		"CSRFToken": auth.CSRFToken(c),
+	})
+}
+
+// SharedPageHandler shows a shared page read-only, without signing in
+func SharedPageHandler(c *gin.Context) {
+	link, ok := resolveShare(c)
+	if !ok {
+		return
+	}
+
+	if link.HasPassword() && sessions.Default(c).Get(shareUnlockedKey(link.ID)) != true {
+		renderShareLocked(c, http.StatusOK, "")
+		return
+	}
+
+	page, err := store.GetPage(link.Path)
+	if err != nil {
+		log.Printf("Error getting shared page %s: %v", link.Path, err)
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "The shared page no longer exists",
+		})
+		return
+	}
+
+	log.Printf("Share link %s opened %s from %s", link.ID, link.Path, c.ClientIP())
+	c.HTML(http.StatusOK, "share.html", gin.H{
+		"Title":     page.Title,
+		"Content":   page.Content,
+		"ExpiresAt": link.ExpiresAt,
+	})
+}
+
+// UnlockShareHandler checks the password of a protected share link
+func UnlockShareHandler(c *gin.Context) {
+	link, ok := resolveShare(c)
+	if !ok {
+		return
+	}
+
+	key := link.ID + "|" + c.ClientIP()
+	if wait := max(shareLimiter.Locked(key), shareLinkLimiter.Locked(link.ID)); wait > 0 {
+		log.Printf("Share link %s password attempt from %s refused, locked out", link.ID, c.ClientIP())
+		renderShareLocked(c, http.StatusTooManyRequests, shareLockedMessage(wait))
+		return
+	}
+
+	if !shareStore.CheckPassword(link, c.PostForm("password")) {
+		log.Printf("Wrong share link %s password from %s", link.ID, c.ClientIP())
+		shareLimiter.Fail(key)
+		shareLinkLimiter.Fail(link.ID)
+		renderShareLocked(c, http.StatusUnauthorized, "Wrong password")
+		return
+	}
+	// The link's own count is left to expire, as others may still be guessing
+	shareLimiter.Reset(key)
+
+	session := sessions.Default(c)
+	session.Set(shareUnlockedKey(link.ID), true)
+	if err := session.Save(); err != nil {
+		log.Printf("Error saving session: %v", err)
+	}
+	c.Redirect(http.StatusSeeOther, c.Request.URL.Path)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+var tokenStore *auth.TokenStore
+
+// InitTokenHandlers initializes the personal access token handlers
+func InitTokenHandlers(tokens *auth.TokenStore) {
+	tokenStore = tokens
+}
+
+// currentUser returns the authenticated user set by the auth middleware
+func currentUser(c *gin.Context) auth.User {
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(auth.User); ok {
+			return user
+		}
+	}
+	return auth.User{}
+}
+
+// TokensPageHandler shows the current user's personal access tokens
+func TokensPageHandler(c *gin.Context) {
+	user := currentUser(c)
+
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	c.HTML(http.StatusOK, "tokens.html", gin.H{
+		"Title":      "Access Tokens",
+		"Tokens":     tokenStore.List(user.Email),
+		"Scopes":     []string{auth.ScopeRead, auth.ScopeWrite, auth.ScopeAdmin},
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       user,
+	})
+}
+
+// CreateTokenHandler mints a new token and returns its secret exactly once
+func CreateTokenHandler(c *gin.Context) {
+	var requestBody struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expiresInDays"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	var expiresAt *time.Time
+	if requestBody.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, requestBody.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	secret, token, err := tokenStore.Create(currentUser(c), requestBody.Name, requestBody.Scopes, expiresAt)
+	if err != nil {
+		log.Printf("Error creating token: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"token":   secret,
+		"id":      token.ID,
+	})
+}
+
+// RevokeTokenHandler deletes one of the current user's tokens
+func RevokeTokenHandler(c *gin.Context) {
+	id := c.Param("id")
+	if err := tokenStore.Revoke(currentUser(c).Email, id); err != nil {
+		log.Printf("Error revoking token %s: %v", id, err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
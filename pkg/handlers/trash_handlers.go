@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/audit"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/config"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/trash"
+	"github.com/gin-gonic/gin"
+)
+
+var trashBin *trash.Bin
+
+// InitTrashHandlers sets the trash bin deleted pages and folders are moved to
+func InitTrashHandlers(bin *trash.Bin) {
+	trashBin = bin
+}
+
+// moveToTrash keeps a copy of a page or folder before it is deleted. It
+// returns nil when the trash is disabled.
+func moveToTrash(c *gin.Context, kind, wikiPath string) (*trash.Item, error) {
+	if trashBin == nil {
+		return nil, nil
+	}
+	user := currentUser(c)
+	return trashBin.Put(kind, wikiPath, user.Email, user.Name)
+}
+
+// discardTrashItem drops a trash copy that is no longer needed, because the
+// delete it guarded failed or the page lives on under a new name
+func discardTrashItem(item *trash.Item) {
+	if item != nil {
+		trashBin.Discard(item.ID)
+	}
+}
+
+// TrashPageHandler lists deleted pages and folders the user can see
+func TrashPageHandler(c *gin.Context) {
+	var items []trash.Item
+	if trashBin != nil {
+		all, err := trashBin.List()
+		if err != nil {
+			log.Printf("Error listing trash: %v", err)
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+				"error": "Failed to load trash",
+			})
+			return
+		}
+		for _, item := range all {
+			if can(c, item.Path, access.RoleViewer) {
+				items = append(items, item)
+			}
+		}
+	}
+
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	c.HTML(http.StatusOK, "trash.html", gin.H{
+		"Title":         "Trash",
+		"Items":         items,
+		"CanPurge":      can(c, "", access.RoleAdmin),
+		"RetentionDays": config.GetConfig().Trash.RetentionDays,
+		"FolderTree":    folderTree,
+		"FolderPath":    "",
+		"User":          currentUser(c),
+	})
+}
+
+// RestoreTrashHandler puts a deleted page or folder back where it was
+func RestoreTrashHandler(c *gin.Context) {
+	if trashBin == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Trash is disabled"})
+		return
+	}
+
+	id := c.Param("id")
+	item, err := trashBin.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if !can(c, item.Path, access.RoleEditor) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "You don't have permission to restore this item",
+		})
+		return
+	}
+
+	if _, err := trashBin.Restore(id, storeFor(c, "Restore "+item.Kind+": "+item.Path)); err != nil {
+		log.Printf("Error restoring trash item %s: %v", id, err)
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "already exists") {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	invalidateStoreCache()
+
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionTrashRestore,
+		Path:   item.Path,
+	})
+	if item.Kind == trash.KindPage {
+		pageChanged(c, notify.ActionRestored, item.Path, "", nil, nil)
+	} else {
+		folderChanged(c, notify.ActionRestored, item.Path)
+	}
+
+	redirectURL := "/category/" + url.QueryEscape(item.Path)
+	if item.Kind == trash.KindPage {
+		redirectURL = "/view/" + url.QueryEscape(getNameFromPath(item.Path))
+		if parent := getParentPath(item.Path); parent != "" {
+			redirectURL += "?folder=" + url.QueryEscape(parent)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"redirect": redirectURL,
+	})
+}
+
+// PurgeTrashHandler permanently deletes an item from the trash
+func PurgeTrashHandler(c *gin.Context) {
+	if trashBin == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Trash is disabled"})
+		return
+	}
+
+	id := c.Param("id")
+	item, err := trashBin.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err := trashBin.Purge(id); err != nil {
+		log.Printf("Error purging trash item %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	recordAudit(c, audit.Entry{
+		Action: audit.ActionTrashPurge,
+		Path:   item.Path,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	watchStore *notify.WatchStore
+	notifier   *notify.Notifier
+)
+
+// InitWatchHandlers sets the watch lists and the notifier that mails changes.
+// The notifier may be nil when email is not configured; watches still work.
+func InitWatchHandlers(ws *notify.WatchStore, n *notify.Notifier) {
+	watchStore = ws
+	notifier = n
+
+	// Pages pulled in from GitHub never pass through the handlers
+	if reporter, ok := store.(types.SyncReporter); ok {
+		reporter.OnSync(notifySyncChanges)
+	}
+}
+
+// notifyPageChange tells watchers about a change to a page. Before and after
+// are the page contents, nil when the page didn't exist.
+func notifyPageChange(user auth.User, action, pagePath, oldPath string, before, after *string) {
+	change := notify.Change{
+		Action:    action,
+		Path:      pagePath,
+		OldPath:   oldPath,
+		Actor:     user.Email,
+		ActorName: user.Name,
+	}
+	if after == nil {
+		notifier.Notify(change)
+		return
+	}
+	previous := ""
+	if before != nil {
+		previous = *before
+	}
+	notifier.NotifyPage(change, previous, *after)
+}
+
+// notifyFolderChange tells watchers about a folder being created or deleted
+func notifyFolderChange(c *gin.Context, action, folderPath string) {
+	user := currentUser(c)
+	notifier.Notify(notify.Change{
+		Action:    action,
+		Path:      folderPath,
+		Folder:    true,
+		Actor:     user.Email,
+		ActorName: user.Name,
+	})
+}
+
+// notifySyncChanges tells watchers about pages a sync pulled in from GitHub
+func notifySyncChanges(changes []types.SyncChange) {
+	for _, sc := range changes {
+		action := notify.ActionUpdated
+		previous := ""
+		if sc.Before == nil {
+			action = notify.ActionCreated
+		} else {
+			previous = *sc.Before
+		}
+		notifier.NotifyPage(notify.Change{
+			Action:    action,
+			Path:      sc.Path,
+			ActorName: "GitHub sync",
+		}, previous, sc.After)
+	}
+}
+
+// isWatching reports whether the current user watches the page or folder
+func isWatching(c *gin.Context, wikiPath string, folder bool) bool {
+	if watchStore == nil {
+		return false
+	}
+	return watchStore.Watching(currentUser(c).Email, wikiPath, folder)
+}
+
+// WatchesPageHandler shows the current user's watches and digest setting
+func WatchesPageHandler(c *gin.Context) {
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	var sub notify.Subscriber
+	if watchStore != nil {
+		sub = watchStore.Get(currentUser(c).Email)
+	}
+
+	c.HTML(http.StatusOK, "watches.html", gin.H{
+		"Title":        "Watches",
+		"Watches":      sub.Watches,
+		"Digest":       sub.Digest,
+		"EmailEnabled": notifier != nil,
+		"FolderTree":   folderTree,
+		"CurrentPath":  "",
+		"FolderPath":   "",
+		"User":         c.MustGet("user"),
+	})
+}
+
+// watchRequest identifies a page or folder to watch
+type watchRequest struct {
+	Path   string `json:"path"`
+	Folder bool   `json:"folder"`
+}
+
+// WatchHandler starts watching a page or folder
+func WatchHandler(c *gin.Context) {
+	if watchStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Watches are disabled"})
+		return
+	}
+
+	var req watchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+	req.Path = strings.Trim(req.Path, "/")
+	if req.Path == "" && !req.Folder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Page path is required"})
+		return
+	}
+	if !can(c, req.Path, access.RoleViewer) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to view this page"})
+		return
+	}
+
+	user := currentUser(c)
+	if err := watchStore.Add(user.Email, user.Name, req.Path, req.Folder); err != nil {
+		log.Printf("Error adding watch: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watch"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "watching": true})
+}
+
+// UnwatchHandler stops watching a page or folder
+func UnwatchHandler(c *gin.Context) {
+	if watchStore == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "watching": false})
+		return
+	}
+
+	wikiPath := strings.Trim(c.Query("path"), "/")
+	folder := c.Query("folder") == "true"
+	if err := watchStore.Remove(currentUser(c).Email, wikiPath, folder); err != nil {
+		log.Printf("Error removing watch: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unwatch"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "watching": false})
+}
+
+// DigestHandler changes how the current user's notifications are delivered
+func DigestHandler(c *gin.Context) {
+	if watchStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Watches are disabled"})
+		return
+	}
+
+	var req struct {
+		Digest string `json:"digest"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !notify.ValidDigest(req.Digest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Digest must be \"immediate\" or \"daily\""})
+		return
+	}
+
+	user := currentUser(c)
+	if err := watchStore.SetDigest(user.Email, user.Name, req.Digest); err != nil {
+		log.Printf("Error saving digest setting: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save setting"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "digest": req.Digest})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/notify"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+var webhookManager *webhooks.Manager
+
+// InitWebhookHandlers sets the manager that delivers change events to
+// outgoing webhooks
+func InitWebhookHandlers(m *webhooks.Manager) {
+	webhookManager = m
+
+	if reporter, ok := store.(types.SyncReporter); ok {
+		reporter.OnSync(emitSyncEvents)
+	}
+}
+
+// pageChanged tells watchers, webhooks and the change journal about a change
+// to a page. Before and after are the page contents, nil when the page didn't
+// exist.
+func pageChanged(c *gin.Context, action, pagePath, oldPath string, before, after *string) {
+	pageChangedBy(currentUser(c), action, pagePath, oldPath, before, after)
+}
+
+// pageChangedBy is pageChanged for changes made outside a request, such as
+// the save of a collaborative editing session
+func pageChangedBy(user auth.User, action, pagePath, oldPath string, before, after *string) {
+	notifyPageChange(user, action, pagePath, oldPath, before, after)
+	emitPageEvent(action, pagePath, oldPath, user.Email, user.Name)
+	recordChange(action, pagePath, oldPath, false, user.Email, user.Name)
+}
+
+// folderChanged tells watchers, webhooks and the change journal about a
+// folder being created, deleted or restored
+func folderChanged(c *gin.Context, action, folderPath string) {
+	notifyFolderChange(c, action, folderPath)
+
+	user := currentUser(c)
+	recordChange(action, folderPath, "", true, user.Email, user.Name)
+
+	event := webhooks.EventFolderCreated
+	if action == notify.ActionDeleted {
+		event = webhooks.EventFolderDeleted
+	}
+	webhookManager.Emit(webhooks.Event{
+		Type:      event,
+		Path:      folderPath,
+		Actor:     user.Email,
+		ActorName: user.Name,
+	})
+}
+
+// emitPageEvent sends a page change to webhooks. A move is sent as the old
+// page being deleted and the new one created, so receivers that only track
+// paths stay correct.
+func emitPageEvent(action, pagePath, oldPath, actor, actorName string) {
+	event := webhooks.Event{
+		Path:      pagePath,
+		Actor:     actor,
+		ActorName: actorName,
+	}
+	switch action {
+	case notify.ActionCreated, notify.ActionRestored:
+		event.Type = webhooks.EventPageCreated
+	case notify.ActionDeleted:
+		event.Type = webhooks.EventPageDeleted
+	case notify.ActionMoved:
+		deleted := event
+		deleted.Type = webhooks.EventPageDeleted
+		deleted.Path = oldPath
+		webhookManager.Emit(deleted)
+
+		event.Type = webhooks.EventPageCreated
+		event.OldPath = oldPath
+	default:
+		event.Type = webhooks.EventPageUpdated
+	}
+	webhookManager.Emit(event)
+}
+
+// emitSyncEvents sends the pages a GitHub sync pulled in, then sync.completed
+func emitSyncEvents(changes []types.SyncChange) {
+	paths := make([]string, 0, len(changes))
+	for _, sc := range changes {
+		action := notify.ActionUpdated
+		if sc.Before == nil {
+			action = notify.ActionCreated
+		}
+		emitPageEvent(action, sc.Path, "", "", "GitHub sync")
+		paths = append(paths, sc.Path)
+	}
+	webhookManager.Emit(webhooks.Event{
+		Type:      webhooks.EventSyncCompleted,
+		ActorName: "GitHub sync",
+		Paths:     paths,
+	})
+}
+
+// WebhooksPageHandler shows registered webhooks and recent deliveries
+func WebhooksPageHandler(c *gin.Context) {
+	folderTree, err := GetFolderTree(store, "", viewFilter(c))
+	if err != nil {
+		log.Printf("Error building folder tree: %v", err)
+		// Continue without folder tree - not critical
+	}
+
+	var hooks []webhooks.Hook
+	var deliveries []webhooks.Delivery
+	hookID := c.Query("hook")
+	if webhookManager != nil {
+		hooks = webhookManager.Hooks()
+		deliveries = webhookManager.Deliveries(hookID, 200)
+	}
+
+	c.HTML(http.StatusOK, "webhooks.html", gin.H{
+		"Title":      "Webhooks",
+		"Hooks":      hooks,
+		"Deliveries": deliveries,
+		"HookFilter": hookID,
+		"Events":     webhooks.Events,
+		"FolderTree": folderTree,
+		"FolderPath": "",
+		"User":       currentUser(c),
+	})
+}
+
+// createWebhookRequest is the body of a webhook registration
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Folder string   `json:"folder"`
+}
+
+// CreateWebhookHandler registers a webhook. The signing secret is only
+// returned in this response.
+func CreateWebhookHandler(c *gin.Context) {
+	if webhookManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhooks are disabled"})
+		return
+	}
+
+	var req createWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	hook, err := webhookManager.Create(req.URL, req.Events, strings.TrimSpace(req.Folder), currentUser(c).Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"id":      hook.ID,
+		"secret":  hook.Secret,
+	})
+}
+
+// UpdateWebhookHandler pauses or resumes a webhook
+func UpdateWebhookHandler(c *gin.Context) {
+	if webhookManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhooks are disabled"})
+		return
+	}
+
+	var req struct {
+		Active bool `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse request: %v", err),
+		})
+		return
+	}
+
+	if err := webhookManager.SetActive(c.Param("id"), req.Active); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "active": req.Active})
+}
+
+// DeleteWebhookHandler removes a webhook
+func DeleteWebhookHandler(c *gin.Context) {
+	if webhookManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhooks are disabled"})
+		return
+	}
+
+	if err := webhookManager.Delete(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// TestWebhookHandler sends a ping event to a webhook
+func TestWebhookHandler(c *gin.Context) {
+	if webhookManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhooks are disabled"})
+		return
+	}
+
+	if err := webhookManager.Ping(c.Param("id"), currentUser(c).Email); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"log"
 	"strings"
 	"time"
 
@@ -31,45 +30,6 @@ func LoadPage(path string, store types.Storage) (*Page, error) {
 	}, nil
 }
 
-// MenuItem represents an item in the side menu
-type MenuItem struct {
-	Title  string
-	URL    string
-	Active bool
-}
-
-// GetMenuItems returns the side menu items
-func GetMenuItems(currentPage string, store types.Storage) []MenuItem {
-	// Get all pages
-	pages, err := GetAllPages(store)
-	if err != nil {
-		return []MenuItem{}
-	}
-
-	// Normalize the current page - strip any .txt extension
-	currentPage = strings.TrimSuffix(currentPage, ".txt")
-
-	// Debug the current page to help troubleshoot
-	log.Printf("GetMenuItems: Current page is '%s'", currentPage)
-
-	// Create menu items from existing pages
-	var items []MenuItem
-	for _, page := range pages {
-		isActive := currentPage == page.Title
-		if isActive {
-			log.Printf("Found active page: %s", page.Title)
-		}
-
-		items = append(items, MenuItem{
-			Title:  page.Title,
-			URL:    "/view/" + page.Title,
-			Active: isActive,
-		})
-	}
-
-	return items
-}
-
 // GetPreview returns a preview of the page content
 func (p *Page) GetPreview() string {
 	// Convert markdown to plain text for preview
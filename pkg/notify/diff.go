@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxDiffLines caps how many changed lines a summary shows
+	maxDiffLines = 20
+
+	// maxDiffCells bounds the line-by-line comparison; larger pages only get
+	// counts of added and removed lines
+	maxDiffCells = 4_000_000
+)
+
+// Summarize describes how a page changed as a short line diff, e.g.
+//
+//	+2 -1 lines
+//	- old line
+//	+ new line
+func Summarize(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// Skip the unchanged head and tail so only the edited region is compared
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a = a[prefix : len(a)-suffix]
+	b = b[prefix : len(b)-suffix]
+
+	if len(a)*len(b) > maxDiffCells {
+		return fmt.Sprintf("+%d -%d lines", len(b), len(a))
+	}
+
+	lines := diffLines(a, b)
+	added, removed := 0, 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "+") {
+			added++
+		} else {
+			removed++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "+%d -%d lines", added, removed)
+	for i, line := range lines {
+		if i == maxDiffLines {
+			fmt.Fprintf(&sb, "\n... %d more changed lines", len(lines)-maxDiffLines)
+			break
+		}
+		sb.WriteString("\n")
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// splitLines splits content into lines, treating "" as no lines at all
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines returns the removed ("- ") and added ("+ ") lines between a and b
+// using a longest common subsequence
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, "+ "+b[j])
+			j++
+		default:
+			lines = append(lines, "- "+a[i])
+			i++
+		}
+	}
+	return lines
+}
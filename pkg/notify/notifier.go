@@ -0,0 +1,324 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Change actions
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionMoved    = "moved"
+	ActionRestored = "restored"
+)
+
+// Change is one change to a page or folder that watchers hear about
+type Change struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Path      string    `json:"path"`
+	OldPath   string    `json:"old_path,omitempty"`
+	Folder    bool      `json:"folder,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	ActorName string    `json:"actor_name,omitempty"`
+	Summary   string    `json:"summary,omitempty"`
+}
+
+// Options configures a Notifier
+type Options struct {
+	Watches *WatchStore
+	Mailer  Mailer
+
+	// BaseURL is prepended to links in emails, e.g. https://wiki.example.com
+	BaseURL string
+
+	// StateFile keeps queued changes across restarts
+	StateFile string
+
+	// BatchDelay is how long immediate notifications wait for more changes
+	// before they are sent together
+	BatchDelay time.Duration
+
+	// DigestHour is the local hour at which daily digests go out
+	DigestHour int
+
+	// CanView reports whether a user may still see a path; nil allows all
+	CanView func(email, wikiPath string) bool
+}
+
+// queue holds the changes waiting to be mailed to one user
+type queue struct {
+	Email   string   `json:"email"`
+	Name    string   `json:"name"`
+	Changes []Change `json:"changes"`
+}
+
+// state is what the notifier persists between restarts
+type state struct {
+	Pending    []*queue  `json:"pending"`
+	LastDigest time.Time `json:"last_digest"`
+}
+
+// Notifier queues changes for watchers and mails them in batches
+type Notifier struct {
+	opts       Options
+	mu         sync.Mutex
+	pending    map[string]*queue
+	lastDigest time.Time
+}
+
+// NewNotifier creates a notifier, restoring changes queued before a restart
+func NewNotifier(opts Options) (*Notifier, error) {
+	n := &Notifier{opts: opts, pending: make(map[string]*queue)}
+
+	data, err := os.ReadFile(opts.StateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read notification state: %v", err)
+	}
+	if len(data) > 0 {
+		var st state
+		if err := json.Unmarshal(data, &st); err != nil {
+			return nil, fmt.Errorf("failed to parse notification state: %v", err)
+		}
+		for _, q := range st.Pending {
+			n.pending[strings.ToLower(q.Email)] = q
+		}
+		n.lastDigest = st.LastDigest
+	}
+	log.Printf("Notifications enabled, %d users with queued changes", len(n.pending))
+	return n, nil
+}
+
+// save persists the queued changes. The caller must hold the lock.
+func (n *Notifier) save() {
+	st := state{LastDigest: n.lastDigest}
+	for _, q := range n.pending {
+		st.Pending = append(st.Pending, q)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		log.Printf("Error encoding notification state: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(n.opts.StateFile), 0700); err != nil {
+		log.Printf("Error creating notification directory: %v", err)
+		return
+	}
+	tmp := n.opts.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("Error writing notification state: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, n.opts.StateFile); err != nil {
+		log.Printf("Error saving notification state: %v", err)
+	}
+}
+
+// Notify queues a change for everyone watching it, except whoever made it.
+// It is safe to call on a nil notifier.
+func (n *Notifier) Notify(change Change) {
+	if n == nil {
+		return
+	}
+	if change.Time.IsZero() {
+		change.Time = time.Now().UTC()
+	}
+	if recipients := n.recipients(change); len(recipients) > 0 {
+		n.enqueue(change, recipients)
+	}
+}
+
+// NotifyPage queues a page edit like Notify. Summarizing the edit can take a
+// while on large pages, so it is only done when somebody is to hear about it,
+// and in the background. It is safe to call on a nil notifier.
+func (n *Notifier) NotifyPage(change Change, before, after string) {
+	if n == nil {
+		return
+	}
+	if change.Time.IsZero() {
+		change.Time = time.Now().UTC()
+	}
+	recipients := n.recipients(change)
+	if len(recipients) == 0 {
+		return
+	}
+	go func() {
+		change.Summary = Summarize(before, after)
+		n.enqueue(change, recipients)
+	}()
+}
+
+// recipients returns the watchers who should hear about a change: everyone
+// watching it who may still see it, except whoever made it
+func (n *Notifier) recipients(change Change) []Subscriber {
+	watchers := n.opts.Watches.Watchers(change.Path, change.Folder)
+	if change.OldPath != "" {
+		watchers = append(watchers, n.opts.Watches.Watchers(change.OldPath, change.Folder)...)
+	}
+
+	var result []Subscriber
+	seen := make(map[string]bool)
+	for _, w := range watchers {
+		key := strings.ToLower(w.Email)
+		if seen[key] || strings.EqualFold(w.Email, change.Actor) {
+			continue
+		}
+		seen[key] = true
+		if n.opts.CanView != nil && !n.opts.CanView(w.Email, change.Path) {
+			continue
+		}
+		result = append(result, w)
+	}
+	return result
+}
+
+// enqueue adds a change to the queues of its recipients
+func (n *Notifier) enqueue(change Change, recipients []Subscriber) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, w := range recipients {
+		key := strings.ToLower(w.Email)
+		q, ok := n.pending[key]
+		if !ok {
+			q = &queue{Email: w.Email}
+			n.pending[key] = q
+		}
+		q.Name = w.Name
+		q.Changes = append(q.Changes, change)
+	}
+	n.save()
+}
+
+// Start sends due notifications every minute in the background
+func (n *Notifier) Start() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			n.Flush(now)
+		}
+	}()
+}
+
+// Flush mails every queue that is due at the given time
+func (n *Notifier) Flush(now time.Time) {
+	n.mu.Lock()
+	today := now.Format("2006-01-02")
+	digestDue := now.Hour() >= n.opts.DigestHour && n.lastDigest.Format("2006-01-02") != today
+
+	var due []*queue
+	for key, q := range n.pending {
+		if len(q.Changes) == 0 {
+			delete(n.pending, key)
+			continue
+		}
+		digest := n.opts.Watches.Get(q.Email).Digest
+		if (digest == DigestDaily && digestDue) ||
+			(digest != DigestDaily && now.Sub(q.Changes[0].Time) >= n.opts.BatchDelay) {
+			due = append(due, q)
+			delete(n.pending, key)
+		}
+	}
+	if digestDue {
+		n.lastDigest = now
+	}
+	if len(due) > 0 || digestDue {
+		n.save()
+	}
+	n.mu.Unlock()
+
+	for _, q := range due {
+		subject, body := n.compose(q)
+		if err := n.opts.Mailer.Send(q.Email, subject, body); err != nil {
+			// Put the changes back in front of anything queued meanwhile
+			log.Printf("Error sending notification: %v", err)
+			n.mu.Lock()
+			key := strings.ToLower(q.Email)
+			if later, ok := n.pending[key]; ok {
+				q.Changes = append(q.Changes, later.Changes...)
+			}
+			n.pending[key] = q
+			n.save()
+			n.mu.Unlock()
+			continue
+		}
+		log.Printf("Sent %d change notifications to %s", len(q.Changes), q.Email)
+	}
+}
+
+// compose writes the email for a queue of changes
+func (n *Notifier) compose(q *queue) (string, string) {
+	var subject string
+	if len(q.Changes) == 1 {
+		c := q.Changes[0]
+		subject = fmt.Sprintf("[Wiki] %s was %s", c.Path, c.Action)
+		if c.ActorName != "" {
+			subject += " by " + c.ActorName
+		}
+	} else {
+		subject = fmt.Sprintf("[Wiki] %d changes to pages you watch", len(q.Changes))
+	}
+
+	var sb strings.Builder
+	name := q.Name
+	if name == "" {
+		name = q.Email
+	}
+	fmt.Fprintf(&sb, "Hi %s,\n\n", name)
+	if len(q.Changes) == 1 {
+		sb.WriteString("A page you watch has changed:\n")
+	} else {
+		fmt.Fprintf(&sb, "%d changes were made to pages you watch:\n", len(q.Changes))
+	}
+
+	for _, c := range q.Changes {
+		sb.WriteString("\n")
+		label := "Page"
+		if c.Folder {
+			label = "Folder"
+		}
+		fmt.Fprintf(&sb, "* %s %s was %s", label, c.Path, c.Action)
+		if c.OldPath != "" {
+			fmt.Fprintf(&sb, " from %s", c.OldPath)
+		}
+		if c.ActorName != "" {
+			fmt.Fprintf(&sb, " by %s", c.ActorName)
+		}
+		fmt.Fprintf(&sb, " on %s UTC\n", c.Time.UTC().Format("2006-01-02 15:04"))
+		fmt.Fprintf(&sb, "  %s\n", n.link(c))
+		if c.Summary != "" {
+			for _, line := range strings.Split(c.Summary, "\n") {
+				fmt.Fprintf(&sb, "    %s\n", line)
+			}
+		}
+	}
+
+	fmt.Fprintf(&sb, "\nManage your watches at %s/settings/watches\n", n.opts.BaseURL)
+	return subject, sb.String()
+}
+
+// link points at the changed page, its folder, or the trash once deleted
+func (n *Notifier) link(c Change) string {
+	switch {
+	case c.Action == ActionDeleted:
+		return n.opts.BaseURL + "/trash"
+	case c.Folder:
+		return n.opts.BaseURL + "/category/" + c.Path
+	}
+	link := n.opts.BaseURL + "/view/" + url.PathEscape(path.Base(c.Path))
+	if folder := path.Dir(c.Path); folder != "." {
+		link += "?folder=" + url.QueryEscape(folder)
+	}
+	return link
+}
@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Digest modes: immediate batches changes for a few minutes, daily sends
+	// one email a day
+	DigestImmediate = "immediate"
+	DigestDaily     = "daily"
+)
+
+// ValidDigest reports whether the digest mode is known
+func ValidDigest(digest string) bool {
+	return digest == DigestImmediate || digest == DigestDaily
+}
+
+// Watch is a page, or a folder and everything below it, that a user follows.
+// The empty folder path watches the whole wiki.
+type Watch struct {
+	Path      string    `json:"path"`
+	Folder    bool      `json:"folder"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether a change to the page path concerns this watch
+func (w Watch) Matches(pagePath string) bool {
+	if !w.Folder {
+		return w.Path == pagePath
+	}
+	return w.Path == "" || pagePath == w.Path || strings.HasPrefix(pagePath, w.Path+"/")
+}
+
+// Subscriber is a user's watch list and delivery preference
+type Subscriber struct {
+	Email   string  `json:"email"`
+	Name    string  `json:"name"`
+	Digest  string  `json:"digest"`
+	Watches []Watch `json:"watches"`
+}
+
+// WatchStore persists watch lists in a JSON file
+type WatchStore struct {
+	mu          sync.Mutex
+	path        string
+	subscribers map[string]*Subscriber
+}
+
+// NewWatchStore loads the watch file, creating an empty store if it doesn't exist
+func NewWatchStore(path string) (*WatchStore, error) {
+	s := &WatchStore{path: path, subscribers: make(map[string]*Subscriber)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("No watch file at %s, starting with no watches", path)
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read watch file: %v", err)
+	}
+
+	var subscribers []*Subscriber
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &subscribers); err != nil {
+			return nil, fmt.Errorf("failed to parse watch file: %v", err)
+		}
+	}
+	for _, sub := range subscribers {
+		s.subscribers[strings.ToLower(sub.Email)] = sub
+	}
+	log.Printf("Loaded watch lists for %d users", len(s.subscribers))
+	return s, nil
+}
+
+// save writes the watch lists to disk. The caller must hold the lock.
+func (s *WatchStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create watch directory: %v", err)
+	}
+
+	subscribers := make([]*Subscriber, 0, len(s.subscribers))
+	for _, sub := range s.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].Email < subscribers[j].Email
+	})
+
+	data, err := json.MarshalIndent(subscribers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal watches: %v", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write watch file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace watch file: %v", err)
+	}
+	return nil
+}
+
+// subscriber returns the user's entry, creating it if needed. The caller must
+// hold the lock.
+func (s *WatchStore) subscriber(email, name string) *Subscriber {
+	key := strings.ToLower(email)
+	sub, ok := s.subscribers[key]
+	if !ok {
+		sub = &Subscriber{Email: email, Digest: DigestImmediate}
+		s.subscribers[key] = sub
+	}
+	if name != "" {
+		sub.Name = name
+	}
+	return sub
+}
+
+// Get returns a copy of the user's watch list
+func (s *WatchStore) Get(email string) Subscriber {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscribers[strings.ToLower(email)]
+	if !ok {
+		return Subscriber{Email: email, Digest: DigestImmediate}
+	}
+	result := *sub
+	result.Watches = append([]Watch(nil), sub.Watches...)
+	return result
+}
+
+// Add starts watching a page or folder
+func (s *WatchStore) Add(email, name, wikiPath string, folder bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.subscriber(email, name)
+	for _, w := range sub.Watches {
+		if w.Path == wikiPath && w.Folder == folder {
+			return nil
+		}
+	}
+	sub.Watches = append(sub.Watches, Watch{Path: wikiPath, Folder: folder, CreatedAt: time.Now().UTC()})
+	sort.Slice(sub.Watches, func(i, j int) bool {
+		return sub.Watches[i].Path < sub.Watches[j].Path
+	})
+	return s.save()
+}
+
+// Remove stops watching a page or folder
+func (s *WatchStore) Remove(email, wikiPath string, folder bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscribers[strings.ToLower(email)]
+	if !ok {
+		return nil
+	}
+	for i, w := range sub.Watches {
+		if w.Path == wikiPath && w.Folder == folder {
+			sub.Watches = append(sub.Watches[:i], sub.Watches[i+1:]...)
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// SetDigest changes how the user's notifications are delivered
+func (s *WatchStore) SetDigest(email, name, digest string) error {
+	if !ValidDigest(digest) {
+		return fmt.Errorf("unknown digest mode %q", digest)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriber(email, name).Digest = digest
+	return s.save()
+}
+
+// Watching reports whether the user watches exactly this page or folder
+func (s *WatchStore) Watching(email, wikiPath string, folder bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscribers[strings.ToLower(email)]
+	if !ok {
+		return false
+	}
+	for _, w := range sub.Watches {
+		if w.Path == wikiPath && w.Folder == folder {
+			return true
+		}
+	}
+	return false
+}
+
+// Watchers returns everyone with a watch matching the changed path. A change
+// to a whole folder also concerns the watches inside it.
+func (s *WatchStore) Watchers(changedPath string, folder bool) []Subscriber {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Subscriber
+	for _, sub := range s.subscribers {
+		for _, w := range sub.Watches {
+			if w.Matches(changedPath) || (folder && strings.HasPrefix(w.Path, changedPath+"/")) {
+				result = append(result, Subscriber{Email: sub.Email, Name: sub.Name, Digest: sub.Digest})
+				break
+			}
+		}
+	}
+	return result
+}
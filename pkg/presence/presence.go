@@ -0,0 +1,208 @@
+package presence
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Editor is someone who has a page open in the editor
+type Editor struct {
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	Since    time.Time `json:"since"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Store tracks who is editing which page. Editors that stop sending
+// heartbeats are forgotten once the store's ttl passes.
+type Store interface {
+	// Touch records a heartbeat from an editor of a page
+	Touch(path, email, name string) error
+	// Leave forgets an editor straight away, e.g. when the editor is closed
+	Leave(path, email string) error
+	// List returns the current editors of a page, longest-editing first
+	List(path string) ([]Editor, error)
+}
+
+// sortEditors orders editors by when they started editing
+func sortEditors(editors []Editor) {
+	sort.Slice(editors, func(i, j int) bool {
+		return editors[i].Since.Before(editors[j].Since)
+	})
+}
+
+// MemoryStore keeps presence in process memory
+type MemoryStore struct {
+	mu    sync.Mutex
+	pages map[string]map[string]Editor
+	ttl   time.Duration
+}
+
+// NewMemoryStore creates an in-memory presence store
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	log.Printf("Tracking edit presence in memory")
+	return &MemoryStore{pages: make(map[string]map[string]Editor), ttl: ttl}
+}
+
+// prune drops stale editors of a page. The caller must hold s.mu.
+func (s *MemoryStore) prune(path string, now time.Time) map[string]Editor {
+	editors := s.pages[path]
+	for email, editor := range editors {
+		if now.Sub(editor.LastSeen) > s.ttl {
+			delete(editors, email)
+		}
+	}
+	if len(editors) == 0 {
+		delete(s.pages, path)
+		return nil
+	}
+	return editors
+}
+
+// Touch records a heartbeat from an editor of a page
+func (s *MemoryStore) Touch(path, email, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	editors := s.prune(path, now)
+	if editors == nil {
+		editors = make(map[string]Editor)
+		s.pages[path] = editors
+	}
+	key := strings.ToLower(email)
+	editor, ok := editors[key]
+	if !ok {
+		editor = Editor{Email: email, Since: now}
+	}
+	editor.Name = name
+	editor.LastSeen = now
+	editors[key] = editor
+	return nil
+}
+
+// Leave forgets an editor of a page
+func (s *MemoryStore) Leave(path, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if editors := s.pages[path]; editors != nil {
+		delete(editors, strings.ToLower(email))
+		if len(editors) == 0 {
+			delete(s.pages, path)
+		}
+	}
+	return nil
+}
+
+// List returns the current editors of a page
+func (s *MemoryStore) List(path string) ([]Editor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []Editor
+	for _, editor := range s.prune(path, time.Now().UTC()) {
+		list = append(list, editor)
+	}
+	sortEditors(list)
+	return list, nil
+}
+
+// RedisStore keeps presence in Redis so it is shared between instances. Each
+// page is a hash of email to editor; the hash expires with its last editor.
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisStore creates a presence store backed by Redis
+func NewRedisStore(client *redis.Client, ttl time.Duration) (*RedisStore, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	log.Printf("Tracking edit presence in Redis")
+	return &RedisStore{client: client, ttl: ttl}, nil
+}
+
+// redisKey returns the Redis key holding a page's editors
+func redisKey(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return "presence:" + hex.EncodeToString(sum[:16])
+}
+
+// Touch records a heartbeat from an editor of a page
+func (s *RedisStore) Touch(path, email, name string) error {
+	ctx := context.Background()
+	key := redisKey(path)
+	field := strings.ToLower(email)
+	now := time.Now().UTC()
+
+	editor := Editor{Email: email, Since: now}
+	if data, err := s.client.HGet(ctx, key, field).Bytes(); err == nil {
+		var previous Editor
+		if json.Unmarshal(data, &previous) == nil && now.Sub(previous.LastSeen) <= s.ttl {
+			editor.Since = previous.Since
+		}
+	} else if err != redis.Nil {
+		return fmt.Errorf("failed to read presence: %v", err)
+	}
+	editor.Name = name
+	editor.LastSeen = now
+
+	data, err := json.Marshal(editor)
+	if err != nil {
+		return fmt.Errorf("failed to encode presence: %v", err)
+	}
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, field, data)
+	pipe.Expire(ctx, key, s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save presence: %v", err)
+	}
+	return nil
+}
+
+// Leave forgets an editor of a page
+func (s *RedisStore) Leave(path, email string) error {
+	if err := s.client.HDel(context.Background(), redisKey(path), strings.ToLower(email)).Err(); err != nil {
+		return fmt.Errorf("failed to clear presence: %v", err)
+	}
+	return nil
+}
+
+// List returns the current editors of a page, removing stale ones
+func (s *RedisStore) List(path string) ([]Editor, error) {
+	ctx := context.Background()
+	key := redisKey(path)
+	fields, err := s.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presence: %v", err)
+	}
+
+	now := time.Now().UTC()
+	var list []Editor
+	var stale []string
+	for field, data := range fields {
+		var editor Editor
+		if err := json.Unmarshal([]byte(data), &editor); err != nil || now.Sub(editor.LastSeen) > s.ttl {
+			stale = append(stale, field)
+			continue
+		}
+		list = append(list, editor)
+	}
+	if len(stale) > 0 {
+		s.client.HDel(ctx, key, stale...)
+	}
+	sortEditors(list)
+	return list, nil
+}
@@ -0,0 +1,253 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Limit is a token bucket holding up to Burst requests, refilled at
+// PerMinute requests a minute. A PerMinute of zero or less means no limit.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+// perSecond returns the refill rate in requests per second
+func (l Limit) perSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// capacity returns the bucket size, at least one request
+func (l Limit) capacity() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	// Remaining is the number of requests left in the bucket
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, when refused
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets
+type Store interface {
+	// Take spends one request from the key's bucket if it has one left
+	Take(key string, limit Limit) (Result, error)
+}
+
+// bucket is a token bucket kept in memory, with the limit it was last taken under
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// MemoryStore keeps token buckets in process memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an in-memory token bucket store
+func NewMemoryStore() *MemoryStore {
+	log.Printf("Keeping rate limits in memory")
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Take spends one request from the key's bucket if it has one left
+func (s *MemoryStore) Take(key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.calls++
+	if s.calls%1000 == 0 {
+		s.prune(now)
+	}
+
+	capacity := limit.capacity()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*limit.perSecond())
+	b.last = now
+	b.limit = limit
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / limit.perSecond()
+		return Result{RetryAfter: time.Duration(wait * float64(time.Second))}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// prune forgets buckets idle long enough to have refilled completely under
+// their own limit, which behave the same as new ones. The caller must hold s.mu.
+func (s *MemoryStore) prune(now time.Time) {
+	for key, b := range s.buckets {
+		idle := time.Duration(b.limit.capacity() / b.limit.perSecond() * float64(time.Second))
+		if now.Sub(b.last) > idle {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// takeScript refills and spends from a bucket atomically, using the Redis
+// server's clock so every wiki instance agrees on the time
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local last = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - last) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {allowed, math.floor(tokens), wait}
+`)
+
+// RedisStore keeps token buckets in Redis so limits hold across instances
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a Redis-backed token bucket store
+func NewRedisStore(client *redis.Client) (*RedisStore, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	log.Printf("Keeping rate limits in Redis")
+	return &RedisStore{client: client}, nil
+}
+
+// redisKey returns the Redis key of a bucket, hashed to keep emails out of key names
+func redisKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "ratelimit:" + hex.EncodeToString(sum[:16])
+}
+
+// Take spends one request from the key's bucket if it has one left
+func (s *RedisStore) Take(key string, limit Limit) (Result, error) {
+	// The script works in milliseconds
+	rate := limit.perSecond() / 1000
+	values, err := takeScript.Run(context.Background(), s.client, []string{redisKey(key)}, rate, limit.capacity()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+	return Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+// clientKey identifies who is making the request: the signed-in or token
+// user, or the client IP for anonymous requests
+func clientKey(c *gin.Context) string {
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(auth.User); ok && !user.IsGuest() {
+			return "user:" + strings.ToLower(user.Email)
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Middleware limits each user, or each client IP for anonymous requests, to
+// the group's rate. It must run after the authentication middleware for
+// requests to be counted per user. Requests over the limit get a 429 with a
+// Retry-After header. When the store fails, requests are let through.
+func Middleware(store Store, group string, limit Limit) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		key := group + ":" + clientKey(c)
+		result, err := store.Take(key, limit)
+		if err != nil {
+			log.Printf("Warning: rate limiting %s failed, allowing request: %v", key, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(int(limit.capacity())))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		if result.Allowed {
+			c.Next()
+			return
+		}
+
+		retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		log.Printf("Rate limited %s on %s %s, retry after %ds", key, c.Request.Method, c.Request.URL.Path, retryAfter)
+		reject(c, retryAfter)
+	}
+}
+
+// reject aborts with a 429 in the format the route's clients expect
+func reject(c *gin.Context, retryAfter int) {
+	message := fmt.Sprintf("Too many requests, try again in %d seconds", retryAfter)
+	if strings.HasPrefix(c.Request.URL.Path, "/api/v1/") {
+		auth.AbortAPI(c, http.StatusTooManyRequests, "rate_limited", message)
+		return
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") || c.Request.Method != http.MethodGet {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"success": false,
+			"error":   message,
+		})
+		return
+	}
+	c.HTML(http.StatusTooManyRequests, "error.html", gin.H{
+		"error": message,
+	})
+	c.Abort()
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestStore returns a memory store whose clock only moves when told to
+func newTestStore() (*MemoryStore, *time.Time) {
+	now := time.Now()
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestMemoryStoreBurst(t *testing.T) {
+	s, _ := newTestStore()
+	limit := Limit{PerMinute: 60, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		result, _ := s.Take("alice", limit)
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", 3-i, result, i)
+		}
+	}
+	result, _ := s.Take("alice", limit)
+	if result.Allowed {
+		t.Fatalf("request over the burst was allowed")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s at one request a second", result.RetryAfter)
+	}
+
+	if result, _ := s.Take("bob", limit); !result.Allowed {
+		t.Errorf("bob limited by alice's requests")
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	s, now := newTestStore()
+	limit := Limit{PerMinute: 30, Burst: 2}
+
+	s.Take("alice", limit)
+	s.Take("alice", limit)
+	if result, _ := s.Take("alice", limit); result.Allowed {
+		t.Fatalf("empty bucket allowed a request")
+	}
+
+	*now = now.Add(time.Second)
+	result, _ := s.Take("alice", limit)
+	if result.Allowed || result.RetryAfter != time.Second {
+		t.Fatalf("after half a token = %+v, want refused with 1s to wait", result)
+	}
+
+	*now = now.Add(time.Second)
+	if result, _ := s.Take("alice", limit); !result.Allowed {
+		t.Fatalf("refilled token was refused")
+	}
+
+	// A long pause refills no more than the burst
+	*now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if result, _ := s.Take("alice", limit); !result.Allowed {
+			t.Fatalf("request %d after a pause was refused", i+1)
+		}
+	}
+	if result, _ := s.Take("alice", limit); result.Allowed {
+		t.Errorf("pause refilled more than the burst")
+	}
+}
+
+func TestMemoryStorePrunesByBucketLimit(t *testing.T) {
+	s, now := newTestStore()
+	slow := Limit{PerMinute: 1, Burst: 5}
+	fast := Limit{PerMinute: 600, Burst: 5}
+
+	s.Take("slow", slow)
+	*now = now.Add(time.Minute)
+	// Pruning runs during a fast group's call, whose buckets refill in half a second
+	s.calls = 999
+	s.Take("fast", fast)
+
+	if _, ok := s.buckets["slow"]; !ok {
+		t.Errorf("slow bucket pruned before it refilled")
+	}
+}
+
+func TestMiddlewareRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, _ := newTestStore()
+	router := gin.New()
+	router.GET("/api/pages", Middleware(s, "api", Limit{PerMinute: 20, Burst: 1}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/pages", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("first request = %d with %q remaining, want 200 with 0", w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+	w := get()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("Retry-After = %q, want 3 at one request every 3 seconds", got)
+	}
+}
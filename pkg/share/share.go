@@ -0,0 +1,281 @@
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/access"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxPasswordBytes is the longest password bcrypt can hash
+const maxPasswordBytes = 72
+
+// Link gives read-only access to one page until it expires or is revoked.
+// The URL carries the link's ID and expiry signed with the server secret, so
+// it can't be extended or guessed from the ID shown to admins.
+type Link struct {
+	ID           string     `json:"id"`
+	Path         string     `json:"path"`
+	CreatedBy    string     `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    string     `json:"revoked_by,omitempty"`
+}
+
+// HasPassword reports whether visitors must enter a password
+func (l *Link) HasPassword() bool {
+	return l.PasswordHash != ""
+}
+
+// Expired reports whether the link is past its expiry time
+func (l *Link) Expired() bool {
+	return !time.Now().Before(l.ExpiresAt)
+}
+
+// Active reports whether the link can still be opened
+func (l *Link) Active() bool {
+	return l.RevokedAt == nil && !l.Expired()
+}
+
+// Store persists share links in a JSON file. Revoked links are kept until
+// they would have expired, which makes the file the revocation list.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	secret []byte
+	links  []*Link
+}
+
+// NewStore loads the share link file, creating an empty store if it doesn't exist
+func NewStore(path string, secret []byte) (*Store, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("share link secret is required")
+	}
+	s := &Store{path: path, secret: secret}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("No share link file at %s, starting with no share links", path)
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read share link file: %v", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.links); err != nil {
+			return nil, fmt.Errorf("failed to parse share link file: %v", err)
+		}
+	}
+	log.Printf("Loaded %d share links", len(s.links))
+	return s, nil
+}
+
+// save drops expired links and writes the rest to disk. The caller must
+// hold the lock.
+func (s *Store) save() error {
+	var kept []*Link
+	for _, link := range s.links {
+		if !link.Expired() {
+			kept = append(kept, link)
+		}
+	}
+	s.links = kept
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create share link directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(s.links, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal share links: %v", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write share link file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace share link file: %v", err)
+	}
+	return nil
+}
+
+// sign returns the signature of a link's ID, expiry and page
+func (s *Store) sign(id string, expires int64, pagePath string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%d\n%s", id, expires, pagePath)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Token returns the signed token that goes in the link's URL
+func (s *Store) Token(link *Link) string {
+	expires := link.ExpiresAt.Unix()
+	return fmt.Sprintf("%s.%d.%s", link.ID, expires, s.sign(link.ID, expires, link.Path))
+}
+
+// Create shares a page for the given duration, optionally behind a password,
+// and returns the new link's token
+func (s *Store) Create(pagePath, createdBy string, ttl time.Duration, password string) (string, *Link, error) {
+	pagePath, err := access.CleanPath(strings.TrimSuffix(pagePath, "/"))
+	if err != nil {
+		return "", nil, err
+	}
+	if pagePath == "" {
+		return "", nil, fmt.Errorf("page path is required")
+	}
+	if ttl <= 0 {
+		return "", nil, fmt.Errorf("expiry must be in the future")
+	}
+	if len(password) > maxPasswordBytes {
+		return "", nil, fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate share link id: %v", err)
+	}
+
+	now := time.Now()
+	link := &Link{
+		ID:        hex.EncodeToString(id),
+		Path:      pagePath,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to hash password: %v", err)
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.links = append(s.links, link)
+	if err := s.save(); err != nil {
+		s.links = s.links[:len(s.links)-1]
+		return "", nil, err
+	}
+
+	log.Printf("Created share link %s for %s by %s, expires %s", link.ID, pagePath, createdBy, link.ExpiresAt.Format(time.RFC3339))
+	return s.Token(link), link, nil
+}
+
+// Resolve returns the link a token opens, checking its signature, expiry
+// and the revocation list
+func (s *Store) Resolve(token string) (*Link, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed share token")
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed share token")
+	}
+	if time.Now().Unix() >= expires {
+		return nil, fmt.Errorf("share link expired")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, link := range s.links {
+		if link.ID != parts[0] {
+			continue
+		}
+		if link.ExpiresAt.Unix() != expires || !hmac.Equal([]byte(parts[2]), []byte(s.sign(link.ID, expires, link.Path))) {
+			return nil, fmt.Errorf("invalid share link signature")
+		}
+		if link.RevokedAt != nil {
+			return nil, fmt.Errorf("share link revoked")
+		}
+		result := *link
+		return &result, nil
+	}
+	return nil, fmt.Errorf("share link not found")
+}
+
+// CheckPassword reports whether the password opens the link
+func (s *Store) CheckPassword(link *Link, password string) bool {
+	if !link.HasPassword() {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) == nil
+}
+
+// Get returns a link by ID
+func (s *Store) Get(id string) (*Link, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, link := range s.links {
+		if link.ID == id {
+			result := *link
+			return &result, true
+		}
+	}
+	return nil, false
+}
+
+// Revoke stops a link from opening, recording who revoked it
+func (s *Store) Revoke(id, revokedBy string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, link := range s.links {
+		if link.ID != id {
+			continue
+		}
+		if link.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		link.RevokedAt = &now
+		link.RevokedBy = revokedBy
+		if err := s.save(); err != nil {
+			link.RevokedAt = nil
+			link.RevokedBy = ""
+			return err
+		}
+		log.Printf("Revoked share link %s for %s by %s", link.ID, link.Path, revokedBy)
+		return nil
+	}
+	return fmt.Errorf("share link not found")
+}
+
+// Active returns the links that can still be opened, soonest to expire first
+func (s *Store) Active() []Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Link
+	for _, link := range s.links {
+		if link.Active() {
+			result = append(result, *link)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ExpiresAt.Before(result[j].ExpiresAt)
+	})
+	return result
+}
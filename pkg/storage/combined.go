@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"strings"
@@ -13,6 +14,9 @@ import (
 type CombinedStorage struct {
 	local  types.Storage
 	github types.Storage
+	onSync []func(changes []types.SyncChange)
+	// sidecars are the suffixes of the page sidecars Sync copies
+	sidecars []string
 }
 
 // NewCombinedStorage creates a new combined storage instance
@@ -33,10 +37,38 @@ func NewCombinedStorage(cfg *config.Config) (*CombinedStorage, error) {
 	}, nil
 }
 
+// WithCommit returns a view of the storage whose GitHub commits are
+// attributed to the given author
+func (s *CombinedStorage) WithCommit(info types.CommitInfo) types.Storage {
+	committer, ok := s.github.(types.Committer)
+	if !ok {
+		return s
+	}
+	return &CombinedStorage{
+		local:    s.local,
+		github:   committer.WithCommit(info),
+		onSync:   s.onSync,
+		sidecars: s.sidecars,
+	}
+}
+
+// OnSync registers a function that is called after each successful Sync with
+// the pages it pulled in from GitHub
+func (s *CombinedStorage) OnSync(fn func(changes []types.SyncChange)) {
+	s.onSync = append(s.onSync, fn)
+}
+
+// SyncSidecar has each Sync copy the page sidecars with the suffix from
+// GitHub, so that reading them never costs an API call
+func (s *CombinedStorage) SyncSidecar(suffix string) {
+	s.sidecars = append(s.sidecars, suffix)
+}
+
 // Sync synchronizes data between local and GitHub storage
 func (s *CombinedStorage) Sync() error {
 	// First pull from GitHub to get latest changes
-	if err := s.pullFromGitHub(); err != nil {
+	changes, err := s.pullFromGitHub()
+	if err != nil {
 		return fmt.Errorf("failed to pull from GitHub: %v", err)
 	}
 
@@ -45,37 +77,120 @@ func (s *CombinedStorage) Sync() error {
 		return fmt.Errorf("failed to push to GitHub: %v", err)
 	}
 
+	if len(changes) > 0 {
+		log.Printf("Sync pulled %d changed pages from GitHub", len(changes))
+	}
+	for _, fn := range s.onSync {
+		fn(changes)
+	}
 	return nil
 }
 
-// pullFromGitHub pulls changes from GitHub to local storage
-func (s *CombinedStorage) pullFromGitHub() error {
+// pullFromGitHub pulls changes from GitHub to local storage and returns the
+// pages it created or changed
+func (s *CombinedStorage) pullFromGitHub() ([]types.SyncChange, error) {
 	// Sync folders first
 	folders, err := s.github.ListFolders()
 	if err != nil {
-		return fmt.Errorf("failed to list folders from GitHub: %v", err)
+		return nil, fmt.Errorf("failed to list folders from GitHub: %v", err)
 	}
 	for _, folder := range folders {
 		if err := s.local.CreateFolder(folder); err != nil {
 			log.Printf("Warning: Failed to create local folder %s: %v", folder, err)
+			continue
 		}
+		s.pullFolderMeta(folder)
 	}
 
 	// Get all pages from GitHub
 	pages, err := s.github.ListPages()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// For each page, get its content and save locally
+	// For each page, get its content and save locally, noting what changed
+	var changes []types.SyncChange
+	hadLocal := false
 	for _, page := range pages {
+		change := types.SyncChange{
+			Path:  strings.TrimSuffix(page.Path, ".txt"),
+			After: page.Content,
+		}
+		changed := true
+		if existing, err := s.local.GetPage(page.Path); err == nil {
+			hadLocal = true
+			changed = existing.Content != page.Content
+			change.Before = &existing.Content
+		}
+
 		pagePtr := &page
 		if err := s.local.CreatePage(pagePtr); err != nil {
-			return err
+			return nil, err
 		}
+		if changed {
+			changes = append(changes, change)
+		}
+	}
+	for _, suffix := range s.sidecars {
+		s.pullSidecars(suffix)
 	}
 
-	return nil
+	// A first sync into an empty data directory is a copy, not a change
+	if !hadLocal {
+		return nil, nil
+	}
+	return changes, nil
+}
+
+// pullFolderMeta copies a folder's .folder metadata from GitHub, so settings
+// such as its default template are read locally
+func (s *CombinedStorage) pullFolderMeta(folder string) {
+	remote, remoteOK := s.github.(types.FolderMetaReader)
+	local, localOK := s.local.(types.FolderMetaWriter)
+	if !remoteOK || !localOK {
+		return
+	}
+	content, err := remote.ReadFolderMeta(folder)
+	if err != nil {
+		return
+	}
+	if reader, ok := s.local.(types.FolderMetaReader); ok {
+		if existing, err := reader.ReadFolderMeta(folder); err == nil && bytes.Equal(existing, content) {
+			return
+		}
+	}
+	if err := local.WriteFolderMeta(folder, content); err != nil {
+		log.Printf("Warning: Failed to copy metadata of folder %s: %v", folder, err)
+	}
+}
+
+// pullSidecars copies the page sidecars with the suffix from GitHub, such as
+// comments added on another instance
+func (s *CombinedStorage) pullSidecars(suffix string) {
+	lister, listerOK := s.github.(types.SidecarLister)
+	remote, remoteOK := s.github.(types.SidecarStore)
+	local, localOK := s.local.(types.SidecarStore)
+	if !listerOK || !remoteOK || !localOK {
+		return
+	}
+	pagePaths, err := lister.ListSidecars(suffix)
+	if err != nil {
+		log.Printf("Warning: Failed to list %s files on GitHub: %v", suffix, err)
+		return
+	}
+	for _, pagePath := range pagePaths {
+		content, err := remote.ReadSidecar(pagePath, suffix)
+		if err != nil {
+			log.Printf("Warning: Failed to read %s%s from GitHub: %v", pagePath, suffix, err)
+			continue
+		}
+		if existing, err := local.ReadSidecar(pagePath, suffix); err == nil && bytes.Equal(existing, content) {
+			continue
+		}
+		if err := local.WriteSidecar(pagePath, suffix, content); err != nil {
+			log.Printf("Warning: Failed to copy %s%s: %v", pagePath, suffix, err)
+		}
+	}
 }
 
 // pushToGitHub pushes local changes to GitHub
@@ -205,6 +320,84 @@ func (s *CombinedStorage) DeleteFolder(path string) error {
 	return s.github.DeleteFolder(path)
 }
 
+// ReadFolderMeta reads folder metadata from local storage. Sync copies the
+// .folder files from GitHub, so reads never cost an API call.
+func (s *CombinedStorage) ReadFolderMeta(path string) ([]byte, error) {
+	reader, ok := s.local.(types.FolderMetaReader)
+	if !ok {
+		return nil, fmt.Errorf("folder metadata not supported")
+	}
+	return reader.ReadFolderMeta(path)
+}
+
+// WriteFolderMeta writes folder metadata to both local and GitHub storage
+func (s *CombinedStorage) WriteFolderMeta(path string, content []byte) error {
+	local, localOK := s.local.(types.FolderMetaWriter)
+	remote, remoteOK := s.github.(types.FolderMetaWriter)
+	if !localOK || !remoteOK {
+		return fmt.Errorf("folder metadata not supported")
+	}
+
+	// Write locally first
+	if err := local.WriteFolderMeta(path, content); err != nil {
+		return err
+	}
+
+	// Then write to GitHub
+	return remote.WriteFolderMeta(path, content)
+}
+
+// ReadSidecar reads a page's sidecar from local storage. Sync copies the
+// sidecars registered with SyncSidecar from GitHub, so reads never cost an
+// API call.
+func (s *CombinedStorage) ReadSidecar(pagePath, suffix string) ([]byte, error) {
+	local, ok := s.local.(types.SidecarStore)
+	if !ok {
+		return nil, fmt.Errorf("sidecar files not supported")
+	}
+	return local.ReadSidecar(pagePath, suffix)
+}
+
+// WriteSidecar writes a page's sidecar to both local and GitHub storage
+func (s *CombinedStorage) WriteSidecar(pagePath, suffix string, data []byte) error {
+	local, localOK := s.local.(types.SidecarStore)
+	remote, remoteOK := s.github.(types.SidecarStore)
+	if !localOK || !remoteOK {
+		return fmt.Errorf("sidecar files not supported")
+	}
+
+	// Write locally first
+	if err := local.WriteSidecar(pagePath, suffix, data); err != nil {
+		return err
+	}
+
+	// Then write to GitHub
+	return remote.WriteSidecar(pagePath, suffix, data)
+}
+
+// DeleteSidecar removes a page's sidecar from both local and GitHub storage
+func (s *CombinedStorage) DeleteSidecar(pagePath, suffix string) error {
+	local, localOK := s.local.(types.SidecarStore)
+	remote, remoteOK := s.github.(types.SidecarStore)
+	if !localOK || !remoteOK {
+		return fmt.Errorf("sidecar files not supported")
+	}
+
+	if err := local.DeleteSidecar(pagePath, suffix); err != nil {
+		return err
+	}
+	return remote.DeleteSidecar(pagePath, suffix)
+}
+
+// RecentChanges lists changes from the GitHub commit history
+func (s *CombinedStorage) RecentChanges(folder string, limit int) ([]types.Change, error) {
+	lister, ok := s.github.(types.ChangeLister)
+	if !ok {
+		return nil, fmt.Errorf("change history not supported")
+	}
+	return lister.RecentChanges(folder, limit)
+}
+
 // ListFolders lists all folders from local storage
 func (s *CombinedStorage) ListFolders() ([]string, error) {
 	return s.local.ListFolders()
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"log"
 	"path/filepath"
 	"strings"
@@ -21,6 +22,7 @@ type GitHubStorage struct {
 	repository string
 	branch     string
 	ctx        context.Context
+	commit     types.CommitInfo
 }
 
 // NewGitHubStorage creates a new GitHub storage instance
@@ -90,6 +92,37 @@ func NewGitHubStorage(config *config.Config) (*GitHubStorage, error) {
 	}, nil
 }
 
+// WithCommit returns a copy of the storage that attributes its commits to the
+// given author and uses the given message when one is provided
+func (g *GitHubStorage) WithCommit(info types.CommitInfo) types.Storage {
+	attributed := *g
+	attributed.commit = info
+	return &attributed
+}
+
+// commitMessage returns the user supplied commit message, or the default one
+func (g *GitHubStorage) commitMessage(defaultMessage string) *string {
+	if message := strings.TrimSpace(g.commit.Message); message != "" {
+		return github.String(message)
+	}
+	return github.String(defaultMessage)
+}
+
+// commitAuthor returns the commit author, or nil to let GitHub use the token owner
+func (g *GitHubStorage) commitAuthor() *github.CommitAuthor {
+	if g.commit.AuthorEmail == "" {
+		return nil
+	}
+	name := g.commit.AuthorName
+	if name == "" {
+		name = g.commit.AuthorEmail
+	}
+	return &github.CommitAuthor{
+		Name:  github.String(name),
+		Email: github.String(g.commit.AuthorEmail),
+	}
+}
+
 // ListPages retrieves all pages from the GitHub repository recursively
 func (g *GitHubStorage) ListPages() ([]types.Page, error) {
 	var pages []types.Page
@@ -218,7 +251,8 @@ func (g *GitHubStorage) CreatePage(page *types.Page) error {
 	}
 
 	opts := &github.RepositoryContentFileOptions{
-		Message: github.String(fmt.Sprintf("Create page: %s", page.Path)),
+		Message: g.commitMessage(fmt.Sprintf("Create page: %s", page.Path)),
+		Author:  g.commitAuthor(),
 		Content: page.Body,
 		Branch:  &g.branch,
 	}
@@ -293,7 +327,8 @@ func (g *GitHubStorage) UpdatePage(page *types.Page) error {
 
 	// File exists, update it with the SHA
 	opts := &github.RepositoryContentFileOptions{
-		Message: github.String(fmt.Sprintf("Update page: %s", page.Path)),
+		Message: g.commitMessage(fmt.Sprintf("Update page: %s", page.Path)),
+		Author:  g.commitAuthor(),
 		Content: page.Body,
 		SHA:     fileContent.SHA,
 		Branch:  github.String(g.branch),
@@ -351,7 +386,8 @@ func (g *GitHubStorage) DeletePage(path string) error {
 
 		// File exists, delete it
 		opts := &github.RepositoryContentFileOptions{
-			Message: github.String(fmt.Sprintf("Delete .folder file: %s", path)),
+			Message: g.commitMessage(fmt.Sprintf("Delete .folder file: %s", path)),
+			Author:  g.commitAuthor(),
 			SHA:     fileContent.SHA,
 			Branch:  github.String(g.branch),
 		}
@@ -422,7 +458,8 @@ func (g *GitHubStorage) DeletePage(path string) error {
 
 	// File exists, delete it
 	opts := &github.RepositoryContentFileOptions{
-		Message: github.String(fmt.Sprintf("Delete page: %s", path)),
+		Message: g.commitMessage(fmt.Sprintf("Delete page: %s", path)),
+		Author:  g.commitAuthor(),
 		SHA:     fileContent.SHA,
 		Branch:  github.String(g.branch),
 	}
@@ -531,7 +568,8 @@ func (g *GitHubStorage) CreateFolder(path string) error {
 				// File doesn't exist, create it
 				content := []byte("This file marks the folder for the wiki system. Please do not delete.")
 				opts := &github.RepositoryContentFileOptions{
-					Message: github.String(fmt.Sprintf("Create folder: %s", currentPath)),
+					Message: g.commitMessage(fmt.Sprintf("Create folder: %s", currentPath)),
+					Author:  g.commitAuthor(),
 					Content: content,
 					Branch:  &g.branch,
 				}
@@ -583,6 +621,13 @@ func (g *GitHubStorage) DeleteFolder(path string) error {
 
 	// Delete all files in the folder
 	for _, content := range contents {
+		// Sidecar files such as comments aren't pages, delete them as they are
+		if content.GetType() == "file" && !strings.HasSuffix(content.GetName(), ".txt") && content.GetName() != ".folder" {
+			if err := g.deleteFile(content.GetPath()); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := g.DeletePage(content.GetPath()); err != nil {
 			return fmt.Errorf("failed to delete file %s: %v", content.GetPath(), err)
 		}
@@ -594,7 +639,8 @@ func (g *GitHubStorage) DeleteFolder(path string) error {
 	if err == nil && fileContent != nil {
 		// .folder file exists, delete it
 		opts := &github.RepositoryContentFileOptions{
-			Message: github.String(fmt.Sprintf("Delete folder marker: %s", path)),
+			Message: g.commitMessage(fmt.Sprintf("Delete folder marker: %s", path)),
+			Author:  g.commitAuthor(),
 			SHA:     fileContent.SHA,
 			Branch:  github.String(g.branch),
 		}
@@ -609,6 +655,262 @@ func (g *GitHubStorage) DeleteFolder(path string) error {
 	return nil
 }
 
+// ReadFolderMeta reads the .folder marker file of a folder
+func (g *GitHubStorage) ReadFolderMeta(path string) ([]byte, error) {
+	fileContent, _, _, err := g.client.Repositories.GetContents(
+		g.ctx,
+		g.owner,
+		g.repository,
+		path+"/.folder",
+		&github.RepositoryContentGetOptions{Ref: g.branch},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read folder metadata: %v", err)
+	}
+	if fileContent == nil {
+		return nil, fmt.Errorf("folder metadata not found: %s", path)
+	}
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode folder metadata: %v", err)
+	}
+	return []byte(content), nil
+}
+
+// WriteFolderMeta replaces the .folder marker file of a folder
+func (g *GitHubStorage) WriteFolderMeta(path string, content []byte) error {
+	folderPath := path + "/.folder"
+	fileContent, err := g.getFile(folderPath)
+	if err != nil {
+		return err
+	}
+
+	opts := &github.RepositoryContentFileOptions{
+		Message: g.commitMessage(fmt.Sprintf("Update folder marker: %s", path)),
+		Author:  g.commitAuthor(),
+		Content: content,
+		Branch:  github.String(g.branch),
+	}
+	if fileContent == nil {
+		_, _, err = g.client.Repositories.CreateFile(g.ctx, g.owner, g.repository, folderPath, opts)
+	} else {
+		opts.SHA = fileContent.SHA
+		_, _, err = g.client.Repositories.UpdateFile(g.ctx, g.owner, g.repository, folderPath, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write folder metadata: %v", err)
+	}
+	return nil
+}
+
+// getFile returns a file's metadata and content, or nil when it doesn't exist
+func (g *GitHubStorage) getFile(path string) (*github.RepositoryContent, error) {
+	fileContent, _, resp, err := g.client.Repositories.GetContents(
+		g.ctx,
+		g.owner,
+		g.repository,
+		path,
+		&github.RepositoryContentGetOptions{Ref: g.branch},
+	)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s: %v", path, err)
+	}
+	return fileContent, nil
+}
+
+// deleteFile deletes a file by its full path, ignoring files that don't exist
+func (g *GitHubStorage) deleteFile(path string) error {
+	fileContent, err := g.getFile(path)
+	if err != nil {
+		return err
+	}
+	if fileContent == nil {
+		return nil
+	}
+
+	opts := &github.RepositoryContentFileOptions{
+		Message: g.commitMessage(fmt.Sprintf("Delete %s", path)),
+		Author:  g.commitAuthor(),
+		SHA:     fileContent.SHA,
+		Branch:  github.String(g.branch),
+	}
+	if _, _, err := g.client.Repositories.DeleteFile(g.ctx, g.owner, g.repository, path, opts); err != nil {
+		return fmt.Errorf("failed to delete %s: %v", path, err)
+	}
+	return nil
+}
+
+// ReadSidecar reads a file kept next to a page
+func (g *GitHubStorage) ReadSidecar(pagePath, suffix string) ([]byte, error) {
+	path := strings.TrimSuffix(pagePath, ".txt") + suffix
+	fileContent, err := g.getFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if fileContent == nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, fs.ErrNotExist)
+	}
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return []byte(content), nil
+}
+
+// WriteSidecar creates or updates a file kept next to a page
+func (g *GitHubStorage) WriteSidecar(pagePath, suffix string, data []byte) error {
+	path := strings.TrimSuffix(pagePath, ".txt") + suffix
+	fileContent, err := g.getFile(path)
+	if err != nil {
+		return err
+	}
+
+	opts := &github.RepositoryContentFileOptions{
+		Message: g.commitMessage(fmt.Sprintf("Update %s", path)),
+		Author:  g.commitAuthor(),
+		Content: data,
+		Branch:  github.String(g.branch),
+	}
+	if fileContent == nil {
+		_, _, err = g.client.Repositories.CreateFile(g.ctx, g.owner, g.repository, path, opts)
+	} else {
+		opts.SHA = fileContent.SHA
+		_, _, err = g.client.Repositories.UpdateFile(g.ctx, g.owner, g.repository, path, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// DeleteSidecar removes a file kept next to a page, if there is one
+func (g *GitHubStorage) DeleteSidecar(pagePath, suffix string) error {
+	return g.deleteFile(strings.TrimSuffix(pagePath, ".txt") + suffix)
+}
+
+// ListSidecars finds every page with a sidecar of the given suffix
+func (g *GitHubStorage) ListSidecars(suffix string) ([]string, error) {
+	var pagePaths []string
+	if err := g.getAllSidecars("", suffix, &pagePaths); err != nil {
+		return nil, err
+	}
+	return pagePaths, nil
+}
+
+// getAllSidecars recursively collects the pages with a sidecar under the given path
+func (g *GitHubStorage) getAllSidecars(path, suffix string, pagePaths *[]string) error {
+	opts := &github.RepositoryContentGetOptions{Ref: g.branch}
+
+	_, contents, _, err := g.client.Repositories.GetContents(g.ctx, g.owner, g.repository, path, opts)
+	if err != nil {
+		return fmt.Errorf("failed to get repository contents at %q: %v", path, err)
+	}
+
+	for _, content := range contents {
+		switch content.GetType() {
+		case "file":
+			if strings.HasSuffix(content.GetName(), suffix) {
+				*pagePaths = append(*pagePaths, strings.TrimSuffix(content.GetPath(), suffix))
+			}
+		case "dir":
+			if err := g.getAllSidecars(content.GetPath(), suffix, pagePaths); err != nil {
+				log.Printf("Warning: failed to recurse into %s: %v", content.GetPath(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecentChanges lists the page and folder changes in the latest commits,
+// newest first. Each commit costs one API call, so callers should cache.
+func (g *GitHubStorage) RecentChanges(folder string, limit int) ([]types.Change, error) {
+	opts := &github.CommitsListOptions{
+		SHA:         g.branch,
+		Path:        folder,
+		ListOptions: github.ListOptions{PerPage: min(limit, 100)},
+	}
+	commits, _, err := g.client.Repositories.ListCommits(g.ctx, g.owner, g.repository, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %v", err)
+	}
+
+	var changes []types.Change
+	for _, listed := range commits {
+		commit, _, err := g.client.Repositories.GetCommit(g.ctx, g.owner, g.repository, listed.GetSHA(), nil)
+		if err != nil {
+			log.Printf("Warning: failed to get commit %s: %v", listed.GetSHA(), err)
+			continue
+		}
+
+		author := commit.GetCommit().GetAuthor()
+		message := commit.GetCommit().GetMessage()
+		if i := strings.Index(message, "\n"); i >= 0 {
+			message = message[:i]
+		}
+		for _, file := range commit.Files {
+			change, ok := commitFileChange(file)
+			if !ok || (folder != "" && change.Path != folder && !strings.HasPrefix(change.Path, folder+"/")) {
+				continue
+			}
+			change.ID = commit.GetSHA() + ":" + file.GetFilename()
+			change.Time = author.GetDate().UTC()
+			change.AuthorName = author.GetName()
+			change.AuthorEmail = author.GetEmail()
+			change.Message = message
+			change.Commit = commit.GetSHA()
+			changes = append(changes, change)
+		}
+		if len(changes) >= limit {
+			break
+		}
+	}
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+// commitFileChange describes a file touched by a commit as a wiki change.
+// Pages are .txt files and folders show up as their .folder markers; other
+// files such as comments are skipped.
+func commitFileChange(file *github.CommitFile) (types.Change, bool) {
+	name := file.GetFilename()
+	var change types.Change
+	switch {
+	case strings.HasSuffix(name, ".txt"):
+		change.Path = strings.TrimSuffix(name, ".txt")
+		change.OldPath = strings.TrimSuffix(file.GetPreviousFilename(), ".txt")
+	case name == ".folder" || strings.HasSuffix(name, "/.folder"):
+		change.Path = strings.TrimSuffix(strings.TrimSuffix(name, ".folder"), "/")
+		change.Folder = true
+		if change.Path == "" {
+			return change, false
+		}
+	default:
+		return change, false
+	}
+
+	switch file.GetStatus() {
+	case "added", "copied":
+		change.Action = types.ChangeCreated
+	case "removed":
+		change.Action = types.ChangeDeleted
+	case "renamed":
+		change.Action = types.ChangeMoved
+	default:
+		if change.Folder {
+			// A folder marker being edited isn't a change to the wiki
+			return change, false
+		}
+		change.Action = types.ChangeUpdated
+	}
+	return change, true
+}
+
 // GetPagesInFolder retrieves all pages from a specific folder
 func (g *GitHubStorage) GetPagesInFolder(folderPath string) ([]types.Page, error) {
 	log.Printf("=== GetPagesInFolder START: %s ===", folderPath)
@@ -24,6 +24,12 @@ func NewLocalStorage(config *config.Config) (*LocalStorage, error) {
 	}, nil
 }
 
+// isHiddenDir reports whether a walked directory should be skipped. Dot
+// directories such as .git hold internal data, not wiki content.
+func isHiddenDir(path string, info os.FileInfo, baseDir string) bool {
+	return info.IsDir() && path != baseDir && strings.HasPrefix(info.Name(), ".")
+}
+
 // ListPages retrieves all pages from the local filesystem
 func (l *LocalStorage) ListPages() ([]types.Page, error) {
 	var pages []types.Page
@@ -31,6 +37,9 @@ func (l *LocalStorage) ListPages() ([]types.Page, error) {
 		if err != nil {
 			return err
 		}
+		if isHiddenDir(path, info, l.baseDir) {
+			return filepath.SkipDir
+		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".txt") {
 			relPath, err := filepath.Rel(l.baseDir, path)
 			if err != nil {
@@ -125,6 +134,9 @@ func (l *LocalStorage) ListFolders() ([]string, error) {
 		if err != nil {
 			return err
 		}
+		if isHiddenDir(path, info, l.baseDir) {
+			return filepath.SkipDir
+		}
 		if info.IsDir() && path != l.baseDir {
 			relPath, err := filepath.Rel(l.baseDir, path)
 			if err != nil {
@@ -158,6 +170,61 @@ func (l *LocalStorage) DeleteFolder(path string) error {
 	return nil
 }
 
+// ReadFolderMeta reads the .folder metadata file of a folder
+func (l *LocalStorage) ReadFolderMeta(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filepath.Join(l.baseDir, path, ".folder"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read folder metadata: %v", err)
+	}
+	return content, nil
+}
+
+// WriteFolderMeta replaces the .folder metadata file of a folder
+func (l *LocalStorage) WriteFolderMeta(path string, content []byte) error {
+	fullPath := filepath.Join(l.baseDir, path)
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return fmt.Errorf("failed to create folder: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(fullPath, ".folder"), content, 0644); err != nil {
+		return fmt.Errorf("failed to write folder metadata: %v", err)
+	}
+	return nil
+}
+
+// sidecarPath returns the file holding a page's sidecar with the given suffix
+func (l *LocalStorage) sidecarPath(pagePath, suffix string) string {
+	return filepath.Join(l.baseDir, strings.TrimSuffix(pagePath, ".txt")+suffix)
+}
+
+// ReadSidecar reads a file kept next to a page
+func (l *LocalStorage) ReadSidecar(pagePath, suffix string) ([]byte, error) {
+	content, err := ioutil.ReadFile(l.sidecarPath(pagePath, suffix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s%s: %w", pagePath, suffix, err)
+	}
+	return content, nil
+}
+
+// WriteSidecar writes a file kept next to a page
+func (l *LocalStorage) WriteSidecar(pagePath, suffix string, data []byte) error {
+	fullPath := l.sidecarPath(pagePath, suffix)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := ioutil.WriteFile(fullPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s%s: %v", pagePath, suffix, err)
+	}
+	return nil
+}
+
+// DeleteSidecar removes a file kept next to a page, if there is one
+func (l *LocalStorage) DeleteSidecar(pagePath, suffix string) error {
+	if err := os.Remove(l.sidecarPath(pagePath, suffix)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s%s: %v", pagePath, suffix, err)
+	}
+	return nil
+}
+
 // GetPagesInFolder retrieves all pages from a specific folder
 func (l *LocalStorage) GetPagesInFolder(folderPath string) ([]types.Page, error) {
 	log.Printf("=== GetPagesInFolder START: %s ===", folderPath)
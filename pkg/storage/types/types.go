@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // Page represents a wiki page
 type Page struct {
 	Title        string
@@ -28,3 +30,91 @@ type Storage interface {
 	// Sync operations
 	Sync() error
 }
+
+// CommitInfo describes who made a change and why, for backends that keep history
+type CommitInfo struct {
+	AuthorName  string
+	AuthorEmail string
+	Message     string
+}
+
+// Committer is implemented by storages that can attribute changes to a user.
+// WithCommit returns a view of the storage whose writes use the given info.
+type Committer interface {
+	WithCommit(info CommitInfo) Storage
+}
+
+// FolderMetaReader is implemented by storages that can read the raw contents
+// of a folder's .folder metadata file
+type FolderMetaReader interface {
+	ReadFolderMeta(path string) ([]byte, error)
+}
+
+// FolderMetaWriter is implemented by storages that can replace the contents
+// of a folder's .folder metadata file
+type FolderMetaWriter interface {
+	WriteFolderMeta(path string, content []byte) error
+}
+
+// SidecarStore is implemented by storages that can keep extra files next to
+// a page, named after the page plus a suffix such as ".comments.json". Reading
+// a sidecar that doesn't exist returns an error wrapping fs.ErrNotExist.
+type SidecarStore interface {
+	ReadSidecar(pagePath, suffix string) ([]byte, error)
+	WriteSidecar(pagePath, suffix string, data []byte) error
+	DeleteSidecar(pagePath, suffix string) error
+}
+
+// SidecarLister is implemented by storages that can find every page with a
+// sidecar of the given suffix. It returns the page paths without ".txt".
+type SidecarLister interface {
+	ListSidecars(suffix string) ([]string, error)
+}
+
+// SidecarSyncer is implemented by storages that keep a local copy of page
+// sidecars. SyncSidecar has every later Sync copy those with the suffix.
+type SidecarSyncer interface {
+	SyncSidecar(suffix string)
+}
+
+// SyncChange is a page that a sync created or changed in local storage
+type SyncChange struct {
+	Path   string
+	Before *string // nil when the page is new
+	After  string
+}
+
+// SyncReporter is implemented by storages that can report the pages a Sync
+// brought in from elsewhere. Every registered function is called after each
+// successful Sync, with no changes when nothing came in.
+type SyncReporter interface {
+	OnSync(fn func(changes []SyncChange))
+}
+
+// Change actions, as they appear in the wiki's history
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeMoved   = "moved"
+	ChangeDeleted = "deleted"
+)
+
+// Change is one entry in the wiki's history of changes
+type Change struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	Path        string    `json:"path"`
+	OldPath     string    `json:"old_path,omitempty"`
+	Folder      bool      `json:"folder,omitempty"`
+	AuthorName  string    `json:"author_name,omitempty"`
+	AuthorEmail string    `json:"author_email,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+}
+
+// ChangeLister is implemented by storages that keep their own history, such
+// as a Git repository. Paths are wiki paths without the .txt extension.
+type ChangeLister interface {
+	RecentChanges(folder string, limit int) ([]Change, error)
+}
@@ -0,0 +1,508 @@
+package trash
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-vuky/golang-my-wiki-v2/pkg/storage/types"
+)
+
+const (
+	// legacyDirName is the trash directory older versions kept inside the
+	// data directory, where its content could be reached as wiki paths
+	legacyDirName = ".trash"
+
+	metaFile   = "item.json"
+	contentDir = "content"
+	folderMeta = ".folder"
+)
+
+// Kinds of trashed items
+const (
+	KindPage   = "page"
+	KindFolder = "folder"
+)
+
+// Item describes something that was deleted
+type Item struct {
+	ID            string    `json:"id"`
+	Kind          string    `json:"kind"`
+	Path          string    `json:"path"`
+	DeletedBy     string    `json:"deleted_by"`
+	DeletedByName string    `json:"deleted_by_name,omitempty"`
+	DeletedAt     time.Time `json:"deleted_at"`
+	Pages         int       `json:"pages"`
+}
+
+// Bin keeps copies of deleted pages and folders in a directory outside the
+// data directory, so trashed content is never served as a wiki page
+type Bin struct {
+	mu      sync.Mutex
+	dataDir string
+	dir     string
+	// sidecars are the suffixes of files kept next to pages, such as
+	// ".comments.json", that are trashed and restored with their page
+	sidecars []string
+}
+
+// NewBin creates the trash directory and moves in the items of a trash left
+// inside the data directory by older versions
+func NewBin(dataDir, dir string, sidecars ...string) (*Bin, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trash directory: %v", err)
+	}
+	if err := migrateLegacy(filepath.Join(dataDir, legacyDirName), dir); err != nil {
+		return nil, err
+	}
+	return &Bin{dataDir: dataDir, dir: dir, sidecars: sidecars}, nil
+}
+
+// migrateLegacy moves the items of an old in-data trash directory to dir
+func migrateLegacy(legacy, dir string) error {
+	entries, err := os.ReadDir(legacy)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read old trash directory: %v", err)
+	}
+	for _, entry := range entries {
+		source := filepath.Join(legacy, entry.Name())
+		target := filepath.Join(dir, entry.Name())
+		if err := os.Rename(source, target); err != nil {
+			// The state directory may be on another device
+			if err := copyTree(source, target); err != nil {
+				return fmt.Errorf("failed to move trash item %s: %v", entry.Name(), err)
+			}
+			if err := os.RemoveAll(source); err != nil {
+				return fmt.Errorf("failed to remove old trash item %s: %v", entry.Name(), err)
+			}
+		}
+	}
+	if err := os.Remove(legacy); err != nil {
+		return fmt.Errorf("failed to remove old trash directory: %v", err)
+	}
+	log.Printf("Moved %d trash items from %s to %s", len(entries), legacy, dir)
+	return nil
+}
+
+// newID returns a sortable, unique item id
+func newID() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(buf), nil
+}
+
+// Put copies a page or folder from the data directory into the trash. It must
+// be called before the content is deleted from storage.
+func (b *Bin) Put(kind, wikiPath, byEmail, byName string) (*Item, error) {
+	wikiPath = strings.Trim(wikiPath, "/")
+	source := filepath.Join(b.dataDir, filepath.FromSlash(wikiPath))
+	if kind == KindPage && !strings.HasSuffix(source, ".txt") {
+		source += ".txt"
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate trash id: %v", err)
+	}
+	item := &Item{
+		ID:            id,
+		Kind:          kind,
+		Path:          strings.TrimSuffix(wikiPath, ".txt"),
+		DeletedBy:     byEmail,
+		DeletedByName: byName,
+		DeletedAt:     time.Now().UTC(),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	itemDir := filepath.Join(b.dir, id)
+	target := filepath.Join(itemDir, contentDir, filepath.Base(source))
+	if err := copyTree(source, target); err != nil {
+		os.RemoveAll(itemDir)
+		return nil, fmt.Errorf("failed to copy %s to trash: %v", wikiPath, err)
+	}
+	if kind == KindPage {
+		if err := b.copySidecars(source, filepath.Dir(target)); err != nil {
+			os.RemoveAll(itemDir)
+			return nil, fmt.Errorf("failed to copy %s to trash: %v", wikiPath, err)
+		}
+	}
+	item.Pages = countPages(target)
+
+	if err := writeMeta(itemDir, item); err != nil {
+		os.RemoveAll(itemDir)
+		return nil, err
+	}
+
+	log.Printf("Moved %s %s to trash as %s", kind, item.Path, id)
+	return item, nil
+}
+
+// Discard removes an item that was put in the trash when the delete it was
+// guarding failed
+func (b *Bin) Discard(id string) {
+	if err := b.Purge(id); err != nil {
+		log.Printf("Warning: failed to discard trash item %s: %v", id, err)
+	}
+}
+
+// List returns all trashed items, most recently deleted first
+func (b *Bin) List() ([]Item, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash: %v", err)
+	}
+
+	var items []Item
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		item, err := readMeta(filepath.Join(b.dir, entry.Name()))
+		if err != nil {
+			log.Printf("Skipping unreadable trash item %s: %v", entry.Name(), err)
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// Get returns a trashed item by id
+func (b *Bin) Get(id string) (*Item, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("invalid trash id")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return readMeta(filepath.Join(b.dir, id))
+}
+
+// Restore recreates a trashed item at its original path through the given
+// storage, so that every backend gets the content back, then removes it from
+// the trash
+func (b *Bin) Restore(id string, store types.Storage) (*Item, error) {
+	item, err := b.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	root := filepath.Join(b.dir, id, contentDir)
+	parent := getParentPath(item.Path)
+
+	switch item.Kind {
+	case KindPage:
+		if _, err := store.GetPage(item.Path); err == nil {
+			return nil, fmt.Errorf("a page already exists at %s", item.Path)
+		}
+		if parent != "" {
+			if err := store.CreateFolder(parent); err != nil {
+				return nil, fmt.Errorf("failed to recreate folder %s: %v", parent, err)
+			}
+		}
+		body, err := os.ReadFile(filepath.Join(root, filepath.Base(item.Path)+".txt"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trashed page: %v", err)
+		}
+		if err := store.CreatePage(pageFor(item.Path, body)); err != nil {
+			return nil, fmt.Errorf("failed to restore page: %v", err)
+		}
+		for _, suffix := range b.sidecars {
+			data, err := os.ReadFile(filepath.Join(root, filepath.Base(item.Path)+suffix))
+			if err != nil {
+				continue
+			}
+			if err := writeSidecar(store, item.Path, suffix, data); err != nil {
+				log.Printf("Warning: restored page %s without its %s file: %v", item.Path, suffix, err)
+			}
+		}
+
+	case KindFolder:
+		folders, err := store.ListFolders()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list folders: %v", err)
+		}
+		for _, folder := range folders {
+			if folder == item.Path {
+				return nil, fmt.Errorf("a folder already exists at %s", item.Path)
+			}
+		}
+
+		source := filepath.Join(root, filepath.Base(item.Path))
+		err = filepath.Walk(source, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(source, p)
+			if err != nil {
+				return err
+			}
+			wikiPath := item.Path
+			if rel != "." {
+				wikiPath = item.Path + "/" + filepath.ToSlash(rel)
+			}
+			if info.IsDir() {
+				return store.CreateFolder(wikiPath)
+			}
+			body, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if info.Name() == folderMeta {
+				if err := writeFolderMeta(store, getParentPath(wikiPath), body); err != nil {
+					log.Printf("Warning: restored folder %s without its metadata: %v", getParentPath(wikiPath), err)
+				}
+				return nil
+			}
+			for _, suffix := range b.sidecars {
+				if strings.HasSuffix(info.Name(), suffix) {
+					pagePath := strings.TrimSuffix(wikiPath, suffix)
+					if err := writeSidecar(store, pagePath, suffix, body); err != nil {
+						log.Printf("Warning: restored page %s without its %s file: %v", pagePath, suffix, err)
+					}
+					return nil
+				}
+			}
+			if !strings.HasSuffix(info.Name(), ".txt") {
+				return nil
+			}
+			return store.CreatePage(pageFor(strings.TrimSuffix(wikiPath, ".txt"), body))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore folder: %v", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown trash item kind %q", item.Kind)
+	}
+
+	if err := os.RemoveAll(filepath.Join(b.dir, id)); err != nil {
+		log.Printf("Warning: restored %s but failed to remove it from trash: %v", item.Path, err)
+	}
+	log.Printf("Restored %s %s from trash", item.Kind, item.Path)
+	return item, nil
+}
+
+// Purge permanently deletes a trashed item
+func (b *Bin) Purge(id string) error {
+	if !validID(id) {
+		return fmt.Errorf("invalid trash id")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	itemDir := filepath.Join(b.dir, id)
+	if _, err := os.Stat(itemDir); err != nil {
+		return fmt.Errorf("trash item not found: %s", id)
+	}
+	if err := os.RemoveAll(itemDir); err != nil {
+		return fmt.Errorf("failed to purge trash item: %v", err)
+	}
+	log.Printf("Purged trash item %s", id)
+	return nil
+}
+
+// Sweep purges items deleted before the cutoff and returns how many were removed
+func (b *Bin) Sweep(cutoff time.Time) int {
+	items, err := b.List()
+	if err != nil {
+		log.Printf("Error listing trash for sweep: %v", err)
+		return 0
+	}
+	purged := 0
+	for _, item := range items {
+		if item.DeletedAt.Before(cutoff) {
+			if err := b.Purge(item.ID); err != nil {
+				log.Printf("Error purging expired trash item %s: %v", item.ID, err)
+				continue
+			}
+			purged++
+		}
+	}
+	return purged
+}
+
+// StartSweeper purges items older than the retention period once an hour.
+// A retention of zero keeps items until they are purged by hand.
+func (b *Bin) StartSweeper(retention time.Duration) {
+	if retention <= 0 {
+		log.Printf("Trash retention disabled; items are kept until purged")
+		return
+	}
+	log.Printf("Trash retention set to %v", retention)
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if n := b.Sweep(time.Now().Add(-retention)); n > 0 {
+				log.Printf("Trash sweep purged %d expired items", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// copySidecars copies the sidecar files of a page into the trash item's
+// content directory
+func (b *Bin) copySidecars(pageFile, targetDir string) error {
+	base := strings.TrimSuffix(pageFile, ".txt")
+	for _, suffix := range b.sidecars {
+		source := base + suffix
+		if _, err := os.Stat(source); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := copyFile(source, filepath.Join(targetDir, filepath.Base(source))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSidecar restores a page's sidecar file through the storage
+func writeSidecar(store types.Storage, pagePath, suffix string, data []byte) error {
+	sidecars, ok := store.(types.SidecarStore)
+	if !ok {
+		return fmt.Errorf("storage does not support sidecar files")
+	}
+	return sidecars.WriteSidecar(pagePath, suffix, data)
+}
+
+// writeFolderMeta restores a folder's .folder metadata through the storage
+func writeFolderMeta(store types.Storage, folderPath string, content []byte) error {
+	writer, ok := store.(types.FolderMetaWriter)
+	if !ok {
+		return fmt.Errorf("storage does not support folder metadata")
+	}
+	return writer.WriteFolderMeta(folderPath, content)
+}
+
+// validID rejects ids that could escape the trash directory
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\.`)
+}
+
+// pageFor builds a page for the given wiki path and content
+func pageFor(wikiPath string, body []byte) *types.Page {
+	return &types.Page{
+		Title:   path.Base(wikiPath),
+		Path:    wikiPath,
+		Body:    body,
+		Content: string(body),
+	}
+}
+
+// getParentPath returns the parent folder of a wiki path
+func getParentPath(wikiPath string) string {
+	if i := strings.LastIndex(wikiPath, "/"); i >= 0 {
+		return wikiPath[:i]
+	}
+	return ""
+}
+
+// writeMeta stores the item metadata next to its content
+func writeMeta(itemDir string, item *Item) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode trash metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(itemDir, metaFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write trash metadata: %v", err)
+	}
+	return nil
+}
+
+// readMeta loads the metadata of a trashed item
+func readMeta(itemDir string) (*Item, error) {
+	data, err := os.ReadFile(filepath.Join(itemDir, metaFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("trash item not found: %s", filepath.Base(itemDir))
+		}
+		return nil, fmt.Errorf("failed to read trash metadata: %v", err)
+	}
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("failed to parse trash metadata: %v", err)
+	}
+	return &item, nil
+}
+
+// copyTree copies a file or directory tree
+func copyTree(source, target string) error {
+	return filepath.Walk(source, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(target, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		return copyFile(p, dest)
+	})
+}
+
+// copyFile copies a single file
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// countPages returns the number of pages in a trashed file or folder
+func countPages(root string) int {
+	count := 0
+	filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(info.Name(), ".txt") {
+			count++
+		}
+		return nil
+	})
+	return count
+}
@@ -0,0 +1,211 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// retryDelays is the wait before each retry; later retries reuse the last delay
+var retryDelays = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	1 * time.Hour,
+}
+
+// retryDelay returns how long to wait after the given failed attempt
+func retryDelay(attempt int) time.Duration {
+	if attempt-1 < len(retryDelays) {
+		return retryDelays[attempt-1]
+	}
+	return retryDelays[len(retryDelays)-1]
+}
+
+// Sign returns the signature sent in the X-Wiki-Signature header: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the hook's secret. Signing
+// the timestamp lets receivers reject replayed deliveries.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Emit delivers the event to every active hook that wants it. Delivery
+// happens in the background, so Emit never blocks the request that caused
+// the event. It is safe to call on a nil manager.
+func (m *Manager) Emit(e Event) {
+	if m == nil {
+		return
+	}
+	if e.ID == "" {
+		id, err := randomID(12)
+		if err != nil {
+			log.Printf("Error generating webhook event id: %v", err)
+			return
+		}
+		e.ID = id
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	m.mu.Lock()
+	var targets []Hook
+	for _, h := range m.hooks {
+		if h.Wants(e) {
+			targets = append(targets, *h)
+		}
+	}
+	m.mu.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Error encoding webhook event %s: %v", e.Type, err)
+		return
+	}
+	for _, hook := range targets {
+		go m.deliver(hook, e, body, 1, m.opts.MaxAttempts)
+	}
+}
+
+// Ping sends a test event to one hook, whether or not it's active
+func (m *Manager) Ping(id, actor string) error {
+	m.mu.Lock()
+	var hook *Hook
+	for _, h := range m.hooks {
+		if h.ID == id {
+			copied := *h
+			hook = &copied
+			break
+		}
+	}
+	m.mu.Unlock()
+	if hook == nil {
+		return fmt.Errorf("webhook not found")
+	}
+
+	eventID, err := randomID(12)
+	if err != nil {
+		return fmt.Errorf("failed to generate event id: %v", err)
+	}
+	e := Event{ID: eventID, Type: EventPing, Time: time.Now().UTC(), Actor: actor}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// Tests are tried once so the result shows up right away
+	go m.deliver(*hook, e, body, 1, 1)
+	return nil
+}
+
+// deliver posts the event once and schedules a retry when that fails and
+// fewer than maxAttempts were made
+func (m *Manager) deliver(hook Hook, e Event, body []byte, attempt, maxAttempts int) {
+	deliveryID, _ := randomID(8)
+	record := Delivery{
+		ID:      deliveryID,
+		HookID:  hook.ID,
+		EventID: e.ID,
+		Event:   e.Type,
+		Path:    e.Path,
+		URL:     hook.URL,
+		Attempt: attempt,
+		Time:    time.Now().UTC(),
+	}
+
+	retry := false
+	started := time.Now()
+	status, err := m.post(hook, e, body, deliveryID)
+	record.DurationMS = time.Since(started).Milliseconds()
+	record.StatusCode = status
+	switch {
+	case err != nil:
+		record.Error = err.Error()
+		retry = true
+	case status < 200 || status >= 300:
+		record.Error = fmt.Sprintf("receiver responded with %d", status)
+		// Other client errors mean the receiver rejected the payload for good
+		retry = status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+	}
+
+	if retry && attempt < maxAttempts {
+		delay := retryDelay(attempt)
+		next := time.Now().Add(delay).UTC()
+		record.NextRetry = &next
+		time.AfterFunc(delay, func() {
+			// Skip the retry if the hook was deleted or paused meanwhile
+			if m.activeHook(hook.ID) {
+				m.deliver(hook, e, body, attempt+1, maxAttempts)
+			}
+		})
+	}
+	if record.Error != "" {
+		log.Printf("Webhook %s: delivery of %s %s failed (attempt %d): %s", hook.ID, e.Type, e.ID, attempt, record.Error)
+	}
+	m.record(record)
+}
+
+// post sends one signed request and returns the response status
+func (m *Manager) post(hook Hook, e Event, body []byte, deliveryID string) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "golang-my-wiki-webhooks")
+	req.Header.Set("X-Wiki-Event", e.Type)
+	req.Header.Set("X-Wiki-Delivery", deliveryID)
+	req.Header.Set("X-Wiki-Timestamp", timestamp)
+	req.Header.Set("X-Wiki-Signature", Sign(hook.Secret, timestamp, body))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, nil
+}
+
+// activeHook reports whether the hook still exists and is active
+func (m *Manager) activeHook(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.hooks {
+		if h.ID == id {
+			return h.Active
+		}
+	}
+	return false
+}
+
+// record appends a delivery attempt to the history, dropping the oldest ones
+func (m *Manager) record(d Delivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries = append(m.deliveries, d)
+	if over := len(m.deliveries) - m.opts.HistorySize; over > 0 {
+		m.deliveries = append([]Delivery(nil), m.deliveries[over:]...)
+	}
+	if err := writeJSON(filepath.Join(m.opts.Dir, "deliveries.json"), m.deliveries); err != nil {
+		log.Printf("Error saving webhook deliveries: %v", err)
+	}
+}
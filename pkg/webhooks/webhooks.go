@@ -0,0 +1,344 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events a webhook can subscribe to
+const (
+	EventPageCreated   = "page.created"
+	EventPageUpdated   = "page.updated"
+	EventPageDeleted   = "page.deleted"
+	EventFolderCreated = "folder.created"
+	EventFolderDeleted = "folder.deleted"
+	EventSyncCompleted = "sync.completed"
+
+	// EventPing is only sent by the "Send test" button, to every hook
+	EventPing = "ping"
+
+	// secretPrefix makes webhook secrets easy to recognise in logs and secret scanners
+	secretPrefix = "whsec_"
+)
+
+// Events lists the events a webhook can subscribe to, in display order
+var Events = []string{
+	EventPageCreated,
+	EventPageUpdated,
+	EventPageDeleted,
+	EventFolderCreated,
+	EventFolderDeleted,
+	EventSyncCompleted,
+}
+
+// ValidEvent reports whether the event name is known
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON payload posted to webhook URLs
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Path      string    `json:"path,omitempty"`
+	OldPath   string    `json:"old_path,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	ActorName string    `json:"actor_name,omitempty"`
+
+	// Paths lists the pages a sync created or changed
+	Paths []string `json:"paths,omitempty"`
+}
+
+// Hook is a registered webhook. The secret is kept in plain text because it
+// is needed to sign every delivery.
+type Hook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	Folder    string    `json:"folder,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants reports whether the hook should receive the event. The folder filter
+// applies to page and folder events; sync and ping events go to every hook
+// that subscribes to them.
+func (h *Hook) Wants(e Event) bool {
+	if !h.Active {
+		return false
+	}
+	if e.Type == EventPing {
+		return true
+	}
+	subscribed := false
+	for _, name := range h.Events {
+		if name == e.Type {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return false
+	}
+	if h.Folder == "" || e.Type == EventSyncCompleted {
+		return true
+	}
+	return inFolder(e.Path, h.Folder) || (e.OldPath != "" && inFolder(e.OldPath, h.Folder))
+}
+
+// inFolder reports whether the path is the folder or anything below it
+func inFolder(wikiPath, folder string) bool {
+	return wikiPath == folder || strings.HasPrefix(wikiPath, folder+"/")
+}
+
+// Delivery records one attempt to deliver an event to a hook
+type Delivery struct {
+	ID         string    `json:"id"`
+	HookID     string    `json:"hook_id"`
+	EventID    string    `json:"event_id"`
+	Event      string    `json:"event"`
+	Path       string    `json:"path,omitempty"`
+	URL        string    `json:"url"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Time       time.Time `json:"time"`
+
+	// NextRetry is set when the attempt failed and another one is scheduled
+	NextRetry *time.Time `json:"next_retry,omitempty"`
+}
+
+// Success reports whether the receiver accepted the delivery
+func (d Delivery) Success() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// Options configures a Manager
+type Options struct {
+	// Dir holds hooks.json and deliveries.json
+	Dir string
+
+	// Timeout bounds each delivery request
+	Timeout time.Duration
+
+	// MaxAttempts is how often a delivery is tried before giving up
+	MaxAttempts int
+
+	// HistorySize is how many delivery attempts are kept
+	HistorySize int
+}
+
+// Manager stores webhooks and delivers events to them
+type Manager struct {
+	opts       Options
+	client     *http.Client
+	mu         sync.Mutex
+	hooks      []*Hook
+	deliveries []Delivery
+}
+
+// NewManager loads registered hooks and their delivery history
+func NewManager(opts Options) (*Manager, error) {
+	m := &Manager{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+	if err := readJSON(filepath.Join(opts.Dir, "hooks.json"), &m.hooks); err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %v", err)
+	}
+	if err := readJSON(filepath.Join(opts.Dir, "deliveries.json"), &m.deliveries); err != nil {
+		return nil, fmt.Errorf("failed to load webhook deliveries: %v", err)
+	}
+	log.Printf("Loaded %d webhooks", len(m.hooks))
+	return m, nil
+}
+
+// readJSON decodes a file into v, leaving v alone if the file doesn't exist
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON replaces a file atomically with the JSON encoding of v
+func writeJSON(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create webhook directory: %v", err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", filepath.Base(path), err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// saveHooks writes the hooks to disk. The caller must hold the lock.
+func (m *Manager) saveHooks() error {
+	return writeJSON(filepath.Join(m.opts.Dir, "hooks.json"), m.hooks)
+}
+
+// randomID returns n random bytes as hex
+func randomID(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// Create registers a webhook and returns it including its signing secret
+func (m *Manager) Create(rawURL string, events []string, folder, createdBy string) (*Hook, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("URL must be an absolute http or https URL")
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("at least one event is required")
+	}
+	for _, event := range events {
+		if !ValidEvent(event) {
+			return nil, fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	id, err := randomID(8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook id: %v", err)
+	}
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+
+	hook := &Hook{
+		ID:        id,
+		URL:       u.String(),
+		Secret:    secretPrefix + base64.RawURLEncoding.EncodeToString(raw),
+		Events:    events,
+		Folder:    strings.Trim(folder, "/"),
+		Active:    true,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook)
+	if err := m.saveHooks(); err != nil {
+		m.hooks = m.hooks[:len(m.hooks)-1]
+		return nil, err
+	}
+
+	log.Printf("Created webhook %s for %s with events %v", hook.ID, hook.URL, events)
+	copied := *hook
+	return &copied, nil
+}
+
+// Hooks returns all registered webhooks, newest first
+func (m *Manager) Hooks() []Hook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]Hook, 0, len(m.hooks))
+	for _, h := range m.hooks {
+		result = append(result, *h)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// SetActive pauses or resumes deliveries to a webhook
+func (m *Manager) SetActive(id string, active bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, h := range m.hooks {
+		if h.ID == id {
+			h.Active = active
+			if err := m.saveHooks(); err != nil {
+				return err
+			}
+			log.Printf("Webhook %s active=%v", id, active)
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook not found")
+}
+
+// Delete removes a webhook. Its delivery history is kept until it ages out.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, h := range m.hooks {
+		if h.ID == id {
+			m.hooks = append(m.hooks[:i], m.hooks[i+1:]...)
+			if err := m.saveHooks(); err != nil {
+				return err
+			}
+			log.Printf("Deleted webhook %s (%s)", h.ID, h.URL)
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook not found")
+}
+
+// Deliveries returns the most recent delivery attempts, newest first,
+// optionally only those for one hook
+func (m *Manager) Deliveries(hookID string, limit int) []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []Delivery
+	for i := len(m.deliveries) - 1; i >= 0; i-- {
+		d := m.deliveries[i]
+		if hookID != "" && d.HookID != hookID {
+			continue
+		}
+		result = append(result, d)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
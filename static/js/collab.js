@@ -0,0 +1,418 @@
+// Real-time collaborative editing
+//
+// Edits are exchanged as operational transforms in the ot.js format: an
+// operation is a list of components where a positive number retains that many
+// characters, a negative number deletes them and a string inserts it.
+// Positions count Unicode code points so they match the server.
+
+function TextOperation() {
+    this.ops = [];
+    this.baseLength = 0;
+    this.targetLength = 0;
+}
+
+TextOperation.prototype.retain = function(n) {
+    if (n <= 0) return this;
+    this.baseLength += n;
+    this.targetLength += n;
+    const last = this.ops[this.ops.length - 1];
+    if (typeof last === 'number' && last > 0) {
+        this.ops[this.ops.length - 1] += n;
+    } else {
+        this.ops.push(n);
+    }
+    return this;
+};
+
+TextOperation.prototype.insert = function(str) {
+    if (str === '') return this;
+    this.targetLength += Array.from(str).length;
+    const ops = this.ops;
+    const last = ops[ops.length - 1];
+    if (typeof last === 'string') {
+        ops[ops.length - 1] += str;
+    } else if (typeof last === 'number' && last < 0) {
+        // Keep inserts before deletes so equivalent operations look the same
+        if (typeof ops[ops.length - 2] === 'string') {
+            ops[ops.length - 2] += str;
+        } else {
+            ops[ops.length] = last;
+            ops[ops.length - 2] = str;
+        }
+    } else {
+        ops.push(str);
+    }
+    return this;
+};
+
+TextOperation.prototype.delete = function(n) {
+    if (n <= 0) return this;
+    this.baseLength += n;
+    const last = this.ops[this.ops.length - 1];
+    if (typeof last === 'number' && last < 0) {
+        this.ops[this.ops.length - 1] -= n;
+    } else {
+        this.ops.push(-n);
+    }
+    return this;
+};
+
+TextOperation.fromJSON = function(ops) {
+    const op = new TextOperation();
+    ops.forEach(function(c) {
+        if (typeof c === 'string') op.insert(c);
+        else if (c > 0) op.retain(c);
+        else op.delete(-c);
+    });
+    return op;
+};
+
+// Build the operation that turns oldText into newText, assuming a single
+// edited region as produced by typing, pasting or deleting
+TextOperation.fromDiff = function(oldText, newText) {
+    const a = Array.from(oldText);
+    const b = Array.from(newText);
+    let prefix = 0;
+    while (prefix < a.length && prefix < b.length && a[prefix] === b[prefix]) prefix++;
+    let suffix = 0;
+    while (suffix < a.length - prefix && suffix < b.length - prefix &&
+           a[a.length - 1 - suffix] === b[b.length - 1 - suffix]) suffix++;
+
+    return new TextOperation()
+        .retain(prefix)
+        .delete(a.length - prefix - suffix)
+        .insert(b.slice(prefix, b.length - suffix).join(''))
+        .retain(suffix);
+};
+
+TextOperation.prototype.apply = function(text) {
+    const chars = Array.from(text);
+    if (chars.length !== this.baseLength) {
+        throw new Error('Operation does not match the document length');
+    }
+    const result = [];
+    let pos = 0;
+    this.ops.forEach(function(c) {
+        if (typeof c === 'string') {
+            result.push(c);
+        } else if (c > 0) {
+            result.push(chars.slice(pos, pos + c).join(''));
+            pos += c;
+        } else {
+            pos -= c;
+        }
+    });
+    return result.join('');
+};
+
+// Combine this operation with one that follows it into a single operation
+TextOperation.prototype.compose = function(other) {
+    const result = new TextOperation();
+    const ops1 = this.ops.slice();
+    const ops2 = other.ops.slice();
+    let i1 = 0, i2 = 0;
+    let op1 = ops1[i1++], op2 = ops2[i2++];
+
+    while (op1 !== undefined || op2 !== undefined) {
+        if (typeof op1 === 'number' && op1 < 0) {
+            result.delete(-op1);
+            op1 = ops1[i1++];
+            continue;
+        }
+        if (typeof op2 === 'string') {
+            result.insert(op2);
+            op2 = ops2[i2++];
+            continue;
+        }
+        if (op1 === undefined || op2 === undefined) {
+            throw new Error('Operations cannot be composed');
+        }
+
+        if (typeof op1 === 'string') {
+            const len = Array.from(op1).length;
+            if (op2 > 0) {
+                if (len > op2) {
+                    const chars = Array.from(op1);
+                    result.insert(chars.slice(0, op2).join(''));
+                    op1 = chars.slice(op2).join('');
+                    op2 = ops2[i2++];
+                } else {
+                    result.insert(op1);
+                    op1 = ops1[i1++];
+                    op2 = len === op2 ? ops2[i2++] : op2 - len;
+                }
+            } else {
+                // Inserted then deleted
+                if (len > -op2) {
+                    op1 = Array.from(op1).slice(-op2).join('');
+                    op2 = ops2[i2++];
+                } else {
+                    op1 = ops1[i1++];
+                    op2 = len === -op2 ? ops2[i2++] : op2 + len;
+                }
+            }
+        } else if (op2 > 0) {
+            const n = Math.min(op1, op2);
+            result.retain(n);
+            op1 = op1 === n ? ops1[i1++] : op1 - n;
+            op2 = op2 === n ? ops2[i2++] : op2 - n;
+        } else {
+            const n = Math.min(op1, -op2);
+            result.delete(n);
+            op1 = op1 === n ? ops1[i1++] : op1 - n;
+            op2 = -op2 === n ? ops2[i2++] : op2 + n;
+        }
+    }
+    return result;
+};
+
+// Transform two concurrent operations; the first one's inserts win ties,
+// matching the server which always passes the client operation first
+TextOperation.transform = function(a, b) {
+    const aPrime = new TextOperation();
+    const bPrime = new TextOperation();
+    const ops1 = a.ops.slice();
+    const ops2 = b.ops.slice();
+    let i1 = 0, i2 = 0;
+    let op1 = ops1[i1++], op2 = ops2[i2++];
+
+    while (op1 !== undefined || op2 !== undefined) {
+        if (typeof op1 === 'string') {
+            aPrime.insert(op1);
+            bPrime.retain(Array.from(op1).length);
+            op1 = ops1[i1++];
+            continue;
+        }
+        if (typeof op2 === 'string') {
+            aPrime.retain(Array.from(op2).length);
+            bPrime.insert(op2);
+            op2 = ops2[i2++];
+            continue;
+        }
+        if (op1 === undefined || op2 === undefined) {
+            throw new Error('Operations cannot be transformed');
+        }
+
+        let n;
+        if (op1 > 0 && op2 > 0) {
+            n = Math.min(op1, op2);
+            aPrime.retain(n);
+            bPrime.retain(n);
+            op1 = op1 === n ? ops1[i1++] : op1 - n;
+            op2 = op2 === n ? ops2[i2++] : op2 - n;
+        } else if (op1 < 0 && op2 < 0) {
+            n = Math.min(-op1, -op2);
+            op1 = -op1 === n ? ops1[i1++] : op1 + n;
+            op2 = -op2 === n ? ops2[i2++] : op2 + n;
+        } else if (op1 < 0) {
+            n = Math.min(-op1, op2);
+            aPrime.delete(n);
+            op1 = -op1 === n ? ops1[i1++] : op1 + n;
+            op2 = op2 === n ? ops2[i2++] : op2 - n;
+        } else {
+            n = Math.min(op1, -op2);
+            bPrime.delete(n);
+            op1 = op1 === n ? ops1[i1++] : op1 - n;
+            op2 = -op2 === n ? ops2[i2++] : op2 + n;
+        }
+    }
+    return [aPrime, bPrime];
+};
+
+// Move a position in the base document to the matching position afterwards
+TextOperation.prototype.transformPosition = function(pos) {
+    let index = 0;
+    let newPos = pos;
+    for (let i = 0; i < this.ops.length && index <= pos; i++) {
+        const c = this.ops[i];
+        if (typeof c === 'string') {
+            newPos += Array.from(c).length;
+        } else if (c > 0) {
+            index += c;
+        } else {
+            newPos -= Math.min(-c, pos - index);
+            index -= c;
+        }
+    }
+    return newPos;
+};
+
+// CollabClient keeps a document in sync with the server. The editor is
+// reached through callbacks:
+//   getText()                 current editor content
+//   setText(text, op)         replace the content after a remote edit
+//   getCursor()               cursor position in code points, or null
+//   onPeers(peers)            connected editors changed
+//   onStatus(text)            connection or save status changed
+function CollabClient(url, callbacks) {
+    this.url = url;
+    this.cb = callbacks;
+    this.rev = 0;
+    this.text = '';
+    this.outstanding = null;
+    this.buffer = null;
+    this.peers = {};
+    this.connected = false;
+    this.retryDelay = 1000;
+    this.lastCursor = null;
+    this.connect();
+}
+
+CollabClient.prototype.connect = function() {
+    const self = this;
+    const scheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+    this.ws = new WebSocket(scheme + window.location.host + this.url);
+    this.cb.onStatus('Connecting...');
+
+    this.ws.onmessage = function(e) {
+        self.handleMessage(JSON.parse(e.data));
+    };
+    this.ws.onclose = function() {
+        self.connected = false;
+        self.cb.onStatus('Offline, reconnecting...');
+        self.peers = {};
+        self.cb.onPeers([]);
+        setTimeout(function() { self.connect(); }, self.retryDelay);
+        self.retryDelay = Math.min(self.retryDelay * 2, 30000);
+    };
+};
+
+CollabClient.prototype.send = function(msg) {
+    if (this.connected) this.ws.send(JSON.stringify(msg));
+};
+
+CollabClient.prototype.handleMessage = function(msg) {
+    switch (msg.type) {
+    case 'init':
+        this.handleInit(msg);
+        break;
+    case 'ack':
+        this.rev = msg.rev;
+        if (this.buffer) {
+            this.outstanding = this.buffer;
+            this.buffer = null;
+            this.send({ type: 'op', rev: this.rev, op: this.outstanding.ops });
+        } else {
+            this.outstanding = null;
+        }
+        break;
+    case 'op':
+        // Local edits captured while applying are still based on the old revision
+        this.applyServer(TextOperation.fromJSON(msg.op), msg.client);
+        this.rev = msg.rev;
+        // With nothing in flight, later operations are based on this revision
+        // at the earliest, so the server can forget older history
+        if (!this.outstanding && !this.buffer) {
+            this.send({ type: 'seen', rev: this.rev });
+        }
+        break;
+    case 'join':
+        this.peers[msg.peer.id] = msg.peer;
+        this.cb.onPeers(this.peerList());
+        break;
+    case 'leave':
+        delete this.peers[msg.client];
+        this.cb.onPeers(this.peerList());
+        break;
+    case 'cursor':
+        if (this.peers[msg.client]) {
+            this.peers[msg.client].pos = msg.pos;
+            this.cb.onPeers(this.peerList());
+        }
+        break;
+    case 'saved':
+        this.cb.onStatus('Saved at ' + new Date(msg.savedAt).toLocaleTimeString());
+        break;
+    case 'saveFailed':
+    case 'error':
+        this.cb.onStatus(msg.message);
+        break;
+    }
+};
+
+// Start from the server copy, then replay any local edits it never received
+CollabClient.prototype.handleInit = function(msg) {
+    const hadPending = this.outstanding !== null || this.buffer !== null;
+
+    this.connected = true;
+    this.retryDelay = 1000;
+    this.rev = msg.rev;
+    this.text = msg.text;
+    this.outstanding = null;
+    this.buffer = null;
+    this.peers = {};
+    (msg.peers || []).forEach(function(p) { this.peers[p.id] = p; }, this);
+    this.cb.onPeers(this.peerList());
+    this.cb.onStatus('Connected');
+
+    const current = this.cb.getText();
+    if (current !== this.text) {
+        if (hadPending) {
+            this.localChange();
+        } else {
+            this.cb.setText(this.text, null);
+        }
+    }
+};
+
+// Call whenever the editor content changes
+CollabClient.prototype.localChange = function() {
+    const current = this.cb.getText();
+    if (current === this.text) return;
+
+    const op = TextOperation.fromDiff(this.text, current);
+    this.text = current;
+    if (this.buffer) {
+        this.buffer = this.buffer.compose(op);
+    } else if (this.outstanding) {
+        this.buffer = op;
+    } else {
+        this.outstanding = op;
+        this.send({ type: 'op', rev: this.rev, op: op.ops });
+    }
+    this.cb.onStatus('Editing...');
+};
+
+CollabClient.prototype.applyServer = function(op, clientId) {
+    // Capture anything typed since the last change event first
+    this.localChange();
+
+    if (this.outstanding) {
+        const pair = TextOperation.transform(this.outstanding, op);
+        this.outstanding = pair[0];
+        op = pair[1];
+    }
+    if (this.buffer) {
+        const pair = TextOperation.transform(this.buffer, op);
+        this.buffer = pair[0];
+        op = pair[1];
+    }
+
+    this.text = op.apply(this.text);
+    Object.keys(this.peers).forEach(function(id) {
+        if (id !== clientId) this.peers[id].pos = op.transformPosition(this.peers[id].pos);
+    }, this);
+    this.cb.setText(this.text, op);
+    this.cb.onPeers(this.peerList());
+};
+
+// Call when the local cursor may have moved
+CollabClient.prototype.cursorMoved = function() {
+    const pos = this.cb.getCursor();
+    if (pos === null || pos === this.lastCursor) return;
+    this.lastCursor = pos;
+    this.send({ type: 'cursor', pos: pos });
+};
+
+CollabClient.prototype.peerList = function() {
+    const text = this.text;
+    return Object.keys(this.peers).map(function(id) {
+        const p = Object.assign({}, this.peers[id]);
+        p.line = Array.from(text).slice(0, p.pos).join('').split('\n').length;
+        return p;
+    }, this);
+};
+
+CollabClient.prototype.isSynced = function() {
+    return this.connected && !this.outstanding && !this.buffer;
+};
@@ -0,0 +1,245 @@
+// Comment threads under a page, loaded from and saved to /comments
+
+let commentsUrl = '';
+let commentsData = null;
+
+// Comment bodies are Markdown written by anyone who can comment, so raw HTML
+// is shown as text and only web, mail and in-wiki links are kept
+const commentMarked = new marked.Marked({
+    breaks: true,
+    gfm: true,
+    renderer: {
+        html(html) {
+            return escapeCommentHtml(html);
+        },
+        link(href, title, text) {
+            return safeCommentUrl(href) ? false : text;
+        },
+        image(href, title, text) {
+            return safeCommentUrl(href) ? false : escapeCommentHtml(text || '');
+        }
+    }
+});
+
+function escapeCommentHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text;
+    return div.innerHTML;
+}
+
+function safeCommentUrl(href) {
+    return /^(https?:|mailto:|\/|#)/i.test(href || '');
+}
+
+function initComments(title, folder) {
+    commentsUrl = `/comments/${encodeURIComponent(title)}`;
+    if (folder) {
+        commentsUrl += `?folder=${encodeURIComponent(folder)}`;
+    }
+
+    const form = document.getElementById('comment-form');
+    if (form) {
+        form.addEventListener('submit', function(event) {
+            event.preventDefault();
+            const textarea = document.getElementById('comment-body');
+            sendComment('POST', '', { body: textarea.value }).then(ok => {
+                if (ok) textarea.value = '';
+            });
+        });
+    }
+
+    fetch(commentsUrl)
+        .then(response => response.json().then(data => ({ ok: response.ok, data })))
+        .then(({ ok, data }) => {
+            if (!ok) {
+                throw new Error(data.error || 'Failed to load comments');
+            }
+            renderComments(data);
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            document.getElementById('comment-threads').textContent = error.message;
+        });
+}
+
+// commentUrl adds a comment id and action to the page's comments URL
+function commentUrl(id, action) {
+    const [path, query] = commentsUrl.split('?');
+    let url = path;
+    if (id) url += `/${encodeURIComponent(id)}`;
+    if (action) url += `/${action}`;
+    return query ? `${url}?${query}` : url;
+}
+
+// sendComment makes a change and redraws the threads, resolving to whether it worked
+function sendComment(method, url, body) {
+    const options = { method };
+    if (body !== undefined) {
+        options.headers = { 'Content-Type': 'application/json' };
+        options.body = JSON.stringify(body);
+    }
+    return fetch(url || commentUrl(), options)
+        .then(response => response.json().then(data => ({ ok: response.ok, data })))
+        .then(({ ok, data }) => {
+            if (!ok) {
+                throw new Error(data.error || 'Failed to save comment');
+            }
+            renderComments(data);
+            return true;
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            alert(error.message || 'Error saving comment. Please try again.');
+            return false;
+        });
+}
+
+function renderComments(data) {
+    commentsData = data;
+    const container = document.getElementById('comment-threads');
+    container.innerHTML = '';
+
+    const count = data.threads.reduce((n, t) => n + 1 + t.replies.length, 0);
+    document.getElementById('comment-count').textContent = count ? `(${count})` : '';
+    document.getElementById('comment-form').hidden = !data.canComment;
+
+    if (data.threads.length === 0) {
+        const empty = document.createElement('p');
+        empty.className = 'comments-empty';
+        empty.textContent = 'No comments yet.';
+        container.appendChild(empty);
+        return;
+    }
+
+    data.threads.forEach(thread => container.appendChild(renderThread(thread)));
+}
+
+function renderThread(thread) {
+    const el = document.createElement('div');
+    el.className = 'comment-thread' + (thread.resolved ? ' resolved' : '');
+
+    if (thread.resolved) {
+        const note = document.createElement('div');
+        note.className = 'comment-resolved-note';
+        note.innerHTML = '<i class="fas fa-check-circle"></i> ';
+        note.appendChild(document.createTextNode(`Resolved by ${thread.resolved_by}`));
+        const toggle = document.createElement('button');
+        toggle.className = 'comment-action';
+        toggle.textContent = 'Show';
+        toggle.addEventListener('click', () => {
+            el.classList.toggle('expanded');
+            toggle.textContent = el.classList.contains('expanded') ? 'Hide' : 'Show';
+        });
+        note.appendChild(toggle);
+        el.appendChild(note);
+    }
+
+    const body = document.createElement('div');
+    body.className = 'comment-thread-body';
+    body.appendChild(renderComment(thread, true));
+    thread.replies.forEach(reply => body.appendChild(renderComment(reply, false)));
+
+    if (commentsData.canComment) {
+        const reply = document.createElement('button');
+        reply.className = 'comment-action comment-reply-toggle';
+        reply.innerHTML = '<i class="fas fa-reply"></i> Reply';
+        reply.addEventListener('click', () => {
+            reply.hidden = true;
+            body.appendChild(commentEditor('', 'Reply', text =>
+                sendComment('POST', '', { body: text, parent_id: thread.id }),
+                () => { reply.hidden = false; }));
+        });
+        body.appendChild(reply);
+    }
+
+    el.appendChild(body);
+    return el;
+}
+
+function renderComment(comment, isThread) {
+    const el = document.createElement('div');
+    el.className = 'comment' + (isThread ? '' : ' comment-reply');
+
+    const header = document.createElement('div');
+    header.className = 'comment-header';
+    const author = document.createElement('span');
+    author.className = 'comment-author';
+    author.textContent = comment.deleted ? '' : (comment.author_name || comment.author_email);
+    const time = document.createElement('span');
+    time.className = 'comment-time';
+    time.textContent = new Date(comment.created_at).toLocaleString() + (comment.edited_at ? ' (edited)' : '');
+    header.append(author, time);
+
+    const body = document.createElement('div');
+    body.className = 'comment-body';
+    if (comment.deleted) {
+        body.classList.add('deleted');
+        body.textContent = 'This comment was deleted.';
+    } else {
+        body.innerHTML = commentMarked.parse(comment.body);
+    }
+
+    const actions = document.createElement('div');
+    actions.className = 'comment-actions';
+    const mine = !comment.deleted && comment.author_email.toLowerCase() === (commentsData.me || '').toLowerCase();
+    if (mine && commentsData.canComment) {
+        actions.appendChild(commentButton('fa-edit', 'Edit', () => {
+            body.hidden = true;
+            actions.hidden = true;
+            el.appendChild(commentEditor(comment.body, 'Save', text =>
+                sendComment('PUT', commentUrl(comment.id), { body: text }),
+                () => { body.hidden = false; actions.hidden = false; }));
+        }));
+        actions.appendChild(commentButton('fa-trash', 'Delete', () => {
+            if (confirm('Delete this comment?')) {
+                sendComment('DELETE', commentUrl(comment.id));
+            }
+        }));
+    }
+    if (isThread && commentsData.canResolve) {
+        const label = comment.resolved ? 'Reopen' : 'Resolve';
+        const icon = comment.resolved ? 'fa-undo' : 'fa-check';
+        actions.appendChild(commentButton(icon, label, () =>
+            sendComment('POST', commentUrl(comment.id, 'resolve'), { resolved: !comment.resolved })));
+    }
+
+    el.append(header, body, actions);
+    return el;
+}
+
+function commentButton(icon, label, onClick) {
+    const button = document.createElement('button');
+    button.className = 'comment-action';
+    button.innerHTML = `<i class="fas ${icon}"></i> ${label}`;
+    button.addEventListener('click', onClick);
+    return button;
+}
+
+// commentEditor is an inline textarea with save and cancel buttons
+function commentEditor(text, saveLabel, onSave, onCancel) {
+    const form = document.createElement('form');
+    form.className = 'comment-editor';
+    const textarea = document.createElement('textarea');
+    textarea.value = text;
+    textarea.rows = 3;
+    textarea.required = true;
+    const save = document.createElement('button');
+    save.type = 'submit';
+    save.className = 'button primary';
+    save.textContent = saveLabel;
+    const cancel = document.createElement('button');
+    cancel.type = 'button';
+    cancel.className = 'button';
+    cancel.textContent = 'Cancel';
+    cancel.addEventListener('click', () => {
+        form.remove();
+        onCancel();
+    });
+    form.addEventListener('submit', event => {
+        event.preventDefault();
+        onSave(textarea.value);
+    });
+    form.append(textarea, save, cancel);
+    setTimeout(() => textarea.focus(), 0);
+    return form;
+}
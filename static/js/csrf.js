@@ -0,0 +1,40 @@
+// Sends the session's CSRF token with every state-changing request to the wiki
+
+(function() {
+    const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS'];
+    const originalFetch = window.fetch;
+
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)wiki_csrf=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    window.fetch = function(resource, options) {
+        options = options || {};
+        const method = (options.method || 'GET').toUpperCase();
+        const url = new URL(typeof resource === 'string' ? resource : resource.url, window.location.href);
+
+        if (!SAFE_METHODS.includes(method) && url.origin === window.location.origin) {
+            const headers = new Headers(options.headers || {});
+            headers.set('X-CSRF-Token', csrfToken());
+            options = Object.assign({}, options, { headers: headers });
+        }
+        return originalFetch.call(this, resource, options);
+    };
+
+    // Plain forms posting to the wiki, such as Logout, send the token in a field
+    document.addEventListener('submit', function(e) {
+        const form = e.target;
+        if (form.method.toUpperCase() !== 'POST' || new URL(form.action).origin !== window.location.origin) {
+            return;
+        }
+        let field = form.querySelector('input[name="csrf_token"]');
+        if (!field) {
+            field = document.createElement('input');
+            field.type = 'hidden';
+            field.name = 'csrf_token';
+            form.appendChild(field);
+        }
+        if (!field.value) field.value = csrfToken();
+    });
+})();
@@ -0,0 +1,43 @@
+// Star buttons on pages and folders
+
+function toggleFavorite(button) {
+    const path = button.dataset.path;
+    const folder = button.dataset.folder === 'true';
+    const favorite = button.dataset.favorite === 'true';
+
+    let request;
+    if (favorite) {
+        const params = new URLSearchParams({ path, folder: String(folder) });
+        request = fetch(`/favorites?${params.toString()}`, {
+            method: 'DELETE'
+        });
+    } else {
+        request = fetch('/favorites', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ path, folder })
+        });
+    }
+
+    request
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to update favorite');
+        }
+        button.dataset.favorite = String(data.favorite);
+        const label = folder ? ' Folder' : '';
+        button.innerHTML = data.favorite
+            ? `<i class="fas fa-star"></i> Unstar${label}`
+            : `<i class="far fa-star"></i> Star${label}`;
+        if (typeof loadSidebarFavorites === 'function') {
+            loadSidebarFavorites();
+        }
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error updating favorite. Please try again.');
+    });
+}
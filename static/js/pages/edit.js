@@ -1,10 +1,22 @@
 let editor;
 let isDirty = false;
 let lastSavedContent = '';
+let lastDraft = null;
+let publishing = false;
+let collab = null;
+let applyingRemote = false;
+
+const AUTOSAVE_INTERVAL_MS = 5000;
 
 document.addEventListener('DOMContentLoaded', function() {
     initEditor();
     initEventListeners();
+    initDrafts();
+    initCollab();
+
+    // Let others know this page is open in the editor
+    const fields = draftFields();
+    initPresence(fields.oldTitle, fields.folder, true);
 });
 
 function isDarkTheme() {
@@ -46,7 +58,12 @@ function initEditor() {
         // Use default toolbar so all buttons (including codeblock) are available
         events: {
             change: function() {
+                if (applyingRemote) return;
                 isDirty = editor.getMarkdown() !== lastSavedContent;
+                if (collab) collab.localChange();
+            },
+            caretChange: function() {
+                if (collab) collab.cursorMoved();
             }
         }
     });
@@ -70,6 +87,12 @@ function initEventListeners() {
     const form = document.getElementById('note-form');
     const saveBtn = document.getElementById('save-btn');
 
+    const templateSelect = document.getElementById('template');
+    if (templateSelect) {
+        templateSelect.dataset.current = templateSelect.value;
+        templateSelect.addEventListener('change', applyTemplate);
+    }
+
     if (form) form.addEventListener('submit', function(e) { e.preventDefault(); saveContent(); });
     if (saveBtn) saveBtn.addEventListener('click', function(e) { e.preventDefault(); saveContent(); });
 
@@ -78,10 +101,191 @@ function initEventListeners() {
     });
 }
 
+// Replace the editor content with the chosen template
+function applyTemplate(e) {
+    const select = e.target;
+    if (isDirty && !confirm('Replace your changes with this template?')) {
+        select.value = select.dataset.current || '';
+        return;
+    }
+
+    let content = '';
+    document.querySelectorAll('.template-content').forEach(function(el) {
+        if (el.dataset.name === select.value) content = el.value;
+    });
+    editor.setMarkdown(content);
+    lastSavedContent = editor.getMarkdown();
+    isDirty = false;
+    select.dataset.current = select.value;
+}
+
+// Fields identifying the page a draft belongs to
+function draftFields() {
+    const folderPathInput = document.querySelector('input[name="folder_path"]');
+    const originalTitleInput = document.querySelector('input[name="original_title"]');
+    return {
+        folder: folderPathInput ? folderPathInput.value : '',
+        oldTitle: originalTitleInput ? originalTitleInput.value : ''
+    };
+}
+
+function initDrafts() {
+    const titleInput = document.getElementById('title');
+    lastDraft = { title: titleInput ? titleInput.value : '', content: editor.getMarkdown() };
+
+    const restoreBtn = document.getElementById('restore-draft-btn');
+    if (restoreBtn) restoreBtn.addEventListener('click', restoreDraft);
+    const discardBtn = document.getElementById('discard-draft-btn');
+    if (discardBtn) discardBtn.addEventListener('click', discardDraft);
+
+    setInterval(autosaveDraft, AUTOSAVE_INTERVAL_MS);
+}
+
+// Save the editor content as a draft if it changed since the last autosave
+function autosaveDraft() {
+    // Connected collaborative sessions are saved by the server
+    if (publishing || !editor || (collab && collab.connected)) return;
+
+    const titleInput = document.getElementById('title');
+    const title = titleInput ? titleInput.value : '';
+    const content = editor.getMarkdown();
+    if (title === lastDraft.title && content === lastDraft.content) return;
+
+    const fields = draftFields();
+    fetch('/drafts', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ folder: fields.folder, oldTitle: fields.oldTitle, title, content })
+    })
+    .then(function(response) {
+        if (!response.ok) throw new Error('Server returned ' + response.status);
+        return response.json();
+    })
+    .then(function(data) {
+        lastDraft = { title, content };
+        setDraftStatus('Draft saved at ' + new Date(data.savedAt).toLocaleTimeString());
+    })
+    .catch(function(error) {
+        console.error('Autosave failed:', error);
+        setDraftStatus('Draft not saved');
+    });
+}
+
+function setDraftStatus(text) {
+    const status = document.getElementById('draft-status');
+    if (status) status.textContent = text;
+}
+
+function restoreDraft() {
+    const titleInput = document.getElementById('title');
+    const draftTitle = document.getElementById('draft-title');
+    if (titleInput && draftTitle && draftTitle.value) titleInput.value = draftTitle.value;
+    editor.setMarkdown(document.getElementById('draft-content').value);
+    isDirty = true;
+    hideDraftBanner();
+}
+
+function discardDraft() {
+    const fields = draftFields();
+    const params = new URLSearchParams({ folder: fields.folder, oldTitle: fields.oldTitle });
+    fetch('/drafts?' + params.toString(), { method: 'DELETE' })
+    .then(function(response) {
+        if (!response.ok) throw new Error('Server returned ' + response.status);
+        hideDraftBanner();
+    })
+    .catch(function(error) {
+        showNotification('Error discarding draft: ' + error.message, 'error');
+    });
+}
+
+function hideDraftBanner() {
+    const banner = document.getElementById('draft-banner');
+    if (banner) banner.remove();
+}
+
+// Join the page's collaborative session so edits sync with other editors
+function initCollab() {
+    const presence = document.getElementById('collab-presence');
+    if (!presence || !window.WebSocket) return;
+
+    const fields = draftFields();
+    let url = '/collab/' + encodeURIComponent(fields.oldTitle);
+    if (fields.folder) url += '?folder=' + encodeURIComponent(fields.folder);
+
+    collab = new CollabClient(url, {
+        getText: function() { return editor.getMarkdown(); },
+        setText: setCollabText,
+        getCursor: getCursorOffset,
+        onPeers: renderPeers,
+        onStatus: function(text) {
+            document.getElementById('collab-status').textContent = text;
+        }
+    });
+    presence.hidden = false;
+}
+
+// Replace the editor content after a remote edit, keeping the local cursor
+// in place when editing Markdown
+function setCollabText(text, op) {
+    const cursor = getCursorOffset();
+    applyingRemote = true;
+    try {
+        editor.setMarkdown(text, false);
+        if (cursor !== null && op) setCursorOffset(op.transformPosition(cursor));
+    } finally {
+        applyingRemote = false;
+    }
+    lastSavedContent = text;
+    isDirty = false;
+}
+
+// Cursor position in code points, available in Markdown mode only
+function getCursorOffset() {
+    if (!editor.isMarkdownMode()) return null;
+    const end = editor.getSelection()[1];
+    const lines = editor.getMarkdown().split('\n');
+    let offset = 0;
+    for (let i = 0; i < end[0] - 1 && i < lines.length; i++) {
+        offset += Array.from(lines[i]).length + 1;
+    }
+    const line = lines[end[0] - 1] || '';
+    return offset + Array.from(line.slice(0, end[1] - 1)).length;
+}
+
+function setCursorOffset(offset) {
+    const lines = editor.getMarkdown().split('\n');
+    let line = 0;
+    while (line < lines.length - 1 && offset > Array.from(lines[line]).length) {
+        offset -= Array.from(lines[line]).length + 1;
+        line++;
+    }
+    const ch = Array.from(lines[line]).slice(0, offset).join('').length;
+    editor.setSelection([line + 1, ch + 1], [line + 1, ch + 1]);
+}
+
+function renderPeers(peers) {
+    const list = document.getElementById('collab-peers');
+    list.innerHTML = '';
+    peers.forEach(function(peer) {
+        const chip = document.createElement('span');
+        chip.className = 'collab-peer';
+        chip.style.borderColor = peer.color;
+        chip.title = peer.email;
+
+        const dot = document.createElement('span');
+        dot.className = 'collab-dot';
+        dot.style.background = peer.color;
+        chip.appendChild(dot);
+        chip.appendChild(document.createTextNode(peer.name + ' · line ' + peer.line));
+        list.appendChild(chip);
+    });
+}
+
 function saveContent() {
     const titleInput = document.getElementById('title');
     const folderPathInput = document.querySelector('input[name="folder_path"]');
     const originalTitleInput = document.querySelector('input[name="original_title"]');
+    const summaryInput = document.getElementById('summary');
 
     if (!editor || !titleInput || !folderPathInput) {
         console.error('Required elements not found');
@@ -92,16 +296,18 @@ function saveContent() {
     const title = titleInput.value;
     const folderPath = folderPathInput.value;
     const oldTitle = originalTitleInput ? originalTitleInput.value : '';
+    const summary = summaryInput ? summaryInput.value.trim() : '';
 
     const saveBtn = document.getElementById('save-btn');
     const originalText = saveBtn.innerHTML;
-    saveBtn.innerHTML = '<i class="fas fa-spinner fa-spin"></i> Saving...';
+    saveBtn.innerHTML = '<i class="fas fa-spinner fa-spin"></i> Publishing...';
     saveBtn.disabled = true;
+    publishing = true;
 
     fetch('/save', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ title, content, folder: folderPath, oldTitle })
+        body: JSON.stringify({ title, content, folder: folderPath, oldTitle, summary })
     })
     .then(function(response) {
         if (!response.ok) {
@@ -115,10 +321,11 @@ function saveContent() {
         isDirty = false;
         lastSavedContent = content;
         if (data.redirect) window.location.href = data.redirect;
-        showNotification('Note saved successfully', 'success');
+        showNotification('Note published successfully', 'success');
     })
     .catch(function(error) {
-        showNotification('Error saving note: ' + error.message, 'error');
+        publishing = false;
+        showNotification('Error publishing note: ' + error.message, 'error');
     })
     .finally(function() {
         saveBtn.innerHTML = originalText;
@@ -136,5 +343,6 @@ function showNotification(message, type) {
 }
 
 window.addEventListener('beforeunload', function(e) {
-    if (isDirty) { e.preventDefault(); e.returnValue = ''; }
+    const unsaved = collab && collab.connected ? !collab.isSynced() : isDirty;
+    if (unsaved && !publishing) { e.preventDefault(); e.returnValue = ''; }
 });
@@ -0,0 +1,37 @@
+// Password change page functionality
+
+document.addEventListener('DOMContentLoaded', function() {
+    const form = document.getElementById('password-change-form');
+    if (form) form.addEventListener('submit', changePassword);
+});
+
+function changePassword(event) {
+    event.preventDefault();
+
+    const current = document.getElementById('current-password').value;
+    const next = document.getElementById('new-password').value;
+    if (next !== document.getElementById('confirm-password').value) {
+        alert('The new passwords do not match.');
+        return;
+    }
+
+    fetch('/settings/password', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ current, new: next })
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to change password');
+        }
+        document.getElementById('password-change-form').reset();
+        alert('Your password has been changed.');
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error changing password. Please try again.');
+    });
+}
@@ -0,0 +1,49 @@
+// Edit presence: shows who else has a page open in the editor. The editor
+// sends heartbeats so others can see it; the view page only polls.
+
+const PRESENCE_INTERVAL_MS = 20000;
+
+function presenceUrl(title, folder) {
+    let url = '/presence/' + encodeURIComponent(title);
+    if (folder) url += '?folder=' + encodeURIComponent(folder);
+    return url;
+}
+
+function initPresence(title, folder, editing) {
+    const banner = document.getElementById('presence-banner');
+    if (!banner || !title) return;
+
+    const url = presenceUrl(title, folder);
+    const refresh = function() {
+        fetch(url, { method: editing ? 'POST' : 'GET' })
+        .then(function(response) {
+            if (!response.ok) throw new Error('Server returned ' + response.status);
+            return response.json();
+        })
+        .then(function(data) { renderPresence(banner, data.editors); })
+        .catch(function(error) { console.error('Presence update failed:', error); });
+    };
+    setInterval(refresh, PRESENCE_INTERVAL_MS);
+    if (!editing) return;
+
+    // Stop showing up as an editor as soon as the page is closed
+    window.addEventListener('pagehide', function() {
+        fetch(url, { method: 'DELETE', keepalive: true });
+    });
+}
+
+function renderPresence(banner, editors) {
+    if (!editors || editors.length === 0) {
+        banner.hidden = true;
+        return;
+    }
+    const names = editors.map(function(e) { return e.name || e.email; });
+    let text;
+    if (names.length === 1) {
+        text = names[0] + ' is editing this page';
+    } else {
+        text = names.slice(0, -1).join(', ') + ' and ' + names[names.length - 1] + ' are editing this page';
+    }
+    banner.querySelector('.presence-text').textContent = text;
+    banner.hidden = false;
+}
@@ -0,0 +1,26 @@
+// Recent changes page
+
+function resetFeedToken(button) {
+    if (!confirm('Reset your feed token? Feed readers using the old URL will stop updating.')) {
+        return;
+    }
+
+    const params = new URLSearchParams();
+    if (button.dataset.folder) {
+        params.set('folder', button.dataset.folder);
+    }
+    fetch(`/recent/feed-token?${params.toString()}`, {
+        method: 'POST'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to reset feed token');
+        }
+        document.getElementById('feed-url').textContent = data.feedURL;
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error resetting feed token. Please try again.');
+    });
+}
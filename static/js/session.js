@@ -0,0 +1,93 @@
+// Warns before the session expires so unsaved work isn't lost to a redirect
+
+(function() {
+    const POLL_INTERVAL = 60 * 1000;
+
+    let deadline = null;
+    let extendable = false;
+    let warnBefore = 300;
+    let checking = false;
+    let banner = null;
+
+    document.addEventListener('DOMContentLoaded', function() {
+        banner = document.createElement('div');
+        banner.className = 'session-banner';
+        banner.hidden = true;
+        banner.setAttribute('role', 'alert');
+        document.body.appendChild(banner);
+
+        checkSession();
+        setInterval(checkSession, POLL_INTERVAL);
+        setInterval(render, 1000);
+    });
+
+    function checkSession() {
+        if (checking) return;
+        checking = true;
+
+        fetch('/session')
+        .then(response => response.json())
+        .then(update)
+        .catch(error => console.error('Error checking session:', error))
+        .finally(() => { checking = false; });
+    }
+
+    function update(data) {
+        deadline = Date.now() + (data.authenticated ? data.expiresIn * 1000 : 0);
+        extendable = data.extendable;
+        warnBefore = data.warnBefore || warnBefore;
+        render();
+    }
+
+    function render() {
+        if (deadline === null || !banner) return;
+
+        const remaining = Math.max(0, Math.round((deadline - Date.now()) / 1000));
+        if (remaining > warnBefore) {
+            banner.hidden = true;
+            banner.dataset.state = '';
+            return;
+        }
+        if (remaining === 0 && banner.dataset.state !== 'expired') {
+            // Activity in another tab may have extended the session
+            checkSession();
+        }
+
+        const state = remaining === 0 ? 'expired' : (extendable ? 'extendable' : 'ending');
+        if (banner.dataset.state !== state) {
+            banner.dataset.state = state;
+            banner.classList.toggle('expired', state === 'expired');
+            if (state === 'expired') {
+                banner.innerHTML = '<i class="fas fa-exclamation-circle"></i>' +
+                    '<span>Your session has expired. Keep this page open and ' +
+                    '<a href="/login" target="_blank">sign in again</a> in a new tab before saving.</span>';
+            } else {
+                banner.innerHTML = '<i class="fas fa-clock"></i>' +
+                    '<span>Your session expires in <strong class="session-countdown"></strong>.' +
+                    (state === 'extendable' ? '</span><button type="button">Stay signed in</button>' : ' Save your work and sign in again.</span>');
+                const button = banner.querySelector('button');
+                if (button) button.addEventListener('click', keepAlive);
+            }
+        }
+        banner.hidden = false;
+
+        const countdown = banner.querySelector('.session-countdown');
+        if (countdown) {
+            const minutes = Math.floor(remaining / 60);
+            const seconds = String(remaining % 60).padStart(2, '0');
+            countdown.textContent = `${minutes}:${seconds}`;
+        }
+    }
+
+    function keepAlive() {
+        fetch('/session/keepalive', {
+            method: 'POST'
+        })
+        .then(response => response.json())
+        .then(update)
+        .catch(error => {
+            console.error('Error extending session:', error);
+            checkSession();
+        });
+    }
+})();
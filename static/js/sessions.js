@@ -0,0 +1,65 @@
+// Session list and revocation functionality
+
+function revokeSession(id, admin) {
+    if (!confirm('Revoke this session? That browser will be signed out.')) {
+        return;
+    }
+
+    const base = admin ? '/admin/sessions' : '/settings/sessions';
+    fetch(`${base}/${encodeURIComponent(id)}`, {
+        method: 'DELETE'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to revoke session');
+        }
+        window.location.reload();
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error revoking session. Please try again.');
+    });
+}
+
+function revokeUserSessions(email) {
+    if (!confirm(`Sign ${email} out of every session and revoke their access tokens?`)) {
+        return;
+    }
+
+    fetch(`/admin/sessions?email=${encodeURIComponent(email)}`, {
+        method: 'DELETE'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to revoke sessions');
+        }
+        window.location.reload();
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error revoking sessions. Please try again.');
+    });
+}
+
+function logoutEverywhere() {
+    if (!confirm('Sign out of every browser, including this one, and revoke your access tokens?')) {
+        return;
+    }
+
+    fetch('/logout/everywhere', {
+        method: 'POST'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to sign out');
+        }
+        window.location.href = data.redirect || '/login';
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error signing out. Please try again.');
+    });
+}
@@ -0,0 +1,94 @@
+// Share link functionality for the view page and the admin share link list
+
+let currentShareId = null;
+
+function openShareDialog() {
+    currentShareId = null;
+    document.getElementById('share-password').value = '';
+    document.getElementById('share-result').classList.remove('active');
+    document.getElementById('share-revoke').hidden = true;
+    document.getElementById('share-create').hidden = false;
+    document.getElementById('share-popup').classList.add('active');
+}
+
+function closeShareDialog() {
+    document.getElementById('share-popup').classList.remove('active');
+}
+
+function shareUrl(title, folder) {
+    const params = new URLSearchParams();
+    if (folder) params.set('folder', folder);
+    const query = params.toString();
+    return `/shares/${encodeURIComponent(title)}${query ? '?' + query : ''}`;
+}
+
+function createShareLink() {
+    const hours = parseInt(document.getElementById('share-hours').value, 10) || 0;
+    const password = document.getElementById('share-password').value;
+
+    fetch(shareUrl(window.sidebarData.noteTitle, window.sidebarData.folderPath), {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ hours, password })
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to create share link');
+        }
+        currentShareId = data.id;
+        document.getElementById('share-url').value = data.url;
+        document.getElementById('share-result').classList.add('active');
+        document.getElementById('share-revoke').hidden = false;
+        document.getElementById('share-create').hidden = true;
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error creating share link. Please try again.');
+    });
+}
+
+function copyShareLink() {
+    const input = document.getElementById('share-url');
+    input.select();
+    if (navigator.clipboard) {
+        navigator.clipboard.writeText(input.value);
+    } else {
+        document.execCommand('copy');
+    }
+}
+
+function revokeShareLink() {
+    if (!currentShareId) return;
+    revokeShare(currentShareId, function() {
+        currentShareId = null;
+        closeShareDialog();
+    });
+}
+
+function revokeShare(id, done) {
+    if (!confirm('Revoke this share link? It will stop working immediately.')) {
+        return;
+    }
+
+    fetch(`/shares/${encodeURIComponent(id)}`, {
+        method: 'DELETE'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to revoke share link');
+        }
+        if (done) {
+            done();
+        } else {
+            window.location.reload();
+        }
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error revoking share link. Please try again.');
+    });
+}
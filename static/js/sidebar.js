@@ -295,10 +295,42 @@ function highlightCurrentNote(noteTitle, folderPath) {
     }
 }
 
+// Fill the Favorites section with the user's starred pages and folders
+function loadSidebarFavorites() {
+    const section = document.getElementById('sidebar-favorites');
+    if (!section) return;
+
+    fetch('/favorites')
+        .then(response => response.json())
+        .then(data => {
+            const list = document.getElementById('sidebar-favorites-list');
+            list.innerHTML = '';
+            data.favorites.forEach(favorite => {
+                const item = document.createElement('li');
+                item.className = 'tree-item';
+                const link = document.createElement('a');
+                link.className = 'tree-link';
+                link.href = favorite.url;
+                link.title = favorite.path;
+                link.innerHTML = `<i class="fas ${favorite.folder ? 'fa-folder' : 'fa-file-alt'}"></i> `;
+                link.appendChild(document.createTextNode(favorite.name));
+                item.appendChild(link);
+                list.appendChild(item);
+            });
+            section.hidden = data.favorites.length === 0;
+        })
+        .catch(error => {
+            console.error('Error loading favorites:', error);
+        });
+}
+
 // Initialize sidebar functionality
 document.addEventListener('DOMContentLoaded', function() {
     // Initialize tree expand/collapse
     setupTreeExpandCollapse();
+
+    // Show the user's favorites
+    loadSidebarFavorites();
     
     // Initialize sync button
     setupSyncButton();
@@ -0,0 +1,56 @@
+// Personal access token page functionality
+
+document.addEventListener('DOMContentLoaded', function() {
+    const form = document.getElementById('token-form');
+    if (form) form.addEventListener('submit', createToken);
+});
+
+function createToken(event) {
+    event.preventDefault();
+
+    const name = document.getElementById('token-name').value.trim();
+    const expiresInDays = parseInt(document.getElementById('token-expiry').value, 10) || 0;
+    const scopes = Array.from(document.querySelectorAll('input[name="scopes"]:checked')).map(el => el.value);
+
+    fetch('/settings/tokens', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ name, scopes, expiresInDays })
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to create token');
+        }
+        document.getElementById('token-secret-value').textContent = data.token;
+        document.getElementById('token-secret').classList.add('active');
+        document.getElementById('token-form').reset();
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error creating token. Please try again.');
+    });
+}
+
+function revokeToken(id) {
+    if (!confirm('Revoke this token? Anything using it will stop working.')) {
+        return;
+    }
+
+    fetch(`/settings/tokens/${encodeURIComponent(id)}`, {
+        method: 'DELETE'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to revoke token');
+        }
+        window.location.reload();
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error revoking token. Please try again.');
+    });
+}
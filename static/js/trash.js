@@ -0,0 +1,39 @@
+// Trash page functionality
+
+function restoreItem(id) {
+    fetch(`/trash/${encodeURIComponent(id)}/restore`, {
+        method: 'POST'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to restore item');
+        }
+        window.location.href = data.redirect || '/trash';
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error restoring item. Please try again.');
+    });
+}
+
+function purgeItem(id) {
+    if (!confirm('Delete this item forever? This cannot be undone.')) {
+        return;
+    }
+
+    fetch(`/trash/${encodeURIComponent(id)}`, {
+        method: 'DELETE'
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to purge item');
+        }
+        window.location.reload();
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error purging item. Please try again.');
+    });
+}
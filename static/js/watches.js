@@ -0,0 +1,77 @@
+// Watch buttons and the watches settings page
+
+function unwatchRequest(path, folder) {
+    const params = new URLSearchParams({ path, folder: String(folder) });
+    return fetch(`/watches?${params.toString()}`, {
+        method: 'DELETE'
+    });
+}
+
+function watchRequest(path, folder) {
+    return fetch('/watches', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ path, folder })
+    });
+}
+
+function toggleWatch(button) {
+    const path = button.dataset.path;
+    const folder = button.dataset.folder === 'true';
+    const watching = button.dataset.watching === 'true';
+
+    const request = watching ? unwatchRequest(path, folder) : watchRequest(path, folder);
+    request
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to update watch');
+        }
+        button.dataset.watching = String(data.watching);
+        const label = folder ? ' Folder' : '';
+        button.innerHTML = data.watching
+            ? `<i class="fas fa-bell-slash"></i> Unwatch${label}`
+            : `<i class="fas fa-bell"></i> Watch${label}`;
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error updating watch. Please try again.');
+    });
+}
+
+function removeWatch(button) {
+    unwatchRequest(button.dataset.path, button.dataset.folder === 'true')
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to remove watch');
+        }
+        window.location.reload();
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error removing watch. Please try again.');
+    });
+}
+
+function setDigest(digest) {
+    fetch('/settings/watches/digest', {
+        method: 'PUT',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ digest })
+    })
+    .then(response => response.json().then(data => ({ ok: response.ok, data })))
+    .then(({ ok, data }) => {
+        if (!ok) {
+            throw new Error(data.error || 'Failed to save setting');
+        }
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error saving setting. Please try again.');
+    });
+}
@@ -0,0 +1,77 @@
+// Webhook admin page functionality
+
+document.addEventListener('DOMContentLoaded', function() {
+    const form = document.getElementById('webhook-form');
+    if (form) form.addEventListener('submit', createWebhook);
+});
+
+function webhookRequest(url, method, body) {
+    const options = { method };
+    if (body !== undefined) {
+        options.headers = { 'Content-Type': 'application/json' };
+        options.body = JSON.stringify(body);
+    }
+    return fetch(url, options)
+        .then(response => response.json().then(data => ({ ok: response.ok, data })))
+        .then(({ ok, data }) => {
+            if (!ok) {
+                throw new Error(data.error || 'Request failed');
+            }
+            return data;
+        });
+}
+
+function createWebhook(event) {
+    event.preventDefault();
+
+    const url = document.getElementById('webhook-url').value.trim();
+    const folder = document.getElementById('webhook-folder').value.trim();
+    const events = Array.from(document.querySelectorAll('input[name="events"]:checked')).map(el => el.value);
+
+    webhookRequest('/admin/webhooks', 'POST', { url, events, folder })
+    .then(data => {
+        document.getElementById('webhook-secret-value').textContent = data.secret;
+        document.getElementById('webhook-secret').classList.add('active');
+        document.getElementById('webhook-form').reset();
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error adding webhook. Please try again.');
+    });
+}
+
+function setWebhookActive(id, active) {
+    webhookRequest(`/admin/webhooks/${encodeURIComponent(id)}`, 'PUT', { active })
+    .then(() => window.location.reload())
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error updating webhook. Please try again.');
+    });
+}
+
+function testWebhook(id) {
+    webhookRequest(`/admin/webhooks/${encodeURIComponent(id)}/test`, 'POST')
+    .then(() => {
+        // Give the delivery a moment to finish before showing its result
+        setTimeout(() => {
+            window.location.href = `/admin/webhooks?hook=${encodeURIComponent(id)}`;
+        }, 1500);
+    })
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error sending test. Please try again.');
+    });
+}
+
+function deleteWebhook(id) {
+    if (!confirm('Delete this webhook? Nothing more will be sent to it.')) {
+        return;
+    }
+
+    webhookRequest(`/admin/webhooks/${encodeURIComponent(id)}`, 'DELETE')
+    .then(() => window.location.reload())
+    .catch(error => {
+        console.error('Error:', error);
+        alert(error.message || 'Error deleting webhook. Please try again.');
+    });
+}